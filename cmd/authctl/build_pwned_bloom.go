@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"auth-service/pkg/pwned"
+)
+
+// runBuildPwnedBloom 将下载的 Pwned Passwords 文本文件转换为布隆过滤器
+func runBuildPwnedBloom(args []string) error {
+	fs := flag.NewFlagSet("build-pwned-bloom", flag.ExitOnError)
+	in := fs.String("in", "", "下载的泄露密码库文本文件，每行 <SHA-1>:<出现次数>（必填）")
+	out := fs.String("out", "", "输出的布隆过滤器文件路径（必填）")
+	fpr := fs.Float64("fpr", 0.001, "预期误判率")
+	minCount := fs.Int("min-count", 1, "出现次数不少于该值的密码才写入")
+	fs.Parse(args)
+
+	if *in == "" || *out == "" {
+		fs.Usage()
+		return errors.New("缺少 -in 或 -out 参数")
+	}
+
+	src, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("打开输入文件失败: %w", err)
+	}
+	defer src.Close()
+
+	// 先写入同目录下的临时文件，完成后再重命名，避免服务读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(*out), filepath.Base(*out)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	stats, err := pwned.BuildBloom(src, tmp, *fpr, *minCount)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入输出文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入输出文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		return fmt.Errorf("写入输出文件失败: %w", err)
+	}
+
+	fmt.Printf("读取 %d 行，写入 %d 个密码，跳过 %d 个（出现次数低于 %d）\n", stats.Lines, stats.Added, stats.Skipped, *minCount)
+	fmt.Printf("位数组 %d 位（%.1f MiB），哈希函数 %d 个，预期误判率 %.4g\n",
+		stats.Bits, float64(stats.Bits)/8/(1<<20), stats.Hashes, stats.FPR)
+	return nil
+}
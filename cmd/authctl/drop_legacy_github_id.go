@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"auth-service/internal/config"
+	"auth-service/internal/repository"
+	"auth-service/pkg/database"
+)
+
+// runDropLegacyGitHubID 删除已弃用的 users.github_id 列
+// GitHub 账号已迁移到 user_identities，确认不再需要回滚到旧版本后再执行
+func runDropLegacyGitHubID(args []string) error {
+	fs := flag.NewFlagSet("drop-legacy-github-id", flag.ExitOnError)
+	configPath := fs.String("config", "", "配置文件路径，默认按 CONFIG_FILE / APP_ENV 查找")
+	yes := fs.Bool("yes", false, "确认删除；删除后无法再回滚到使用 github_id 的旧版本")
+	fs.Parse(args)
+
+	if !*yes {
+		fs.Usage()
+		return errors.New("删除后无法再回滚到使用 github_id 的旧版本，确认后请加 -yes 执行")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	db, err := database.Connect(&cfg.DB)
+	if err != nil {
+		return err
+	}
+	if err := repository.DropLegacyGitHubID(db); err != nil {
+		return err
+	}
+
+	fmt.Println("已删除 users.github_id 列")
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"auth-service/internal/config"
+	"auth-service/internal/domain/user"
+	"auth-service/internal/repository"
+	"auth-service/internal/userimport"
+	"auth-service/pkg/database"
+	"auth-service/pkg/password"
+)
+
+// runImportUsers 批量导入用户
+func runImportUsers(args []string) error {
+	fs := flag.NewFlagSet("import-users", flag.ExitOnError)
+	configPath := fs.String("config", "", "配置文件路径，默认按 CONFIG_FILE / APP_ENV 查找")
+	file := fs.String("file", "", "导入文件路径（必填）")
+	format := fs.String("format", "", "文件格式：csv 或 jsonl，默认根据扩展名判断")
+	dryRun := fs.Bool("dry-run", false, "只校验，不写入数据库")
+	jsonOutput := fs.Bool("json", false, "以 JSON 输出导入结果")
+	fs.Parse(args)
+
+	if *file == "" {
+		fs.Usage()
+		return errors.New("缺少 -file 参数")
+	}
+	if *format == "" {
+		*format = userimport.FormatFromPath(*file)
+		if *format == "" {
+			return fmt.Errorf("无法根据扩展名判断文件格式，请使用 -format 指定: %s", *file)
+		}
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	hasher, err := password.NewHasher(&cfg.PasswordHash)
+	if err != nil {
+		return err
+	}
+	policy, err := password.NewPolicy(&cfg.PasswordPolicy)
+	if err != nil {
+		return err
+	}
+	db, err := database.Connect(&cfg.DB)
+	if err != nil {
+		return err
+	}
+	// 逐行导入时不输出每条 SQL
+	db = db.Session(&gorm.Session{Logger: db.Logger.LogMode(gormlogger.Warn)})
+	if !*dryRun {
+		if err := repository.AutoMigrate(db); err != nil {
+			return fmt.Errorf("更新数据表结构失败: %w", err)
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return fmt.Errorf("打开导入文件失败: %w", err)
+	}
+	defer f.Close()
+
+	userService := user.NewService(repository.NewUserRepository(db), hasher, policy)
+	report, err := userimport.Run(userService, f, *format, *dryRun)
+	if report != nil {
+		printReport(report, *jsonOutput)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d 条记录导入失败", report.Failed)
+	}
+	return nil
+}
+
+// printReport 输出导入结果
+func printReport(report *userimport.Report, asJSON bool) {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		return
+	}
+
+	for _, e := range report.Errors {
+		if e.Username != "" {
+			fmt.Printf("第 %d 行（%s）: %s\n", e.Line, e.Username, e.Error)
+		} else {
+			fmt.Printf("第 %d 行: %s\n", e.Line, e.Error)
+		}
+	}
+
+	action := "导入成功"
+	if report.DryRun {
+		action = "校验通过（试运行，未写入）"
+	}
+	fmt.Printf("共 %d 条记录，%s %d 条，失败 %d 条\n", report.Total, action, report.Imported, report.Failed)
+}
@@ -0,0 +1,58 @@
+// authctl 运维命令行工具
+//
+// 用法：
+//
+//	authctl import-users -file users.csv [-format csv|jsonl] [-dry-run] [-config configs/config.prod.yaml]
+//	authctl build-pwned-bloom -in pwnedpasswords.txt -out pwned.bloom [-fpr 0.001] [-min-count 1]
+//	authctl drop-legacy-github-id -yes [-config configs/config.prod.yaml]
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command 子命令
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{name: "import-users", summary: "从 CSV / JSONL 批量导入用户（支持外部系统的旧密码哈希）", run: runImportUsers},
+	{name: "build-pwned-bloom", summary: "将下载的 Pwned Passwords 文本文件转换为布隆过滤器", run: runBuildPwnedBloom},
+	{name: "drop-legacy-github-id", summary: "删除已迁移到 user_identities 的旧版 users.github_id 列（删除后无法回滚）", run: runDropLegacyGitHubID},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: authctl <命令> [参数]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "使用 authctl <命令> -h 查看命令参数")
+}
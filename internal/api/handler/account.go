@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/lockout"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/mail"
+	"auth-service/pkg/session"
+	"auth-service/pkg/token"
+	"auth-service/pkg/verification"
+)
+
+// ChangePasswordRequest 修改密码请求参数结构体
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"` // 需符合密码策略
+}
+
+// ChangeEmailRequest 修改邮箱请求参数结构体
+type ChangeEmailRequest struct {
+	Email string `json:"email" binding:"required,email"` // 新邮箱
+}
+
+// ConfirmEmailChangeRequest 确认修改邮箱请求参数结构体
+type ConfirmEmailChangeRequest struct {
+	Email string `json:"email" binding:"required,email"`        // 新邮箱
+	Code  string `json:"code" binding:"required,len=6,numeric"` // 发送到新邮箱的验证码
+}
+
+// AccountHandler 账号信息管理处理器（修改密码、修改邮箱）
+type AccountHandler struct {
+	userService    *user.Service
+	codeManager    *verification.Manager
+	mailSender     mail.Sender
+	sessionManager *session.Manager
+	tokenManager   *token.Manager
+	loginLockout   *loginLockout // 当前密码错误与登录失败共用锁定计数
+	logger         *logger.ZapLogger
+}
+
+// NewAccountHandler 创建账号信息管理处理器实例
+func NewAccountHandler(userService *user.Service, codeManager *verification.Manager, mailSender mail.Sender, sessionManager *session.Manager, tokenManager *token.Manager, lockoutManager *lockout.Manager, logger *logger.ZapLogger) *AccountHandler {
+	return &AccountHandler{
+		userService:    userService,
+		codeManager:    codeManager,
+		mailSender:     mailSender,
+		sessionManager: sessionManager,
+		tokenManager:   tokenManager,
+		loginLockout:   newLoginLockout(lockoutManager, sessionManager, tokenManager, mailSender, logger),
+		logger:         logger,
+	}
+}
+
+// ChangePassword 修改密码
+// @Summary 修改密码
+// @Description 校验当前密码后设置新密码，新密码需符合密码策略；成功后除当前设备外的所有登录会话全部下线。
+// @Description 当前密码错误计入账号的登录失败次数，账号锁定期间不能修改密码
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangePasswordRequest true "当前密码和新密码"
+// @Success 200 {object} gin.H{message:string, revoked_sessions:int}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Failure 429 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/user/password [put]
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("修改密码失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	// 否则持有访问令牌即可无限次猜测当前密码，绕过登录失败锁定
+	if h.loginLockout.stepUpLocked(c, claims.UserID) {
+		return
+	}
+
+	if err := h.userService.ChangePassword(claims.UserID, req.CurrentPassword, req.NewPassword); err != nil {
+		if respondPolicyError(c, "new_password", err) {
+			return
+		}
+		switch {
+		case errors.Is(err, user.ErrPasswordWrong):
+			h.logger.Warn("修改密码失败：当前密码错误",
+				zap.Uint("user_id", claims.UserID),
+				zap.String("client_ip", c.ClientIP()),
+			)
+			u, getErr := h.userService.GetByID(claims.UserID)
+			if getErr != nil {
+				h.logger.Error("修改密码失败：查询用户失败",
+					zap.Uint("user_id", claims.UserID),
+					zap.Error(getErr),
+				)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			h.loginLockout.stepUpFailed(c, u, err.Error())
+		case errors.Is(err, user.ErrPasswordNotSet):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("修改密码失败",
+				zap.Uint("user_id", claims.UserID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "修改密码失败"})
+		}
+		return
+	}
+
+	// 保留当前会话，其他设备全部下线
+	revoked, err := revokeOtherUserSessions(c, h.sessionManager, h.tokenManager, claims.UserID, claims.SessionID)
+	if err != nil {
+		h.logger.Error("修改密码后下线其他设备失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码已修改，但下线其他设备失败"})
+		return
+	}
+
+	h.logger.Info("用户已修改密码",
+		zap.Uint("user_id", claims.UserID),
+		zap.Int("revoked_sessions", revoked),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "密码已修改，其他设备已下线",
+		"revoked_sessions": revoked,
+	})
+}
+
+// ChangeEmail 发起修改邮箱
+// @Summary 修改邮箱
+// @Description 向新邮箱发送验证码，同时通知原邮箱；提交验证码到确认接口后邮箱才会修改
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangeEmailRequest true "新邮箱"
+// @Success 200 {object} gin.H{message:string, expires_in:int64}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 409 {object} gin.H{error:string}
+// @Failure 429 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/user/email [put]
+func (h *AccountHandler) ChangeEmail(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("修改邮箱失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	u, err := h.userService.CheckEmailChange(claims.UserID, req.Email)
+	if err != nil {
+		h.respondEmailError(c, "修改邮箱失败", claims.UserID, err)
+		return
+	}
+
+	code, err := h.codeManager.Issue(c.Request.Context(), verification.PurposeChangeEmail, emailChangeTarget(u.ID, req.Email))
+	if err != nil {
+		if errors.Is(err, verification.ErrResendTooSoon) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("修改邮箱失败：生成验证码失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送验证码失败"})
+		return
+	}
+
+	minutes := int(h.codeManager.TTL().Minutes())
+	if err := h.mailSender.Send(c.Request.Context(), &mail.Message{
+		To:      req.Email,
+		Subject: "确认修改邮箱",
+		Body:    fmt.Sprintf("%s，您好：\n\n您正在将账号邮箱修改为本邮箱，验证码是：%s\n\n验证码 %d 分钟内有效，请勿泄露给他人。如非本人操作，请忽略本邮件。\n", u.Username, code, minutes),
+	}); err != nil {
+		h.logger.Error("修改邮箱失败：发送验证码邮件失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送验证码失败"})
+		return
+	}
+
+	// 通知原邮箱，账号被盗用时用户可以及时发现
+	if u.Email != "" {
+		go h.sendNotice(u.ID, &mail.Message{
+			To:      u.Email,
+			Subject: "账号邮箱修改提醒",
+			Body:    fmt.Sprintf("%s，您好：\n\n您的账号于 %s 申请将邮箱修改为 %s。\n\n如非本人操作，请立即修改密码并下线所有设备。\n", u.Username, time.Now().Format("2006-01-02 15:04:05"), req.Email),
+		})
+	}
+
+	h.logger.Info("修改邮箱验证码已发送",
+		zap.Uint("user_id", u.ID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "验证码已发送到新邮箱",
+		"expires_in": int64(h.codeManager.TTL().Seconds()),
+	})
+}
+
+// ConfirmEmailChange 确认修改邮箱
+// @Summary 确认修改邮箱
+// @Description 提交发送到新邮箱的验证码，校验通过后修改账号邮箱
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ConfirmEmailChangeRequest true "新邮箱和验证码"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 409 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/user/email/confirm [post]
+func (h *AccountHandler) ConfirmEmailChange(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("确认修改邮箱失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	if err := h.codeManager.Consume(c.Request.Context(), verification.PurposeChangeEmail, emailChangeTarget(claims.UserID, req.Email), req.Code); err != nil {
+		switch {
+		case errors.Is(err, verification.ErrCodeInvalid),
+			errors.Is(err, verification.ErrCodeNotFound),
+			errors.Is(err, verification.ErrTooManyAttempts):
+			h.logger.Warn("确认修改邮箱失败：验证码校验失败",
+				zap.Uint("user_id", claims.UserID),
+				zap.String("client_ip", c.ClientIP()),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("确认修改邮箱失败：校验验证码失败",
+				zap.Uint("user_id", claims.UserID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "修改邮箱失败"})
+		}
+		return
+	}
+
+	u, err := h.userService.ChangeEmail(claims.UserID, req.Email)
+	if err != nil {
+		h.respondEmailError(c, "确认修改邮箱失败", claims.UserID, err)
+		return
+	}
+
+	h.logger.Info("用户已修改邮箱",
+		zap.Uint("user_id", u.ID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, UserResponse{
+		ID:        u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		AuthType:  u.AuthType,
+		AvatarURL: u.AvatarURL,
+		CreatedAt: u.CreatedAt,
+	})
+}
+
+// respondEmailError 将修改邮箱的领域错误映射为 HTTP 响应
+func (h *AccountHandler) respondEmailError(c *gin.Context, msg string, userID uint, err error) {
+	switch {
+	case errors.Is(err, user.ErrEmailExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrEmailUnchanged):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg,
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	}
+}
+
+// sendNotice 异步发送安全提醒邮件，发送失败只记录日志
+func (h *AccountHandler) sendNotice(userID uint, msg *mail.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := h.mailSender.Send(ctx, msg); err != nil {
+		h.logger.Warn("发送安全提醒邮件失败",
+			zap.Uint("user_id", userID),
+			zap.String("subject", msg.Subject),
+			zap.Error(err),
+		)
+	}
+}
+
+// emailChangeTarget 修改邮箱验证码的接收方，绑定用户ID，使验证码只能由发起修改的用户使用
+func emailChangeTarget(userID uint, email string) string {
+	return fmt.Sprintf("%d:%s", userID, email)
+}
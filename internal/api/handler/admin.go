@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/jwt"
+	"auth-service/pkg/lockout"
+	"auth-service/pkg/logger"
+)
+
+// SigningKeyResponse 签名密钥信息响应结构体（不包含私钥）
+type SigningKeyResponse struct {
+	KeyID     string     `json:"kid"`
+	Algorithm string     `json:"alg"`
+	Active    bool       `json:"active"` // 是否为当前签名密钥
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+}
+
+// LockoutResponse 账号登录锁定状态响应结构体
+type LockoutResponse struct {
+	UserID     uint `json:"user_id"`
+	Locked     bool `json:"locked"`
+	Permanent  bool `json:"permanent"`             // 永久锁定，需解锁或用户重置密码
+	RetryAfter int  `json:"retry_after,omitempty"` // 临时锁定剩余秒数
+}
+
+// AdminHandler 管理接口处理器
+type AdminHandler struct {
+	keyRing        *jwt.KeyRing
+	userService    *user.Service
+	lockoutManager *lockout.Manager
+	logger         *logger.ZapLogger
+}
+
+// NewAdminHandler 创建管理接口处理器实例
+func NewAdminHandler(keyRing *jwt.KeyRing, userService *user.Service, lockoutManager *lockout.Manager, logger *logger.ZapLogger) *AdminHandler {
+	return &AdminHandler{
+		keyRing:        keyRing,
+		userService:    userService,
+		lockoutManager: lockoutManager,
+		logger:         logger,
+	}
+}
+
+// ListSigningKeys 查询签名密钥
+// @Summary 查询签名密钥
+// @Description 列出密钥环中的所有密钥及其生效时间，不返回私钥
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "管理密钥"
+// @Success 200 {object} gin.H{keys:[]SigningKeyResponse}
+// @Failure 401 {object} gin.H{error:string}
+// @Router /admin/keys [get]
+func (h *AdminHandler) ListSigningKeys(c *gin.Context) {
+	var activeID string
+	if active, err := h.keyRing.SigningKey(); err == nil {
+		activeID = active.ID
+	}
+
+	keys := make([]SigningKeyResponse, 0)
+	for _, key := range h.keyRing.Keys() {
+		keys = append(keys, newSigningKeyResponse(key, activeID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// RotateSigningKey 轮换签名密钥
+// @Summary 轮换签名密钥
+// @Description 生成新的签名密钥并写入存储；新密钥预发布一段时间后开始签名，旧密钥在访问令牌有效期内继续用于验证
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "管理密钥"
+// @Success 201 {object} SigningKeyResponse
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /admin/keys/rotate [post]
+func (h *AdminHandler) RotateSigningKey(c *gin.Context) {
+	key, err := h.keyRing.Rotate(c.Request.Context())
+	if err != nil {
+		h.logger.Error("轮换签名密钥失败",
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "轮换签名密钥失败"})
+		return
+	}
+
+	h.logger.Info("签名密钥已轮换",
+		zap.String("kid", key.ID),
+		zap.Time("not_before", key.NotBefore),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusCreated, newSigningKeyResponse(key, ""))
+}
+
+// GetUserLockout 查询账号登录锁定状态
+// @Summary 查询账号锁定状态
+// @Description 查询账号是否因登录失败次数过多被临时或永久锁定
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "管理密钥"
+// @Param id path int true "用户ID"
+// @Success 200 {object} LockoutResponse
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 404 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /admin/users/{id}/lockout [get]
+func (h *AdminHandler) GetUserLockout(c *gin.Context) {
+	u, ok := h.lookupUser(c)
+	if !ok {
+		return
+	}
+
+	st, err := h.lockoutManager.CheckAccount(c.Request.Context(), u.ID)
+	if err != nil {
+		h.logger.Error("查询账号锁定状态失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询账号锁定状态失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, LockoutResponse{
+		UserID:     u.ID,
+		Locked:     st.Locked,
+		Permanent:  st.Permanent,
+		RetryAfter: ceilSeconds(st.RetryAfter),
+	})
+}
+
+// UnlockUser 解除账号登录锁定
+// @Summary 解除账号锁定
+// @Description 解除账号的临时或永久锁定，并清除登录失败次数和累计锁定次数
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "管理密钥"
+// @Param id path int true "用户ID"
+// @Success 200 {object} LockoutResponse
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 404 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /admin/users/{id}/unlock [post]
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	u, ok := h.lookupUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.lockoutManager.Unlock(c.Request.Context(), u.ID); err != nil {
+		h.logger.Error("解除账号锁定失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解除账号锁定失败"})
+		return
+	}
+
+	h.logger.Info("管理员已解除账号锁定",
+		zap.Uint("user_id", u.ID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, LockoutResponse{UserID: u.ID})
+}
+
+// lookupUser 根据路径参数 id 查询用户，失败时写入响应并返回 false
+func (h *AdminHandler) lookupUser(c *gin.Context) (*user.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+
+	u, err := h.userService.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return nil, false
+		}
+		h.logger.Error("查询用户失败",
+			zap.Uint64("user_id", id),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+		return nil, false
+	}
+	return u, true
+}
+
+// newSigningKeyResponse 构造签名密钥响应
+func newSigningKeyResponse(key *jwt.Key, activeID string) SigningKeyResponse {
+	resp := SigningKeyResponse{
+		KeyID:     key.ID,
+		Algorithm: key.Algorithm,
+		Active:    key.ID == activeID,
+	}
+	if !key.NotBefore.IsZero() {
+		notBefore := key.NotBefore
+		resp.NotBefore = &notBefore
+	}
+	if !key.NotAfter.IsZero() {
+		notAfter := key.NotAfter
+		resp.NotAfter = &notAfter
+	}
+	return resp
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -12,24 +14,54 @@ import (
 	"auth-service/internal/domain/user"
 	"auth-service/pkg/captcha"
 	"auth-service/pkg/jwt"
+	"auth-service/pkg/lockout"
 	"auth-service/pkg/logger"
+	"auth-service/pkg/mail"
+	"auth-service/pkg/session"
+	"auth-service/pkg/token"
+	"auth-service/pkg/verification"
 )
 
 // LoginRequest 登录请求参数结构体
 type LoginRequest struct {
 	Username      string `json:"username" binding:"required,min=3,max=20"` // 用户名验证规则
-	Password      string `json:"password" binding:"required,min=6"`        // 密码验证规则
-	HCaptchaToken string `json:"hcaptcha_token" binding:"required"`        // hCaptcha 令牌
+	Password      string `json:"password" binding:"required,max=1024"`     // 密码规则由密码策略在设置密码时校验，登录时不校验最小长度，兼容导入的旧账号
+	CaptchaToken  string `json:"captcha_token"`                            // 人机验证令牌，存在风险时必填（可通过 /auth/login/captcha 预先查询）
+	HCaptchaToken string `json:"hcaptcha_token"`                           // 已弃用，同 captcha_token
+	DeviceName    string `json:"device_name" binding:"max=64"`             // 可选：设备名称，为空时根据 User-Agent 推断
+	DeviceID      string `json:"device_id" binding:"max=64"`               // 可选：上次登录返回的设备标识，常用设备免人机验证
+}
+
+// LoginMFARequest 两步验证登录请求参数结构体
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`         // 登录接口返回的两步验证挑战令牌
+	Code     string `json:"code" binding:"required,min=6,max=32"` // 6位 TOTP 验证码或恢复码
 }
 
 // RegisterRequest 注册请求参数结构体
 type RegisterRequest struct {
 	Username         string `json:"username" binding:"required,min=3,max=20"`
-	Password         string `json:"password" binding:"required,min=6"`
+	Password         string `json:"password" binding:"required"`                // 需符合密码策略
 	Email            string `json:"email" binding:"required,email"`             // 邮箱格式验证
 	VerificationCode string `json:"verification_code" binding:"required,len=6"` // 新增：邮箱验证码，必须6位
 }
 
+// SendEmailCodeRequest 发送邮箱验证码请求参数结构体
+type SendEmailCodeRequest struct {
+	Email   string `json:"email" binding:"required,email"`
+	Purpose string `json:"purpose" binding:"omitempty,oneof=register"` // 验证码用途，默认为 register
+}
+
+// RefreshTokenRequest 刷新令牌请求参数结构体
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 注销请求参数结构体
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"` // 可选：令牌未携带会话ID时，同时吊销该刷新令牌所在的令牌族
+}
+
 // UserResponse 用户信息响应结构体
 type UserResponse struct {
 	ID        uint      `json:"id"`
@@ -45,29 +77,43 @@ type AuthHandler struct {
 	userService     *user.Service
 	config          *config.Config
 	logger          *logger.ZapLogger
-	hcaptchaService *captcha.HCaptchaService // 新增 hCaptcha 服务
+	captchaVerifier captcha.Verifier      // 人机验证服务（hCaptcha / reCAPTCHA / Turnstile）
+	tokenManager    *token.Manager        // 令牌管理器
+	sessionManager  *session.Manager      // 登录会话管理器
+	codeManager     *verification.Manager // 邮箱验证码管理器
+	mailSender      mail.Sender           // 邮件发送器
+	loginLockout    *loginLockout         // 登录失败锁定
+	captchaAssessor *captcha.Assessor     // 登录风险评估，决定是否要求人机验证
 }
 
 // NewAuthHandler 创建认证处理器实例
-func NewAuthHandler(userService *user.Service, cfg *config.Config, logger *logger.ZapLogger, hcaptchaService *captcha.HCaptchaService) *AuthHandler {
+func NewAuthHandler(userService *user.Service, cfg *config.Config, logger *logger.ZapLogger, captchaVerifier captcha.Verifier, tokenManager *token.Manager, sessionManager *session.Manager, codeManager *verification.Manager, mailSender mail.Sender, lockoutManager *lockout.Manager, captchaAssessor *captcha.Assessor) *AuthHandler {
 	return &AuthHandler{
 		userService:     userService,
 		config:          cfg,
 		logger:          logger,
-		hcaptchaService: hcaptchaService,
+		captchaVerifier: captchaVerifier,
+		tokenManager:    tokenManager,
+		sessionManager:  sessionManager,
+		codeManager:     codeManager,
+		mailSender:      mailSender,
+		loginLockout:    newLoginLockout(lockoutManager, sessionManager, tokenManager, mailSender, logger),
+		captchaAssessor: captchaAssessor,
 	}
 }
 
 // Login 处理用户登录请求
 // @Summary 用户登录
-// @Description 通过用户名和密码获取JWT令牌，需要通过hCaptcha验证
+// @Description 通过用户名和密码获取JWT令牌，存在风险时（近期登录失败、新设备、请求频率过高）需要通过人机验证，登录成功（已启用两步验证时为第二因子验证通过）响应中的 device_id 供下次登录携带；已启用两步验证的用户返回 mfa_token，需再调用 /auth/login/mfa；连续登录失败过多时账号或 IP 会被锁定，账号锁定期间返回与密码错误相同的响应，并通过邮件通知用户
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param request body LoginRequest true "登录参数，包含hCaptcha令牌"
-// @Success 200 {object} gin.H{token:string, user_id:uint, username:string}
-// @Failure 400 {object} gin.H{error:string}
-// @Failure 401 {object} gin.H{error:string}
+// @Param request body LoginRequest true "登录参数，存在风险时需包含人机验证令牌"
+// @Success 200 {object} gin.H{token:string, refresh_token:string, expires_in:int64, user_id:uint, username:string, device_id:string}
+// @Success 202 {object} gin.H{mfa_required:bool, mfa_token:string, mfa_methods:[]string, expires_in:int64}
+// @Failure 400 {object} gin.H{error:string, captcha_required:bool}
+// @Failure 401 {object} gin.H{error:string, captcha_required:bool}
+// @Failure 429 {object} gin.H{error:string, retry_after:int}
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
@@ -77,18 +123,39 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 1. 首先验证 hCaptcha
-	if err := h.hcaptchaService.VerifyToken(c.Request.Context(), req.HCaptchaToken, c.ClientIP()); err != nil {
-		h.logger.Warn("用户登录失败：hCaptcha验证失败",
-			zap.String("username", req.Username),
+	// 1. 存在风险（近期登录失败、新设备、请求频率过高）时进行人机验证
+	if err := h.captchaAssessor.RecordAttempt(c.Request.Context(), c.ClientIP()); err != nil {
+		h.logger.Warn("记录登录请求频率失败",
 			zap.String("client_ip", c.ClientIP()),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "人机验证失败，请重试"})
+	}
+	if h.captchaRequired(c, req.Username, req.DeviceID) {
+		captchaToken := req.CaptchaToken
+		if captchaToken == "" {
+			captchaToken = req.HCaptchaToken
+		}
+		if captchaToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请完成人机验证", "captcha_required": true})
+			return
+		}
+		if err := h.captchaVerifier.VerifyToken(c.Request.Context(), captchaToken, c.ClientIP()); err != nil {
+			h.logger.Warn("用户登录失败：人机验证失败",
+				zap.String("username", req.Username),
+				zap.String("client_ip", c.ClientIP()),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "人机验证失败，请重试", "captcha_required": true})
+			return
+		}
+	}
+
+	// 2. 登录失败次数过多的 IP 暂时禁止登录
+	if h.ipLockedOut(c) {
 		return
 	}
 
-	// 2. 调用服务层查询用户
+	// 3. 调用服务层查询用户
 	u, err := h.userService.GetByUsername(req.Username)
 	if err != nil {
 		// 记录详细错误信息到日志（便于调试）
@@ -97,12 +164,20 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			zap.String("client_ip", c.ClientIP()),
 			zap.Error(err),
 		)
+		h.loginLockout.recordFailure(c, nil)
 		// 统一返回认证失败，避免泄露用户是否存在
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+		h.respondLoginFailed(c, req.Username, req.DeviceID)
 		return
 	}
 
-	// 3. 验证密码
+	// 4. 账号已锁定时不再校验密码，返回与密码错误相同的响应，避免泄露用户是否存在（锁定只通过邮件告知用户）
+	if h.loginLockout.accountLocked(c, u.ID) != nil {
+		h.loginLockout.recordFailure(c, nil)
+		h.respondLoginFailed(c, req.Username, req.DeviceID)
+		return
+	}
+
+	// 5. 验证密码
 	if !u.CheckPassword(req.Password) {
 		// 记录密码验证失败（安全审计）
 		h.logger.Warn("用户登录失败：密码错误",
@@ -110,12 +185,69 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			zap.Uint("user_id", u.ID),
 			zap.String("client_ip", c.ClientIP()),
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+		h.loginLockout.recordFailure(c, u)
+		h.respondLoginFailed(c, req.Username, req.DeviceID)
+		return
+	}
+	// 密码哈希使用了过时的算法或参数时透明升级，失败不影响登录
+	if rehashed, err := h.userService.RehashPasswordIfNeeded(u, req.Password); err != nil {
+		h.logger.Warn("升级密码哈希失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+	} else if rehashed {
+		h.logger.Info("已升级用户密码哈希",
+			zap.Uint("user_id", u.ID),
+		)
+	}
+
+	// 6. 已启用两步验证时先签发挑战令牌，待第二因子验证通过后再签发令牌、信任设备并清除失败次数
+	mfaMethods, err := h.userService.MFAMethods(u)
+	if err != nil {
+		h.logger.Error("查询两步验证方式失败",
+			zap.String("username", req.Username),
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+	if len(mfaMethods) > 0 {
+		mfaToken, err := h.sessionManager.CreateMFAChallenge(c.Request.Context(), session.MFAChallenge{
+			UserID:     u.ID,
+			AuthMethod: session.AuthMethodPassword,
+			DeviceName: req.DeviceName,
+			DeviceID:   req.DeviceID,
+			ClientIP:   c.ClientIP(),
+		}, h.config.MFA.ChallengeTTL)
+		if err != nil {
+			h.logger.Error("创建两步验证挑战失败",
+				zap.String("username", req.Username),
+				zap.Uint("user_id", u.ID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+			return
+		}
+
+		h.logger.Info("用户密码验证通过，等待两步验证",
+			zap.String("username", req.Username),
+			zap.Uint("user_id", u.ID),
+			zap.String("client_ip", c.ClientIP()),
+		)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"mfa_methods":  mfaMethods,
+			"expires_in":   int64(h.config.MFA.ChallengeTTL.Seconds()),
+		})
 		return
 	}
+	deviceID := recordLoginSuccess(c, h.captchaAssessor, h.loginLockout, h.logger, u, req.DeviceID)
 
-	// 4. 生成JWT令牌
-	token, err := jwt.GenerateToken(u.ID, u.Username, h.config.JWT.Secret, 2*time.Hour)
+	// 7. 创建登录会话并签发访问令牌和刷新令牌
+	pair, err := startUserSession(c, h.sessionManager, h.tokenManager, h.config, u, session.AuthMethodPassword, req.DeviceName)
 	if err != nil {
 		// 记录令牌生成失败的详细错误
 		h.logger.Error("JWT令牌生成失败",
@@ -128,24 +260,219 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 5. 记录成功登录日志
+	// 8. 记录成功登录日志
 	h.logger.Info("用户登录成功",
 		zap.String("username", req.Username),
 		zap.Uint("user_id", u.ID),
 		zap.String("client_ip", c.ClientIP()),
 	)
 
-	// 6. 返回登录结果
+	// 9. 返回登录结果
+	c.JSON(http.StatusOK, gin.H{
+		"token":              pair.AccessToken,
+		"refresh_token":      pair.RefreshToken,
+		"token_type":         pair.TokenType,
+		"expires_in":         pair.ExpiresIn,
+		"refresh_expires_in": pair.RefreshExpiresIn,
+		"session_id":         pair.SessionID,
+		"user_id":            u.ID,
+		"username":           u.Username,
+		"device_id":          deviceID,
+	})
+}
+
+// LoginMFA 提交第二因子完成登录
+// @Summary 两步验证登录
+// @Description 使用登录接口（或外部账号登录码兑换接口）返回的 mfa_token 和 TOTP 验证码（或恢复码）完成登录，每个 mfa_token 最多尝试5次，验证码错误计入账号的登录失败次数；安全密钥验证见 /auth/login/mfa/webauthn
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body LoginMFARequest true "两步验证参数"
+// @Success 200 {object} gin.H{token:string, refresh_token:string, expires_in:int64, user_id:uint, username:string, device_id:string}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Router /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	// 1. 校验挑战令牌并记录尝试次数
+	challenge, err := h.sessionManager.AttemptMFAChallenge(c.Request.Context(), req.MFAToken, h.config.MFA.ChallengeTTL)
+	if err != nil {
+		if errors.Is(err, session.ErrMFAChallengeNotFound) || errors.Is(err, session.ErrMFAChallengeExhausted) {
+			h.logger.Warn("两步验证登录失败：挑战无效",
+				zap.String("client_ip", c.ClientIP()),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("两步验证登录失败：查询挑战失败",
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+
+	u, err := h.userService.GetByID(challenge.UserID)
+	if err != nil {
+		h.logger.Warn("两步验证登录失败：查询用户时发生错误",
+			zap.Uint("user_id", challenge.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "两步验证已过期，请重新登录"})
+		return
+	}
+
+	// 2. 账号在密码验证之后被锁定时不再继续
+	if st := h.loginLockout.accountLocked(c, u.ID); st != nil {
+		respondLockedOut(c, st)
+		return
+	}
+
+	// 3. 校验 TOTP 验证码或恢复码
+	if err := h.userService.VerifySecondFactor(u, req.Code); err != nil {
+		if errors.Is(err, user.ErrMFACodeInvalid) {
+			h.logger.Warn("两步验证登录失败：验证码错误",
+				zap.Uint("user_id", u.ID),
+				zap.String("client_ip", c.ClientIP()),
+			)
+			// 能提交第二因子说明密码已泄露，验证码错误与密码错误一样计入锁定
+			if st := h.loginLockout.recordFailure(c, u); st != nil && st.Locked {
+				respondLockedOut(c, st)
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "验证码错误"})
+			return
+		}
+		if errors.Is(err, user.ErrMFANotEnabled) {
+			// 仅注册了安全密钥的用户需使用 /auth/login/mfa/webauthn 完成验证
+			c.JSON(http.StatusBadRequest, gin.H{"error": "该账号未启用验证码两步验证，请使用安全密钥"})
+			return
+		}
+		h.logger.Error("两步验证登录失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+
+	// 挑战令牌一次性使用
+	if err := h.sessionManager.DeleteMFAChallenge(c.Request.Context(), req.MFAToken); err != nil {
+		h.logger.Warn("删除两步验证挑战失败", zap.Error(err))
+	}
+	deviceID := recordLoginSuccess(c, h.captchaAssessor, h.loginLockout, h.logger, u, challenge.DeviceID)
+
+	// 4. 创建登录会话并签发令牌
+	pair, err := startUserSession(c, h.sessionManager, h.tokenManager, h.config, u, challenge.CompleteAuthMethod(session.SecondFactorTOTP), challenge.DeviceName)
+	if err != nil {
+		h.logger.Error("JWT令牌生成失败",
+			zap.Uint("user_id", u.ID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+
+	h.logger.Info("用户两步验证登录成功",
+		zap.String("username", u.Username),
+		zap.Uint("user_id", u.ID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
 	c.JSON(http.StatusOK, gin.H{
-		"token":    token,
-		"user_id":  u.ID,
-		"username": u.Username,
+		"token":              pair.AccessToken,
+		"refresh_token":      pair.RefreshToken,
+		"token_type":         pair.TokenType,
+		"expires_in":         pair.ExpiresIn,
+		"refresh_expires_in": pair.RefreshExpiresIn,
+		"session_id":         pair.SessionID,
+		"user_id":            u.ID,
+		"username":           u.Username,
+		"device_id":          deviceID,
+	})
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌对
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效；重复使用已失效的刷新令牌会吊销整个令牌族
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "刷新令牌"
+// @Success 200 {object} gin.H{token:string, refresh_token:string, expires_in:int64}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Router /auth/token/refresh [post]
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	// 账号被锁定期间不轮换刷新令牌，锁定解除后原刷新令牌仍可使用（永久锁定时令牌已被吊销）
+	if userID, err := h.tokenManager.RefreshTokenOwner(c.Request.Context(), req.RefreshToken); err == nil {
+		if st := h.loginLockout.accountLocked(c, userID); st != nil {
+			respondLockedOut(c, st)
+			return
+		}
+	}
+
+	pair, err := h.tokenManager.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, token.ErrRefreshTokenReused):
+			// 已使用的刷新令牌再次出现，可能已被窃取
+			h.logger.Warn("检测到刷新令牌重用，已吊销令牌族",
+				zap.String("client_ip", c.ClientIP()),
+				zap.String("user_agent", c.GetHeader("User-Agent")),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "刷新令牌无效，请重新登录"})
+		case errors.Is(err, token.ErrRefreshTokenInvalid):
+			h.logger.Warn("刷新令牌失败：令牌无效或已过期",
+				zap.String("client_ip", c.ClientIP()),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "刷新令牌无效，请重新登录"})
+		default:
+			h.logger.Error("刷新令牌失败",
+				zap.String("client_ip", c.ClientIP()),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+		}
+		return
+	}
+
+	// 以刷新时间作为会话最近活跃时间
+	if err := h.sessionManager.TouchUserSession(c.Request.Context(), pair.SessionID, c.ClientIP(), h.config.JWT.RefreshTokenTTL); err != nil {
+		h.logger.Warn("更新登录会话活跃时间失败",
+			zap.String("session_id", pair.SessionID),
+			zap.Error(err),
+		)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":              pair.AccessToken,
+		"refresh_token":      pair.RefreshToken,
+		"token_type":         pair.TokenType,
+		"expires_in":         pair.ExpiresIn,
+		"refresh_expires_in": pair.RefreshExpiresIn,
+		"session_id":         pair.SessionID,
 	})
 }
 
 // Register 处理用户注册请求
 // @Summary 用户注册
-// @Description 创建新用户账号，需要提供邮箱验证码
+// @Description 创建新用户账号，需要提供通过 /auth/email/code 获取的邮箱验证码；密码不符合密码策略时返回违反的规则
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -167,25 +494,73 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// 验证邮箱验证码（模拟验证，实际项目中应该从Redis或数据库中验证）
-	if !h.validateEmailVerificationCode(req.Email, req.VerificationCode) {
-		h.logger.Warn("用户注册失败：邮箱验证码错误",
+	// 先校验密码策略，避免密码不合规时浪费邮箱验证码
+	if err := h.userService.ValidatePassword(&user.User{Username: req.Username, Email: req.Email}, req.Password); err != nil {
+		if respondPolicyError(c, "password", err) {
+			return
+		}
+		h.logger.Error("用户注册失败：校验密码策略失败",
+			zap.String("username", req.Username),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败"})
+		return
+	}
+
+	// 用户名已被占用时同样不消费验证码，换个用户名即可重试
+	// 邮箱是否已注册只在验证码校验通过后检查，否则注册接口本身会泄露邮箱是否已注册
+	usernameExists, err := h.userService.UsernameExists(req.Username)
+	if err != nil {
+		h.logger.Error("用户注册失败：查询用户名失败",
+			zap.String("username", req.Username),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败"})
+		return
+	}
+	if usernameExists {
+		h.logger.Warn("用户注册失败：用户名已存在",
 			zap.String("username", req.Username),
 			zap.String("email", req.Email),
-			zap.String("verification_code", req.VerificationCode),
-			zap.String("client_ip", c.ClientIP()),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱验证码错误"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已被注册"})
+		return
+	}
+
+	// 验证并消费邮箱验证码（一次性，校验通过后立即失效）
+	if err := h.codeManager.Consume(c.Request.Context(), verification.PurposeRegister, req.Email, req.VerificationCode); err != nil {
+		switch {
+		case errors.Is(err, verification.ErrCodeInvalid),
+			errors.Is(err, verification.ErrCodeNotFound),
+			errors.Is(err, verification.ErrTooManyAttempts):
+			h.logger.Warn("用户注册失败：邮箱验证码错误",
+				zap.String("username", req.Username),
+				zap.String("email", req.Email),
+				zap.String("client_ip", c.ClientIP()),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("用户注册失败：校验邮箱验证码失败",
+				zap.String("email", req.Email),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败"})
+		}
 		return
 	}
 
 	// 调用服务层注册用户
 	newUser, err := h.userService.Register(&user.User{
-		Username: req.Username,
-		Password: req.Password, // 服务层会自动加密
-		Email:    req.Email,
+		Username:      req.Username,
+		Password:      req.Password, // 服务层会自动加密
+		Email:         req.Email,
+		EmailVerified: true, // 已通过邮箱验证码确认
 	})
 	if err != nil {
+		if respondPolicyError(c, "password", err) {
+			return
+		}
 		// 根据错误类型返回具体信息并记录日志
 		switch err {
 		case user.ErrUsernameExists:
@@ -231,24 +606,90 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	})
 }
 
-// validateEmailVerificationCode 验证邮箱验证码
-// 当前为模拟实现，实际项目中应该从Redis或数据库中验证
-func (h *AuthHandler) validateEmailVerificationCode(email, code string) bool {
-	// 模拟验证逻辑：只有验证码为 "123456" 时才通过
-	if code == "123456" {
-		h.logger.Debug("邮箱验证码验证通过（模拟）",
-			zap.String("email", email),
-			zap.String("code", code),
+// SendEmailCode 发送邮箱验证码
+// @Summary 发送邮箱验证码
+// @Description 生成6位数字验证码并发送到指定邮箱，验证码只能使用一次且尝试次数有限，同一邮箱的发送频率受限；
+// @Description 注册用途下邮箱已被注册时改为发送提醒邮件，响应相同
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body SendEmailCodeRequest true "邮箱及验证码用途"
+// @Success 200 {object} gin.H{message:string, expires_in:int64}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 429 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/email/code [post]
+func (h *AuthHandler) SendEmailCode(c *gin.Context) {
+	var req SendEmailCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+	if req.Purpose == "" {
+		req.Purpose = verification.PurposeRegister
+	}
+
+	code, err := h.codeManager.Issue(c.Request.Context(), req.Purpose, req.Email)
+	if err != nil {
+		if errors.Is(err, verification.ErrResendTooSoon) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("发送邮箱验证码失败：生成验证码失败",
+			zap.String("email", req.Email),
+			zap.Error(err),
 		)
-		return true
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送验证码失败"})
+		return
 	}
 
-	// 记录验证失败的日志（用于安全审计）
-	h.logger.Debug("邮箱验证码验证失败（模拟）",
-		zap.String("email", email),
-		zap.String("code", code),
+	minutes := int(h.codeManager.TTL().Minutes())
+	msg := &mail.Message{
+		To:      req.Email,
+		Subject: "邮箱验证码",
+		Body:    fmt.Sprintf("您的验证码是：%s\n\n验证码 %d 分钟内有效，请勿泄露给他人。如非本人操作，请忽略本邮件。\n", code, minutes),
+	}
+
+	// 注册验证码：邮箱已被注册时改为发送提醒，响应与未注册时完全相同，接口不能用于探测邮箱是否已注册
+	// 验证码同样生成，发送频率限制对两种情况一致
+	if req.Purpose == verification.PurposeRegister {
+		exists, err := h.userService.EmailExists(req.Email)
+		if err != nil {
+			h.logger.Error("发送邮箱验证码失败：查询邮箱失败",
+				zap.String("email", req.Email),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "发送验证码失败"})
+			return
+		}
+		if exists {
+			msg = &mail.Message{
+				To:      req.Email,
+				Subject: "该邮箱已注册",
+				Body:    "您好：\n\n有人使用本邮箱申请注册账号，但本邮箱已经注册过。如果是您本人操作，请直接登录；忘记密码时可通过“忘记密码”重置。\n\n如非本人操作，请忽略本邮件。\n",
+			}
+		}
+	}
+
+	if err := h.mailSender.Send(c.Request.Context(), msg); err != nil {
+		h.logger.Error("发送邮箱验证码失败：发送邮件失败",
+			zap.String("email", req.Email),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送验证码失败"})
+		return
+	}
+
+	h.logger.Info("邮箱验证码已发送",
+		zap.String("email", req.Email),
+		zap.String("purpose", req.Purpose),
+		zap.String("client_ip", c.ClientIP()),
 	)
-	return false
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "验证码已发送",
+		"expires_in": int64(h.codeManager.TTL().Seconds()),
+	})
 }
 
 // GetCurrentUser 获取当前登录用户信息
@@ -297,3 +738,119 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 		CreatedAt: u.CreatedAt,
 	})
 }
+
+// Logout 注销当前令牌
+// @Summary 注销
+// @Description 吊销当前访问令牌及其所属的登录会话（包括该会话的刷新令牌）
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body LogoutRequest false "注销参数"
+// @Success 200 {object} gin.H{message:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("注销失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	// 请求体可选，解析失败时仅吊销访问令牌
+	var req LogoutRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := h.tokenManager.RevokeAccessToken(c.Request.Context(), claims); err != nil {
+		h.logger.Error("注销失败：吊销访问令牌失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销失败"})
+		return
+	}
+
+	if claims.SessionID != "" {
+		if err := revokeUserSession(c, h.sessionManager, h.tokenManager, claims.UserID, claims.SessionID); err != nil {
+			h.logger.Error("注销失败：吊销登录会话失败",
+				zap.Uint("user_id", claims.UserID),
+				zap.String("session_id", claims.SessionID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "注销失败"})
+			return
+		}
+	} else if req.RefreshToken != "" {
+		if err := h.tokenManager.RevokeRefreshToken(c.Request.Context(), req.RefreshToken, claims.UserID); err != nil && !errors.Is(err, token.ErrRefreshTokenInvalid) {
+			h.logger.Error("注销失败：吊销刷新令牌失败",
+				zap.Uint("user_id", claims.UserID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "注销失败"})
+			return
+		}
+	}
+
+	h.logger.Info("用户注销成功",
+		zap.Uint("user_id", claims.UserID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "注销成功"})
+}
+
+// LogoutAll 注销全部设备
+// @Summary 注销全部设备
+// @Description 使当前用户此前签发的所有访问令牌和刷新令牌失效
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} gin.H{message:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("注销全部设备失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	if err := h.tokenManager.RevokeAllForUser(c.Request.Context(), claims.UserID); err != nil {
+		h.logger.Error("注销全部设备失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销失败"})
+		return
+	}
+
+	// 令牌已通过代数失效，会话记录仅用于展示，删除失败不影响结果
+	if _, err := h.sessionManager.DeleteAllUserSessions(c.Request.Context(), claims.UserID); err != nil {
+		h.logger.Warn("删除登录会话失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+	}
+
+	h.logger.Info("用户注销全部设备成功",
+		zap.Uint("user_id", claims.UserID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "已注销全部设备"})
+}
+
+// currentClaims 从上下文获取JWT中间件解析出的载荷
+func currentClaims(c *gin.Context) (*jwt.Claims, bool) {
+	value, exists := c.Get("claims")
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*jwt.Claims)
+	return claims, ok
+}
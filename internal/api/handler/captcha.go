@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/captcha"
+	"auth-service/pkg/logger"
+)
+
+// LoginCaptchaQuery 查询登录是否需要人机验证的参数
+type LoginCaptchaQuery struct {
+	Username string `form:"username" binding:"required,min=3,max=20"`
+	DeviceID string `form:"device_id" binding:"max=64"` // 可选：上次登录返回的设备标识
+}
+
+// LoginCaptcha 查询登录是否需要人机验证
+// @Summary 查询登录是否需要人机验证
+// @Description 前端在展示登录表单前调用，captcha_required 为 true 时需按 provider 渲染对应的人机验证组件，并在登录请求中以 captcha_token 携带令牌；查询本身不计入请求频率
+// @Tags auth
+// @Produce json
+// @Param username query string true "用户名"
+// @Param device_id query string false "上次登录返回的设备标识"
+// @Success 200 {object} gin.H{captcha_required:bool, provider:string, site_key:string}
+// @Failure 400 {object} gin.H{error:string}
+// @Router /auth/login/captcha [get]
+func (h *AuthHandler) LoginCaptcha(c *gin.Context) {
+	var query LoginCaptchaQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	required := h.captchaRequired(c, query.Username, query.DeviceID)
+	resp := gin.H{"captcha_required": required}
+	if required {
+		resp["provider"] = h.captchaVerifier.Provider()
+		resp["site_key"] = h.captchaVerifier.GetSiteKey()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CaptchaChallenge 获取人机验证挑战
+// @Summary 获取人机验证挑战
+// @Description 内置人机验证方式（滑块、工作量证明）需要先获取挑战，完成后将结果作为 captcha_token 随登录请求提交；第三方服务商不需要调用
+// @Tags auth
+// @Produce json
+// @Success 200 {object} captcha.SliderChallenge "provider 为 slider 时"
+// @Success 200 {object} captcha.PoWChallenge "provider 为 pow 时"
+// @Failure 404 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/captcha/challenge [get]
+func (h *AuthHandler) CaptchaChallenge(c *gin.Context) {
+	challenger, ok := h.captchaVerifier.(captcha.Challenger)
+	if !ok || !h.captchaVerifier.IsEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "当前人机验证方式不需要获取挑战"})
+		return
+	}
+
+	challenge, err := challenger.IssueChallenge(c.Request.Context(), c.ClientIP())
+	if err != nil {
+		h.logger.Error("生成人机验证挑战失败",
+			zap.String("provider", h.captchaVerifier.Provider()),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成人机验证挑战失败"})
+		return
+	}
+	c.JSON(http.StatusOK, challenge)
+}
+
+// captchaRequired 判断本次登录是否需要人机验证；评估失败时要求验证
+func (h *AuthHandler) captchaRequired(c *gin.Context, username, deviceID string) bool {
+	if !h.captchaVerifier.IsEnabled() {
+		return false
+	}
+
+	assessment, err := h.captchaAssessor.Assess(c.Request.Context(), captcha.LoginSignals{
+		IP:       c.ClientIP(),
+		Username: username,
+		DeviceID: deviceID,
+	})
+	if err != nil {
+		h.logger.Warn("评估登录风险失败，要求人机验证",
+			zap.String("username", username),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		return true
+	}
+	if assessment.CaptchaRequired {
+		h.logger.Debug("登录需要人机验证",
+			zap.String("username", username),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Strings("reasons", assessment.Reasons),
+		)
+	}
+	return assessment.CaptchaRequired
+}
+
+// respondLoginFailed 记录登录失败并返回认证失败，同时告知下次登录是否需要人机验证
+func (h *AuthHandler) respondLoginFailed(c *gin.Context, username, deviceID string) {
+	if err := h.captchaAssessor.RecordFailure(c.Request.Context(), c.ClientIP(), username); err != nil {
+		h.logger.Warn("记录登录失败统计失败",
+			zap.String("username", username),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":            "用户名或密码错误",
+		"captcha_required": h.captchaRequired(c, username, deviceID),
+	})
+}
+
+// recordLoginSuccess 全部认证因子验证通过后信任该设备并清除账号的登录失败次数，返回设备标识
+// 已启用两步验证的账号须在第二因子通过后调用，只知道密码不能让设备免人机验证或清除锁定计数
+func recordLoginSuccess(c *gin.Context, assessor *captcha.Assessor, loginLockout *loginLockout, logger *logger.ZapLogger, u *user.User, deviceID string) string {
+	deviceID, err := assessor.RecordSuccess(c.Request.Context(), u.Username, deviceID)
+	if err != nil {
+		logger.Warn("记录可信设备失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+	}
+	loginLockout.resetFailures(c, u.ID)
+	return deviceID
+}
@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/session"
+)
+
+// pendingLinkCookie 待确认关联的 cookie，只存在于完成外部登录的浏览器中，
+// 防止诱导其他已登录用户确认关联攻击者的外部账号
+const pendingLinkCookie = "oauth_pending_link"
+
+// LinkProvider 可关联的外部登录方式
+type LinkProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// ListIdentities 查询已关联的外部账号
+// @Summary 查询已关联的外部账号
+// @Description 返回当前用户关联的外部账号和可关联的登录方式
+// @Tags user
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} gin.H{identities:[]user.UserIdentity, providers:[]LinkProvider}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/user/identities [get]
+func (h *OAuth2Handler) ListIdentities(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("查询外部账号失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	identities, err := h.userService.ListIdentities(claims.UserID)
+	if err != nil {
+		h.logger.Error("查询外部账号失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询外部账号失败"})
+		return
+	}
+
+	providers := []LinkProvider{{Name: user.ProviderGitHub, DisplayName: "GitHub"}}
+	for name, provider := range h.oidcProviders {
+		providers = append(providers, LinkProvider{Name: name, DisplayName: provider.DisplayName()})
+	}
+	sort.Slice(providers[1:], func(i, j int) bool { return providers[i+1].Name < providers[j+1].Name })
+
+	c.JSON(http.StatusOK, gin.H{
+		"identities": identities,
+		"providers":  providers,
+	})
+}
+
+// LinkIdentity 发起关联外部账号
+// @Summary 发起关联外部账号
+// @Description 返回提供方的授权地址，前端跳转完成授权后回到 ui.link_result_path；同一外部账号只能关联一个用户
+// @Tags user
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "提供方名称：github 或配置中的 OpenID Connect 提供方"
+// @Success 200 {object} gin.H{authorization_url:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 404 {object} gin.H{error:string}
+// @Failure 502 {object} gin.H{error:string}
+// @Router /auth/user/identities/{provider} [post]
+func (h *OAuth2Handler) LinkIdentity(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("关联外部账号失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	provider := c.Param("provider")
+	if _, ok := h.oidcProviders[provider]; !ok && provider != user.ProviderGitHub {
+		c.JSON(http.StatusNotFound, gin.H{"error": "不支持的登录方式"})
+		return
+	}
+
+	authURL, sessionID, ok := h.beginAuthorization(c, provider, claims.UserID)
+	if !ok {
+		return
+	}
+
+	h.logger.Info("发起关联外部账号",
+		zap.Uint("user_id", claims.UserID),
+		zap.String("provider", provider),
+		zap.String("session_id", sessionID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// UnlinkIdentity 解除外部账号关联
+// @Summary 解除外部账号关联
+// @Description 账号未设置密码、没有可用于免密登录的通行密钥且这是唯一关联的外部账号时拒绝，避免账号无法登录
+// @Tags user
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "外部账号ID"
+// @Success 200 {object} gin.H{message:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 404 {object} gin.H{error:string}
+// @Failure 409 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/user/identities/{id} [delete]
+func (h *OAuth2Handler) UnlinkIdentity(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("解除外部账号关联失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": user.ErrIdentityNotFound.Error()})
+		return
+	}
+
+	if err := h.userService.UnlinkIdentity(claims.UserID, uint(id)); err != nil {
+		switch {
+		case errors.Is(err, user.ErrIdentityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, user.ErrLastLoginMethod):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("解除外部账号关联失败",
+				zap.Uint("user_id", claims.UserID),
+				zap.Uint64("identity_id", id),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "解除关联失败"})
+		}
+		return
+	}
+
+	h.logger.Info("解除外部账号关联",
+		zap.Uint("user_id", claims.UserID),
+		zap.Uint64("identity_id", id),
+	)
+	c.JSON(http.StatusOK, gin.H{"message": "已解除关联"})
+}
+
+// GetPendingLink 查询待确认的外部账号关联
+// @Summary 查询待确认的外部账号关联
+// @Description 外部账号的邮箱与已有用户一致但未经双方验证时，用户需登录原账号后确认；只能查询本浏览器发起的关联
+// @Tags user
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} gin.H{provider:string, email:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 404 {object} gin.H{error:string}
+// @Router /auth/user/identities/pending [get]
+func (h *OAuth2Handler) GetPendingLink(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("查询待确认关联失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	_, link, ok := h.pendingLink(c, claims.UserID)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"provider": link.Provider,
+		"email":    link.Email,
+	})
+}
+
+// ConfirmLink 确认关联外部账号
+// @Summary 确认关联外部账号
+// @Description 将本浏览器最近一次外部登录得到的账号关联到当前用户，待确认的关联必须属于当前用户
+// @Tags user
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} user.UserIdentity
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 404 {object} gin.H{error:string}
+// @Failure 409 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/user/identities/confirm [post]
+func (h *OAuth2Handler) ConfirmLink(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("确认关联外部账号失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	linkID, link, ok := h.pendingLink(c, claims.UserID)
+	if !ok {
+		return
+	}
+
+	var account user.ExternalAccount
+	if err := json.Unmarshal(link.Account, &account); err != nil {
+		h.logger.Error("解析待确认关联失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "确认关联失败"})
+		return
+	}
+
+	// 一次性使用
+	if err := h.sessionManager.DeletePendingLink(c.Request.Context(), linkID); err != nil {
+		h.logger.Warn("删除待确认关联失败", zap.Error(err))
+	}
+	c.SetCookie(pendingLinkCookie, "", -1, "/", "", false, true)
+
+	identity, err := h.userService.LinkIdentity(claims.UserID, &account)
+	if err != nil {
+		if errors.Is(err, user.ErrIdentityLinked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("确认关联外部账号失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.String("provider", account.Provider),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "确认关联失败"})
+		return
+	}
+
+	h.logger.Info("确认关联外部账号",
+		zap.Uint("user_id", claims.UserID),
+		zap.String("provider", account.Provider),
+		zap.String("subject", account.Subject),
+	)
+	c.JSON(http.StatusOK, identity)
+}
+
+// pendingLink 读取本浏览器的待确认关联，并检查其属于当前用户；失败时已返回错误响应
+func (h *OAuth2Handler) pendingLink(c *gin.Context, userID uint) (string, *session.PendingLink, bool) {
+	linkID, err := c.Cookie(pendingLinkCookie)
+	if err != nil || linkID == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有待确认的关联或已过期"})
+		return "", nil, false
+	}
+
+	link, err := h.sessionManager.GetPendingLink(c.Request.Context(), linkID)
+	if err != nil || link.UserID != userID {
+		if err == nil {
+			h.logger.Warn("待确认关联不属于当前用户",
+				zap.Uint("user_id", userID),
+				zap.Uint("link_user_id", link.UserID),
+				zap.String("client_ip", c.ClientIP()),
+			)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有待确认的关联或已过期"})
+		return "", nil, false
+	}
+	return linkID, link, true
+}
+
+// completeLink 关联账号流程的回调：将外部账号关联到发起的用户，并重定向到前端结果页面
+func (h *OAuth2Handler) completeLink(c *gin.Context, userID uint, account *user.ExternalAccount, sessionID string) {
+	if _, err := h.userService.LinkIdentity(userID, account); err != nil {
+		h.logger.Warn("关联外部账号失败",
+			zap.Uint("user_id", userID),
+			zap.String("provider", account.Provider),
+			zap.String("subject", account.Subject),
+			zap.String("session_id", sessionID),
+			zap.Error(err),
+		)
+		message := "关联失败"
+		if errors.Is(err, user.ErrIdentityLinked) {
+			message = err.Error()
+		}
+		h.redirectToUI(c, h.config.UI.LinkResultPath, url.Values{"provider": {account.Provider}, "error": {message}})
+		return
+	}
+
+	h.logger.Info("关联外部账号成功",
+		zap.Uint("user_id", userID),
+		zap.String("provider", account.Provider),
+		zap.String("subject", account.Subject),
+		zap.String("session_id", sessionID),
+	)
+	h.redirectToUI(c, h.config.UI.LinkResultPath, url.Values{"provider": {account.Provider}, "linked": {"true"}})
+}
+
+// requireLinkConfirmation 外部账号的邮箱与已有用户一致但未经双方验证：保存待确认关联，
+// 重定向到确认页面，用户登录原账号后调用 ConfirmLink 完成关联
+func (h *OAuth2Handler) requireLinkConfirmation(c *gin.Context, userID uint, account *user.ExternalAccount, sessionID string) {
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		h.logger.Error("序列化外部账号失败", zap.Error(err))
+		h.redirectToError(c, "用户登录失败")
+		return
+	}
+
+	linkID, err := h.sessionManager.CreatePendingLink(c.Request.Context(), session.PendingLink{
+		UserID:   userID,
+		Provider: account.Provider,
+		Email:    account.Email,
+		Account:  accountJSON,
+	})
+	if err != nil {
+		h.logger.Error("保存待确认关联失败", zap.Error(err))
+		h.redirectToError(c, "用户登录失败")
+		return
+	}
+	c.SetCookie(pendingLinkCookie, linkID, 600, "/", "", false, true) // 10分钟有效期
+
+	h.logger.Info("外部账号邮箱与已有用户一致，等待确认关联",
+		zap.Uint("user_id", userID),
+		zap.String("provider", account.Provider),
+		zap.String("subject", account.Subject),
+		zap.Bool("email_verified", account.EmailVerified),
+		zap.String("session_id", sessionID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	h.redirectToUI(c, h.config.UI.LinkConfirmPath, url.Values{"provider": {account.Provider}, "email": {account.Email}})
+}
+
+// redirectToUI 重定向到前端页面
+func (h *OAuth2Handler) redirectToUI(c *gin.Context, path string, query url.Values) {
+	c.Redirect(http.StatusTemporaryRedirect, h.config.UI.BaseURL+path+"?"+query.Encode())
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/pkg/logger"
+	"auth-service/pkg/token"
+)
+
+// IntrospectionHandler 令牌内省与吊销处理器，供 API 网关等资源服务器调用
+type IntrospectionHandler struct {
+	tokenManager *token.Manager
+	logger       *logger.ZapLogger
+}
+
+// NewIntrospectionHandler 创建令牌内省与吊销处理器实例
+func NewIntrospectionHandler(tokenManager *token.Manager, logger *logger.ZapLogger) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		tokenManager: tokenManager,
+		logger:       logger,
+	}
+}
+
+// Introspect 令牌内省（RFC 7662）
+// @Summary 令牌内省
+// @Description 资源服务器查询访问令牌或刷新令牌的状态，已吊销的令牌返回 active=false
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Security BasicAuth
+// @Param token formData string true "待查询的令牌"
+// @Param token_type_hint formData string false "令牌类型提示：access_token 或 refresh_token"
+// @Success 200 {object} token.Introspection
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Router /oauth2/introspect [post]
+func (h *IntrospectionHandler) Introspect(c *gin.Context) {
+	tokenString := c.PostForm("token")
+	if tokenString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	result, err := h.tokenManager.Introspect(c.Request.Context(), tokenString, c.PostForm("token_type_hint"))
+	if err != nil {
+		h.logger.Error("令牌内省失败",
+			zap.String("client_id", c.GetString("clientID")),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Revoke 令牌吊销（RFC 7009）
+// @Summary 令牌吊销
+// @Description 吊销 aud 包含当前客户端的访问令牌；刷新令牌和签发给其他客户端的令牌返回 unauthorized_client（持有者通过 /auth/logout 注销）；无效的令牌同样返回 200
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Security BasicAuth
+// @Param token formData string true "待吊销的令牌"
+// @Param token_type_hint formData string false "令牌类型提示：access_token 或 refresh_token"
+// @Success 200
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Router /oauth2/revoke [post]
+func (h *IntrospectionHandler) Revoke(c *gin.Context) {
+	tokenString := c.PostForm("token")
+	if tokenString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	if err := h.tokenManager.Revoke(c.Request.Context(), tokenString, c.GetString("clientID")); err != nil {
+		if errors.Is(err, token.ErrRevokeNotAllowed) {
+			h.logger.Warn("令牌吊销被拒绝：令牌不是签发给该客户端的",
+				zap.String("client_id", c.GetString("clientID")),
+				zap.String("client_ip", c.ClientIP()),
+			)
+			// RFC 7009 2.1：令牌不是签发给请求方的，拒绝请求
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized_client"})
+			return
+		}
+		h.logger.Error("令牌吊销失败",
+			zap.String("client_id", c.GetString("clientID")),
+			zap.Error(err),
+		)
+		// RFC 7009 2.2.1：服务暂时无法处理时返回 503，客户端可稍后重试
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable"})
+		return
+	}
+
+	h.logger.Info("令牌已吊销",
+		zap.String("client_id", c.GetString("clientID")),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/lockout"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/mail"
+	"auth-service/pkg/session"
+	"auth-service/pkg/token"
+)
+
+// ipLockedOut 检查客户端 IP 是否因登录失败过多被锁定，已锁定时写入响应并返回 true
+// 查询失败时放行，避免 Redis 抖动导致所有用户无法登录
+func (h *AuthHandler) ipLockedOut(c *gin.Context) bool {
+	st, err := h.loginLockout.manager.CheckIP(c.Request.Context(), c.ClientIP())
+	if err != nil {
+		h.logger.Warn("查询 IP 锁定状态失败",
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		return false
+	}
+	if !st.Locked {
+		return false
+	}
+
+	h.logger.Warn("用户登录失败：IP 已锁定",
+		zap.String("client_ip", c.ClientIP()),
+		zap.Duration("retry_after", st.RetryAfter),
+	)
+	retryAfter := ceilSeconds(st.RetryAfter)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "登录失败次数过多，请稍后再试", "retry_after": retryAfter})
+	return true
+}
+
+// loginLockout 账号登录失败锁定的检查与记录
+// 密码登录、两步验证、通行密钥、外部账号登录和刷新令牌等签发令牌的入口共用，避免锁定只对密码登录生效
+type loginLockout struct {
+	manager        *lockout.Manager
+	sessionManager *session.Manager
+	tokenManager   *token.Manager
+	mailSender     mail.Sender
+	logger         *logger.ZapLogger
+}
+
+func newLoginLockout(manager *lockout.Manager, sessionManager *session.Manager, tokenManager *token.Manager, mailSender mail.Sender, logger *logger.ZapLogger) *loginLockout {
+	return &loginLockout{
+		manager:        manager,
+		sessionManager: sessionManager,
+		tokenManager:   tokenManager,
+		mailSender:     mailSender,
+		logger:         logger,
+	}
+}
+
+// accountLocked 查询账号是否被锁定，已锁定时返回锁定状态，由调用方决定如何响应
+// 查询失败时放行，避免 Redis 抖动导致所有用户无法登录
+func (l *loginLockout) accountLocked(c *gin.Context, userID uint) *lockout.Status {
+	st, err := l.manager.CheckAccount(c.Request.Context(), userID)
+	if err != nil {
+		l.logger.Warn("查询账号锁定状态失败",
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+		return nil
+	}
+	if !st.Locked {
+		return nil
+	}
+
+	l.logger.Warn("拒绝签发令牌：账号已锁定",
+		zap.Uint("user_id", userID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.String("path", c.FullPath()),
+		zap.Bool("permanent", st.Permanent),
+	)
+	return st
+}
+
+// recordFailure 记录一次登录失败（u 为 nil 表示用户名不存在，只统计 IP），返回账号的锁定状态
+// 本次失败导致账号被锁定时通知用户；永久锁定时同时吊销该账号的全部令牌和会话
+func (l *loginLockout) recordFailure(c *gin.Context, u *user.User) *lockout.Status {
+	ctx := c.Request.Context()
+	if _, err := l.manager.RecordIPFailure(ctx, c.ClientIP()); err != nil {
+		l.logger.Warn("记录 IP 登录失败次数失败",
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+	}
+	if u == nil {
+		return nil
+	}
+
+	st, err := l.manager.RecordAccountFailure(ctx, u.ID)
+	if err != nil {
+		l.logger.Warn("记录账号登录失败次数失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		return nil
+	}
+	if !st.Locked {
+		return st
+	}
+
+	l.logger.Warn("账号因登录失败次数过多被锁定",
+		zap.Uint("user_id", u.ID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.Bool("permanent", st.Permanent),
+		zap.Duration("duration", st.RetryAfter),
+	)
+	if st.Permanent {
+		// 永久锁定前签发的令牌同样失效，需重置密码或管理员解锁后重新登录
+		if err := l.tokenManager.RevokeAllForUser(ctx, u.ID); err != nil {
+			l.logger.Error("永久锁定后吊销令牌失败",
+				zap.Uint("user_id", u.ID),
+				zap.Error(err),
+			)
+		}
+		if _, err := l.sessionManager.DeleteAllUserSessions(ctx, u.ID); err != nil {
+			l.logger.Warn("删除登录会话失败",
+				zap.Uint("user_id", u.ID),
+				zap.Error(err),
+			)
+		}
+	}
+	if u.Email != "" {
+		go l.sendNotice(*u, st, c.ClientIP())
+	}
+	return st
+}
+
+// resetFailures 登录成功后清除账号的失败次数，失败只记录日志
+func (l *loginLockout) resetFailures(c *gin.Context, userID uint) {
+	if err := l.manager.ResetFailures(c.Request.Context(), userID); err != nil {
+		l.logger.Warn("清除登录失败次数失败",
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+	}
+}
+
+// sendNotice 通知用户账号已被锁定（异步调用，失败只记录日志）
+func (l *loginLockout) sendNotice(u user.User, st *lockout.Status, clientIP string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	detail := fmt.Sprintf("账号已被临时锁定 %s，到期后可再次登录。", formatLockDuration(st.RetryAfter))
+	if st.Permanent {
+		detail = "由于多次触发锁定，账号已被锁定，所有设备均已退出登录，请通过“忘记密码”重置密码后登录，或联系管理员解锁。"
+	}
+	if err := l.mailSender.Send(ctx, &mail.Message{
+		To:      u.Email,
+		Subject: "账号已被锁定",
+		Body: fmt.Sprintf("%s，您好：\n\n您的账号于 %s 连续多次登录失败（最近一次来自 IP %s），%s\n\n如非本人操作，说明有人正在尝试登录您的账号，建议尽快修改密码并开启两步验证。\n",
+			u.Username, time.Now().Format("2006-01-02 15:04:05"), clientIP, detail),
+	}); err != nil {
+		l.logger.Warn("发送账号锁定提醒邮件失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// stepUpLocked 已登录用户的敏感操作（添加通行密钥、管理两步验证、修改密码）校验当前凭证前检查账号锁定，
+// 已锁定时写入 423 响应并返回 true
+func (l *loginLockout) stepUpLocked(c *gin.Context, userID uint) bool {
+	st := l.accountLocked(c, userID)
+	if st == nil {
+		return false
+	}
+	respondLockedOut(c, st)
+	return true
+}
+
+// stepUpFailed 记录敏感操作中提交的错误凭证，与登录失败共用计数，持有访问令牌也不能无限次猜测密码或验证码
+// 本次失败导致账号锁定时写入 423 响应，否则写入 401 响应
+func (l *loginLockout) stepUpFailed(c *gin.Context, u *user.User, msg string) {
+	if st := l.recordFailure(c, u); st != nil && st.Locked {
+		respondLockedOut(c, st)
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+}
+
+// respondLockedOut 返回账号锁定响应（调用方已证明持有账号凭证时使用，密码登录接口统一返回认证失败）
+func respondLockedOut(c *gin.Context, st *lockout.Status) {
+	if st.Permanent {
+		c.JSON(http.StatusLocked, gin.H{"error": "账号已被锁定，请重置密码或联系管理员解锁"})
+		return
+	}
+	retryAfter := ceilSeconds(st.RetryAfter)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusLocked, gin.H{
+		"error":       fmt.Sprintf("登录失败次数过多，账号已临时锁定，请 %s后再试", formatLockDuration(st.RetryAfter)),
+		"retry_after": retryAfter,
+	})
+}
+
+// formatLockDuration 将锁定时长格式化为“N 分钟”或“N 秒”
+func formatLockDuration(d time.Duration) string {
+	if d >= time.Minute {
+		return fmt.Sprintf("%d 分钟", int(math.Ceil(d.Minutes())))
+	}
+	return fmt.Sprintf("%d 秒", ceilSeconds(d))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/internal/config"
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/lockout"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/mail"
+	"auth-service/pkg/session"
+	"auth-service/pkg/token"
+	"auth-service/pkg/totp"
+)
+
+// MFACodeRequest 两步验证码请求参数结构体
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required,min=6,max=32"` // 6位 TOTP 验证码（关闭两步验证时也可使用恢复码）
+}
+
+// MFAEnrollRequest 绑定 TOTP 请求参数结构体
+type MFAEnrollRequest struct {
+	Code string `json:"code" binding:"omitempty,min=6,max=32"` // 已启用两步验证时（重新绑定）必填：当前的 TOTP 验证码或恢复码
+}
+
+// MFAHandler 两步验证管理处理器
+type MFAHandler struct {
+	userService  *user.Service
+	loginLockout *loginLockout // 验证码错误与登录失败共用锁定计数
+	config       *config.Config
+	logger       *logger.ZapLogger
+}
+
+// NewMFAHandler 创建两步验证管理处理器实例
+func NewMFAHandler(userService *user.Service, lockoutManager *lockout.Manager, sessionManager *session.Manager, tokenManager *token.Manager, mailSender mail.Sender, cfg *config.Config, logger *logger.ZapLogger) *MFAHandler {
+	return &MFAHandler{
+		userService:  userService,
+		loginLockout: newLoginLockout(lockoutManager, sessionManager, tokenManager, mailSender, logger),
+		config:       cfg,
+		logger:       logger,
+	}
+}
+
+// Status 查询两步验证状态
+// @Summary 查询两步验证状态
+// @Description 返回当前用户是否已启用 TOTP 两步验证及剩余恢复码数量
+// @Tags mfa
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} gin.H{totp_enabled:bool, recovery_codes_remaining:int64}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/mfa [get]
+func (h *MFAHandler) Status(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("查询两步验证状态失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	u, err := h.userService.GetByID(claims.UserID)
+	if err != nil {
+		h.logger.Error("查询两步验证状态失败：查询用户失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询两步验证状态失败"})
+		return
+	}
+
+	var remaining int64
+	if u.TOTPEnabled {
+		remaining, err = h.userService.CountRecoveryCodes(u.ID)
+		if err != nil {
+			h.logger.Error("查询两步验证状态失败：查询恢复码失败",
+				zap.Uint("user_id", u.ID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询两步验证状态失败"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"totp_enabled":             u.TOTPEnabled,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// EnrollTOTP 发起 TOTP 绑定
+// @Summary 绑定 TOTP
+// @Description 生成新的 TOTP 密钥，返回 otpauth:// 链接和二维码，需调用确认接口后才生效。
+// @Description 已启用两步验证时为重新绑定（更换认证器），需提交当前的验证码或恢复码，错误计入账号的登录失败次数，确认前原密钥继续有效
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFAEnrollRequest false "当前验证码（重新绑定时）"
+// @Success 200 {object} gin.H{secret:string, otpauth_url:string, qr_code:string}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Failure 429 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/mfa/totp/enroll [post]
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("绑定TOTP失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	// 首次绑定时请求体可以为空
+	var req MFAEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+	if req.Code != "" && h.loginLockout.stepUpLocked(c, claims.UserID) {
+		return
+	}
+
+	enrollment, err := h.userService.BeginTOTPEnrollment(claims.UserID, h.config.MFA.Issuer, req.Code)
+	if err != nil {
+		h.respondMFAError(c, "绑定两步验证失败", claims.UserID, err)
+		return
+	}
+
+	png, err := totp.QRCodePNG(enrollment.URI, 256)
+	if err != nil {
+		h.logger.Error("绑定TOTP失败：生成二维码失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "绑定两步验证失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      enrollment.Secret,
+		"otpauth_url": enrollment.URI,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// ConfirmTOTP 确认 TOTP 绑定
+// @Summary 确认绑定 TOTP
+// @Description 提交认证器应用生成的第一个验证码以启用两步验证，返回一次性恢复码（仅返回一次，请妥善保存）
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "TOTP 验证码"
+// @Success 200 {object} gin.H{message:string, recovery_codes:[]string}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 409 {object} gin.H{error:string}
+// @Router /auth/mfa/totp/confirm [post]
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("确认TOTP绑定失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	codes, err := h.userService.ConfirmTOTPEnrollment(claims.UserID, req.Code)
+	if err != nil {
+		// 待确认的密钥只有发起绑定的一方知道，验证码输错不计入登录失败次数
+		if errors.Is(err, user.ErrMFACodeInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		h.respondMFAError(c, "确认TOTP绑定失败", claims.UserID, err)
+		return
+	}
+
+	h.logger.Info("用户已启用两步验证",
+		zap.Uint("user_id", claims.UserID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "两步验证已启用",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 提交有效的 TOTP 验证码或恢复码以关闭两步验证，所有恢复码随之作废；验证码错误计入账号的登录失败次数
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "TOTP 验证码或恢复码"
+// @Success 200 {object} gin.H{message:string}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Failure 429 {object} gin.H{error:string}
+// @Router /auth/mfa/totp/disable [post]
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("关闭两步验证失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	if h.loginLockout.stepUpLocked(c, claims.UserID) {
+		return
+	}
+
+	if err := h.userService.DisableTOTP(claims.UserID, req.Code); err != nil {
+		h.respondMFAError(c, "关闭两步验证失败", claims.UserID, err)
+		return
+	}
+
+	h.logger.Info("用户已关闭两步验证",
+		zap.Uint("user_id", claims.UserID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 提交有效的 TOTP 验证码以重新生成恢复码，旧恢复码全部作废；验证码错误计入账号的登录失败次数
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "TOTP 验证码"
+// @Success 200 {object} gin.H{recovery_codes:[]string}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Failure 429 {object} gin.H{error:string}
+// @Router /auth/mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("重新生成恢复码失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	if h.loginLockout.stepUpLocked(c, claims.UserID) {
+		return
+	}
+
+	codes, err := h.userService.RegenerateRecoveryCodes(claims.UserID, req.Code)
+	if err != nil {
+		h.respondMFAError(c, "重新生成恢复码失败", claims.UserID, err)
+		return
+	}
+
+	h.logger.Info("用户已重新生成恢复码",
+		zap.Uint("user_id", claims.UserID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// respondMFAError 将两步验证领域错误映射为 HTTP 响应
+// 验证码错误计入账号的登录失败次数，持有访问令牌也不能暴力猜测验证码后关闭两步验证或生成恢复码
+func (h *MFAHandler) respondMFAError(c *gin.Context, msg string, userID uint, err error) {
+	switch {
+	case errors.Is(err, user.ErrMFACodeInvalid):
+		h.logger.Warn(msg+"：验证码错误",
+			zap.Uint("user_id", userID),
+			zap.String("client_ip", c.ClientIP()),
+		)
+		u, getErr := h.userService.GetByID(userID)
+		if getErr != nil {
+			h.logger.Error(msg+"：查询用户失败",
+				zap.Uint("user_id", userID),
+				zap.Error(getErr),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		h.loginLockout.stepUpFailed(c, u, err.Error())
+	case errors.Is(err, user.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrMFANotEnabled), errors.Is(err, user.ErrMFAEnrollmentNotFound), errors.Is(err, user.ErrMFACodeRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg,
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	}
+}
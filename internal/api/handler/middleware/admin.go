@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 管理接口认证中间件
+// 通过 X-Admin-Key 请求头校验管理密钥，未配置密钥时拒绝所有请求
+func AdminAuth(apiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "管理接口未启用"})
+			c.Abort()
+			return
+		}
+
+		// 使用常量时间比较，防止时序攻击
+		provided := c.GetHeader("X-Admin-Key")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "管理密钥无效"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"auth-service/internal/config"
+)
+
+// ClientAuth 资源服务器客户端认证中间件
+// 支持 HTTP Basic（client_secret_basic）和表单参数（client_secret_post）两种方式（RFC 6749 2.3.1）
+func ClientAuth(clients []config.ResourceServerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, clientSecret, ok := c.Request.BasicAuth()
+		if !ok {
+			clientID = c.PostForm("client_id")
+			clientSecret = c.PostForm("client_secret")
+		}
+
+		if clientID == "" || !validClient(clients, clientID, clientSecret) {
+			c.Header("WWW-Authenticate", `Basic realm="auth-service"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+			c.Abort()
+			return
+		}
+
+		c.Set("clientID", clientID)
+		c.Next()
+	}
+}
+
+// validClient 校验客户端凭证，使用常量时间比较防止时序攻击
+func validClient(clients []config.ResourceServerConfig, clientID, clientSecret string) bool {
+	for _, client := range clients {
+		if client.ClientID != clientID || client.ClientSecret == "" {
+			continue
+		}
+		return subtle.ConstantTimeCompare([]byte(client.ClientSecret), []byte(clientSecret)) == 1
+	}
+	return false
+}
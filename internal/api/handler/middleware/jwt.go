@@ -4,14 +4,14 @@ import (
 	"net/http"
 	"strings"
 
-	"auth-service/pkg/jwt"
+	"auth-service/pkg/token"
 
 	"github.com/gin-gonic/gin"
 )
 
 // JWTAuth JWT认证中间件
-// 接收JWT密钥作为参数，从配置中传入
-func JWTAuth(jwtSecret string) gin.HandlerFunc {
+// 接收令牌管理器作为参数，除校验签名外还会检查令牌是否已在服务端吊销
+func JWTAuth(tokenManager *token.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从Authorization头获取令牌
 		authHeader := c.Request.Header.Get("Authorization")
@@ -30,7 +30,7 @@ func JWTAuth(jwtSecret string) gin.HandlerFunc {
 		}
 
 		// 验证令牌并解析用户ID
-		claims, err := jwt.ParseToken(parts[1], jwtSecret) // 使用从配置传入的密钥
+		claims, err := tokenManager.ValidateAccessToken(c.Request.Context(), parts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的令牌: " + err.Error()})
 			c.Abort()
@@ -40,6 +40,7 @@ func JWTAuth(jwtSecret string) gin.HandlerFunc {
 		// 将用户信息存入上下文，供后续处理使用
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username) // 可选：也可以存储用户名
+		c.Set("claims", claims)            // 完整载荷，供注销等操作使用
 		c.Next()
 	}
 }
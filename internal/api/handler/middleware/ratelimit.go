@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/ratelimit"
+)
+
+// 限流计数维度
+const (
+	RateLimitByIP       = "ip"       // 客户端 IP（由 gin 根据可信代理配置解析）
+	RateLimitByUsername = "username" // 请求体中的 username 字段
+	RateLimitByEmail    = "email"    // 请求体中的 email 字段
+	RateLimitByUser     = "user"     // 已登录用户的ID，仅用于需认证的路由（JWT 中间件之后）
+)
+
+// maxRateLimitBody 读取请求体中计数字段时最多读取的字节数
+const maxRateLimitBody = 64 << 10
+
+// RateLimiter 按路由配置的接口限流中间件
+type RateLimiter struct {
+	limiter ratelimit.Limiter
+	routes  map[string][]config.RateLimitRule
+	logger  *logger.ZapLogger
+}
+
+// NewRateLimiter 创建限流中间件，未启用时 For 返回的中间件不做任何限制
+func NewRateLimiter(cfg *config.RateLimitConfig, limiter ratelimit.Limiter, logger *logger.ZapLogger) (*RateLimiter, error) {
+	if !cfg.Enabled {
+		return &RateLimiter{logger: logger}, nil
+	}
+	for route, rules := range cfg.Routes {
+		for i, rule := range rules {
+			if rule.Limit <= 0 || rule.Period <= 0 || rule.Burst < 0 {
+				return nil, fmt.Errorf("路由 %s 的第 %d 条限流规则无效", route, i+1)
+			}
+			if len(rule.By) == 0 {
+				return nil, fmt.Errorf("路由 %s 的第 %d 条限流规则缺少计数维度", route, i+1)
+			}
+			for _, by := range rule.By {
+				switch by {
+				case RateLimitByIP, RateLimitByUsername, RateLimitByEmail, RateLimitByUser:
+				default:
+					return nil, fmt.Errorf("路由 %s 的第 %d 条限流规则计数维度无效: %s", route, i+1, by)
+				}
+			}
+		}
+	}
+	return &RateLimiter{limiter: limiter, routes: cfg.Routes, logger: logger}, nil
+}
+
+// For 返回指定路由的限流中间件
+// 响应携带 RateLimit-Limit / RateLimit-Remaining / RateLimit-Reset 头（取最先耗尽的规则），超限时返回 429 和 Retry-After
+func (rl *RateLimiter) For(route string) gin.HandlerFunc {
+	rules := rl.routes[route]
+	if len(rules) == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	policies := make([]string, len(rules))
+	for i, rule := range rules {
+		policies[i] = fmt.Sprintf("%d;w=%d", rule.Limit, int(rule.Period.Seconds()))
+	}
+	policy := strings.Join(policies, ", ")
+
+	return func(c *gin.Context) {
+		fields := rateLimitFields(c, rules)
+
+		var tightest *ratelimit.Result
+		for i, rule := range rules {
+			key, ok := rateLimitKey(c, rule, fields)
+			if !ok {
+				// 缺少计数字段的请求会被接口的参数校验拒绝，不计数
+				continue
+			}
+
+			result, err := rl.limiter.Allow(c.Request.Context(), fmt.Sprintf("%s:%d:%s", route, i, key), ratelimit.Limit{
+				Limit:  rule.Limit,
+				Period: rule.Period,
+				Burst:  rule.Burst,
+			})
+			if err != nil {
+				// 限流存储不可用时放行，避免整个登录入口不可用
+				rl.logger.Warn("限流检查失败", zap.String("route", route), zap.Error(err))
+				continue
+			}
+			if tightest == nil || moreRestrictive(result, tightest) {
+				tightest = result
+			}
+		}
+		if tightest == nil {
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(tightest.ResetAfter)))
+		if !tightest.Allowed {
+			retryAfter := ceilSeconds(tightest.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试", "retry_after": retryAfter})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// moreRestrictive 判断 a 是否比 b 更严格：被拒绝的优先，其次等待更久或剩余更少的
+func moreRestrictive(a, b *ratelimit.Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+// rateLimitFields 规则需要按用户名或邮箱计数时，从 JSON 请求体中读取这些字段，并恢复请求体供后续处理
+func rateLimitFields(c *gin.Context, rules []config.RateLimitRule) map[string]string {
+	needed := false
+	for _, rule := range rules {
+		for _, by := range rule.By {
+			if by != RateLimitByIP && by != RateLimitByUser {
+				needed = true
+			}
+		}
+	}
+	if !needed || c.Request.Body == nil {
+		return nil
+	}
+
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRateLimitBody))
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	if err != nil {
+		return nil
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(head, &body); err != nil {
+		return nil
+	}
+	fields := make(map[string]string)
+	for _, name := range []string{RateLimitByUsername, RateLimitByEmail} {
+		if v, ok := body[name].(string); ok {
+			fields[name] = strings.ToLower(strings.TrimSpace(v))
+		}
+	}
+	return fields
+}
+
+// rateLimitKey 计算规则的计数键，各维度的值做哈希，避免在 Redis 中保存邮箱等明文
+func rateLimitKey(c *gin.Context, rule config.RateLimitRule, fields map[string]string) (string, bool) {
+	values := make([]string, 0, len(rule.By))
+	for _, by := range rule.By {
+		v := fields[by]
+		switch by {
+		case RateLimitByIP:
+			v = c.ClientIP()
+		case RateLimitByUser:
+			if userID, ok := c.Get("userID"); ok {
+				v = fmt.Sprint(userID)
+			}
+		}
+		if v == "" {
+			return "", false
+		}
+		values = append(values, v)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(values, "\n")))
+	return strings.Join(rule.By, "+") + ":" + hex.EncodeToString(sum[:16]), true
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// readCloser 组合读取已缓冲的请求体和剩余部分，关闭时关闭原始请求体
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -3,39 +3,56 @@ package handler
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"net/url"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
 	"auth-service/internal/config"
 	"auth-service/internal/domain/user"
-	"auth-service/pkg/jwt"
+	"auth-service/pkg/lockout"
 	"auth-service/pkg/logger"
+	"auth-service/pkg/mail"
 	"auth-service/pkg/oauth2"
 	"auth-service/pkg/redis"
 	"auth-service/pkg/session"
+	"auth-service/pkg/token"
 )
 
+// OAuth2TokenRequest 兑换外部账号登录码请求参数结构体
+type OAuth2TokenRequest struct {
+	Code       string `json:"code" binding:"required,max=64"` // 登录成功页面地址中的一次性登录码
+	DeviceName string `json:"device_name" binding:"max=64"`   // 可选：设备名称，为空时根据 User-Agent 推断
+}
+
 // OAuth2Handler OAuth2 认证处理器
 type OAuth2Handler struct {
 	userService    *user.Service
 	config         *config.Config
 	logger         *logger.ZapLogger
 	githubOAuth2   *oauth2.GitHubOAuth2Service
-	sessionManager *session.Manager // 新增 Session 管理器
+	oidcProviders  map[string]*oauth2.OIDCProvider // 通用 OpenID Connect 提供方，键为 provider 名称
+	sessionManager *session.Manager                // 新增 Session 管理器
+	tokenManager   *token.Manager                  // 令牌管理器
+	loginLockout   *loginLockout                   // 登录失败锁定
 }
 
 // NewOAuth2Handler 创建 OAuth2 处理器实例
-func NewOAuth2Handler(userService *user.Service, cfg *config.Config, logger *logger.ZapLogger, githubOAuth2 *oauth2.GitHubOAuth2Service, redisClient *redis.Client) *OAuth2Handler {
+func NewOAuth2Handler(userService *user.Service, cfg *config.Config, logger *logger.ZapLogger, githubOAuth2 *oauth2.GitHubOAuth2Service, oidcProviders map[string]*oauth2.OIDCProvider, redisClient *redis.Client, tokenManager *token.Manager, lockoutManager *lockout.Manager, mailSender mail.Sender) *OAuth2Handler {
+	sessionManager := session.NewManager(redisClient)
 	return &OAuth2Handler{
 		userService:    userService,
 		config:         cfg,
 		logger:         logger,
 		githubOAuth2:   githubOAuth2,
-		sessionManager: session.NewManager(redisClient),
+		oidcProviders:  oidcProviders,
+		sessionManager: sessionManager,
+		tokenManager:   tokenManager,
+		loginLockout:   newLoginLockout(lockoutManager, sessionManager, tokenManager, mailSender, logger),
 	}
 }
 
@@ -48,32 +65,11 @@ func NewOAuth2Handler(userService *user.Service, cfg *config.Config, logger *log
 // @Success 302 {string} string "重定向到 GitHub"
 // @Router /auth/oauth2/github/login [get]
 func (h *OAuth2Handler) GitHubLogin(c *gin.Context) {
-	// 生成随机状态码防止 CSRF 攻击
-	state, err := h.generateRandomState()
-	if err != nil {
-		h.logger.Error("生成状态码失败", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+	authURL, sessionID, ok := h.beginAuthorization(c, user.ProviderGitHub, 0)
+	if !ok {
 		return
 	}
 
-	// 创建 OAuth2 会话，将状态信息存储到 Redis
-	sessionID, err := h.sessionManager.CreateOAuth2Session(
-		c.Request.Context(),
-		state,
-		c.GetHeader("User-Agent"),
-		c.ClientIP(),
-	)
-	if err != nil {
-		h.logger.Error("创建 OAuth2 会话失败", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
-		return
-	}
-
-	// 将 session ID 存储在 httpOnly cookie 中
-	c.SetCookie("oauth_session", sessionID, 600, "/", "", false, true) // 10分钟有效期
-
-	// 获取授权 URL 并重定向
-	authURL := h.githubOAuth2.GetAuthURL(state)
 	h.logger.Info("发起 GitHub OAuth2 登录",
 		zap.String("session_id", sessionID),
 		zap.String("client_ip", c.ClientIP()),
@@ -85,18 +81,210 @@ func (h *OAuth2Handler) GitHubLogin(c *gin.Context) {
 
 // GitHubCallback 处理 GitHub OAuth2 回调
 // @Summary GitHub OAuth2 回调
-// @Description 处理 GitHub OAuth2 认证回调
+// @Description 处理 GitHub OAuth2 认证回调，登录成功后重定向到前端页面并携带一次性登录码，前端调用 /auth/oauth2/token 换取令牌；由关联账号发起时只关联，不登录
 // @Tags oauth2
 // @Accept json
 // @Produce json
 // @Param code query string true "授权码"
 // @Param state query string true "状态码"
-// @Success 200 {object} gin.H{token:string, user_id:uint, username:string, auth_type:string}
-// @Failure 400 {object} gin.H{error:string}
-// @Failure 401 {object} gin.H{error:string}
-// @Failure 500 {object} gin.H{error:string}
+// @Success 307 {string} string "重定向到前端登录成功页面"
 // @Router /auth/oauth2/github/callback [get]
 func (h *OAuth2Handler) GitHubCallback(c *gin.Context) {
+	// 1~5. 验证并删除 OAuth2 会话
+	code, sessionID, stateInfo, ok := h.consumeOAuth2Session(c, user.ProviderGitHub)
+	if !ok {
+		return
+	}
+
+	// 6. 交换授权码获取用户信息
+	githubUser, err := h.githubOAuth2.ExchangeCode(c.Request.Context(), code, stateInfo.CodeVerifier)
+	if err != nil {
+		h.logger.Error("GitHub OAuth2 授权失败",
+			zap.String("code", code),
+			zap.String("session_id", sessionID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.redirectToError(c, "GitHub授权失败")
+		return
+	}
+
+	// 7~10. 关联账号，或登录（注册）用户并签发令牌
+	h.finishAuthorization(c, user.GitHubAccount(githubUser), sessionID, stateInfo,
+		zap.String("github_login", githubUser.Login),
+	)
+}
+
+// OIDCLogin 发起 OpenID Connect 登录
+// @Summary OpenID Connect 登录
+// @Description 重定向到配置的 OpenID Connect 提供方（如 Google、Microsoft Entra、Keycloak、GitLab）进行认证
+// @Tags oauth2
+// @Produce json
+// @Param provider path string true "提供方名称（配置中 oauth2.providers 的键）"
+// @Success 302 {string} string "重定向到提供方"
+// @Failure 404 {object} gin.H{error:string}
+// @Failure 502 {object} gin.H{error:string}
+// @Router /auth/oauth2/{provider}/login [get]
+func (h *OAuth2Handler) OIDCLogin(c *gin.Context) {
+	provider, ok := h.oidcProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "不支持的登录方式"})
+		return
+	}
+
+	authURL, sessionID, ok := h.beginAuthorization(c, provider.Name(), 0)
+	if !ok {
+		return
+	}
+
+	h.logger.Info("发起 OIDC 登录",
+		zap.String("provider", provider.Name()),
+		zap.String("session_id", sessionID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.String("user_agent", c.GetHeader("User-Agent")),
+	)
+
+	c.Redirect(http.StatusTemporaryRedirect, authURL)
+}
+
+// OIDCCallback 处理 OpenID Connect 回调
+// @Summary OpenID Connect 回调
+// @Description 验证 ID Token（签名、iss、aud、exp、nonce）后登录或注册用户，并重定向到前端页面，携带的一次性登录码通过 /auth/oauth2/token 换取令牌；由关联账号发起时只关联，不登录
+// @Tags oauth2
+// @Produce json
+// @Param provider path string true "提供方名称"
+// @Param code query string true "授权码"
+// @Param state query string true "状态码"
+// @Success 307 {string} string "重定向到前端登录成功页面"
+// @Failure 404 {object} gin.H{error:string}
+// @Router /auth/oauth2/{provider}/callback [get]
+func (h *OAuth2Handler) OIDCCallback(c *gin.Context) {
+	provider, ok := h.oidcProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "不支持的登录方式"})
+		return
+	}
+
+	code, sessionID, stateInfo, ok := h.consumeOAuth2Session(c, provider.Name())
+	if !ok {
+		return
+	}
+
+	oidcUser, err := provider.ExchangeCode(c.Request.Context(), code, stateInfo.Nonce, stateInfo.CodeVerifier)
+	if err != nil {
+		h.logger.Error("OIDC 授权失败",
+			zap.String("provider", provider.Name()),
+			zap.String("session_id", sessionID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.redirectToError(c, provider.DisplayName()+"授权失败")
+		return
+	}
+
+	h.finishAuthorization(c, user.OIDCAccount(oidcUser), sessionID, stateInfo,
+		zap.String("subject", oidcUser.Subject),
+	)
+}
+
+// beginAuthorization 生成状态码、PKCE 验证码（OpenID Connect 还有 nonce），创建 OAuth2 会话并返回授权URL
+// linkUserID 非零时为该用户关联外部账号；失败时已返回错误响应
+func (h *OAuth2Handler) beginAuthorization(c *gin.Context, provider string, linkUserID uint) (string, string, bool) {
+	// 生成随机状态码防止 CSRF 攻击，PKCE 验证码防止授权码注入
+	state, err := h.generateRandomState()
+	if err != nil {
+		h.logger.Error("生成状态码失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return "", "", false
+	}
+	stateInfo := session.OAuth2State{
+		State:        state,
+		Provider:     provider,
+		CodeVerifier: oauth2.NewCodeVerifier(),
+		LinkUserID:   linkUserID,
+	}
+
+	var authURL string
+	if provider == user.ProviderGitHub {
+		authURL = h.githubOAuth2.GetAuthURL(state, stateInfo.CodeVerifier)
+	} else {
+		// nonce 防止 ID Token 重放
+		if stateInfo.Nonce, err = h.generateRandomState(); err != nil {
+			h.logger.Error("生成 nonce 失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+			return "", "", false
+		}
+		oidcProvider := h.oidcProviders[provider]
+		authURL, err = oidcProvider.GetAuthURL(c.Request.Context(), state, stateInfo.Nonce, stateInfo.CodeVerifier)
+		if err != nil {
+			h.logger.Error("获取 OIDC 授权地址失败",
+				zap.String("provider", provider),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusBadGateway, gin.H{"error": oidcProvider.DisplayName() + " 暂时不可用"})
+			return "", "", false
+		}
+	}
+
+	// 创建 OAuth2 会话，将状态信息、nonce 和 PKCE 验证码存储到 Redis
+	sessionID, ok := h.startOAuth2Session(c, stateInfo)
+	if !ok {
+		return "", "", false
+	}
+	return authURL, sessionID, true
+}
+
+// finishAuthorization 处理已验证的外部账号：关联账号流程中关联到发起的用户，否则登录或注册用户
+func (h *OAuth2Handler) finishAuthorization(c *gin.Context, account *user.ExternalAccount, sessionID string, stateInfo *session.OAuth2State, fields ...interface{}) {
+	if stateInfo.LinkUserID != 0 {
+		h.completeLink(c, stateInfo.LinkUserID, account, sessionID)
+		return
+	}
+
+	u, err := h.userService.LoginWithExternal(account)
+	if err != nil {
+		var confirmErr *user.LinkConfirmationError
+		if errors.As(err, &confirmErr) {
+			h.requireLinkConfirmation(c, confirmErr.UserID, account, sessionID)
+			return
+		}
+		h.logger.Error("外部账号登录失败",
+			zap.String("provider", account.Provider),
+			zap.String("subject", account.Subject),
+			zap.String("email", account.Email),
+			zap.Bool("email_verified", account.EmailVerified),
+			zap.String("session_id", sessionID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.redirectToError(c, "用户登录失败")
+		return
+	}
+
+	h.completeLogin(c, u, account.Provider, sessionID, stateInfo,
+		append([]interface{}{zap.String("provider", account.Provider)}, fields...)...,
+	)
+}
+
+// startOAuth2Session 创建 OAuth2 会话，并将 session ID 存储在 httpOnly cookie 中
+func (h *OAuth2Handler) startOAuth2Session(c *gin.Context, stateInfo session.OAuth2State) (string, bool) {
+	stateInfo.UserAgent = c.GetHeader("User-Agent")
+	stateInfo.ClientIP = c.ClientIP()
+
+	sessionID, err := h.sessionManager.CreateOAuth2Session(c.Request.Context(), stateInfo)
+	if err != nil {
+		h.logger.Error("创建 OAuth2 会话失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return "", false
+	}
+
+	c.SetCookie("oauth_session", sessionID, 600, "/", "", false, true) // 10分钟有效期
+	return sessionID, true
+}
+
+// consumeOAuth2Session 校验回调的授权码、状态码和发起登录的提供方，并删除 OAuth2 会话（一次性使用）
+// 校验失败时已重定向到错误页面
+func (h *OAuth2Handler) consumeOAuth2Session(c *gin.Context, provider string) (string, string, *session.OAuth2State, bool) {
 	// 1. 从 cookie 获取 session ID
 	sessionID, err := c.Cookie("oauth_session")
 	if err != nil {
@@ -105,7 +293,7 @@ func (h *OAuth2Handler) GitHubCallback(c *gin.Context) {
 			zap.Error(err),
 		)
 		h.redirectToError(c, "缺少会话信息")
-		return
+		return "", "", nil, false
 	}
 
 	// 2. 验证状态码
@@ -118,11 +306,14 @@ func (h *OAuth2Handler) GitHubCallback(c *gin.Context) {
 			zap.String("client_ip", c.ClientIP()),
 		)
 		h.redirectToError(c, "缺少授权码")
-		return
+		return "", "", nil, false
 	}
 
-	// 3. 验证会话和状态码
+	// 3. 验证会话和状态码，会话必须由同一提供方发起
 	stateInfo, err := h.sessionManager.ValidateOAuth2Session(c.Request.Context(), sessionID, receivedState)
+	if err == nil && stateInfo.Provider != provider {
+		err = fmt.Errorf("会话提供方不匹配: 期望 %s, 收到 %s", stateInfo.Provider, provider)
+	}
 	if err != nil {
 		h.logger.Warn("OAuth2 状态码验证失败",
 			zap.String("session_id", sessionID),
@@ -131,7 +322,7 @@ func (h *OAuth2Handler) GitHubCallback(c *gin.Context) {
 			zap.Error(err),
 		)
 		h.redirectToError(c, "状态码验证失败")
-		return
+		return "", "", nil, false
 	}
 
 	// 4. 删除会话（一次性使用）
@@ -143,78 +334,179 @@ func (h *OAuth2Handler) GitHubCallback(c *gin.Context) {
 	// 5. 清除 cookie
 	c.SetCookie("oauth_session", "", -1, "/", "", false, true)
 
-	// 6. 交换授权码获取用户信息
-	githubUser, err := h.githubOAuth2.ExchangeCode(c.Request.Context(), code)
+	return code, sessionID, stateInfo, true
+}
+
+// completeLogin 签发一次性登录码并重定向到前端成功页面，前端凭登录码调用 /auth/oauth2/token 换取令牌
+// 令牌不放在重定向地址中，避免通过浏览器历史、Referer 和访问日志泄露
+func (h *OAuth2Handler) completeLogin(c *gin.Context, u *user.User, authMethod, sessionID string, stateInfo *session.OAuth2State, fields ...interface{}) {
+	// 外部账号登录同样受登录失败锁定限制
+	if h.loginLockout.accountLocked(c, u.ID) != nil {
+		h.redirectToError(c, "账号已被锁定，请稍后再试或重置密码")
+		return
+	}
+
+	// 8. 签发一次性登录码
+	code, err := h.sessionManager.CreateLoginCode(c.Request.Context(), session.LoginCode{
+		UserID:     u.ID,
+		AuthMethod: authMethod,
+		ClientIP:   c.ClientIP(),
+	})
 	if err != nil {
-		h.logger.Error("GitHub OAuth2 授权失败",
-			zap.String("code", code),
+		h.logger.Error("签发登录码失败",
+			zap.Uint("user_id", u.ID),
 			zap.String("session_id", sessionID),
-			zap.String("client_ip", c.ClientIP()),
 			zap.Error(err),
 		)
-		h.redirectToError(c, "GitHub授权失败")
+		h.redirectToError(c, "用户登录失败")
 		return
 	}
 
-	// 7. 登录或注册用户
-	u, err := h.userService.LoginWithGitHub(githubUser)
+	// 9. 记录登录成功日志
+	h.logger.Info("OAuth2 登录成功", append([]interface{}{
+		zap.Uint("user_id", u.ID),
+		zap.String("username", u.Username),
+		zap.String("auth_type", u.AuthType),
+		zap.String("auth_method", authMethod),
+		zap.String("session_id", sessionID),
+		zap.String("stored_ip", stateInfo.ClientIP),
+		zap.String("current_ip", c.ClientIP()),
+	}, fields...)...)
+
+	// 10. 重定向到前端成功页面
+	h.redirectToUI(c, h.config.UI.LoginSuccessPath, url.Values{
+		"code":       {code},
+		"expires_in": {strconv.Itoa(int(session.LoginCodeTTL.Seconds()))},
+	})
+}
+
+// ExchangeLoginCode 兑换一次性登录码
+// @Summary 兑换外部账号登录码
+// @Description 外部账号登录成功后，前端成功页面地址中携带一次性登录码（1分钟内有效，只能兑换一次），凭登录码换取访问令牌和刷新令牌；已启用两步验证的用户返回 mfa_token，需再调用 /auth/login/mfa
+// @Tags oauth2
+// @Accept json
+// @Produce json
+// @Param request body OAuth2TokenRequest true "登录码"
+// @Success 200 {object} gin.H{token:string, refresh_token:string, expires_in:int64, user_id:uint, username:string, auth_type:string}
+// @Success 202 {object} gin.H{mfa_required:bool, mfa_token:string, mfa_methods:[]string, expires_in:int64}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Router /auth/oauth2/token [post]
+func (h *OAuth2Handler) ExchangeLoginCode(c *gin.Context) {
+	var req OAuth2TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	login, err := h.sessionManager.ConsumeLoginCode(c.Request.Context(), req.Code)
 	if err != nil {
-		h.logger.Error("GitHub 用户登录失败",
-			zap.Int64("github_id", githubUser.ID),
-			zap.String("github_login", githubUser.Login),
-			zap.String("github_email", githubUser.Email),
-			zap.String("session_id", sessionID),
+		if errors.Is(err, session.ErrLoginCodeInvalid) {
+			h.logger.Warn("兑换登录码失败：登录码无效",
+				zap.String("client_ip", c.ClientIP()),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("兑换登录码失败",
 			zap.String("client_ip", c.ClientIP()),
 			zap.Error(err),
 		)
-		h.redirectToError(c, "用户登录失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+
+	u, err := h.userService.GetByID(login.UserID)
+	if err != nil {
+		h.logger.Warn("兑换登录码失败：查询用户时发生错误",
+			zap.Uint("user_id", login.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": session.ErrLoginCodeInvalid.Error()})
+		return
+	}
+	if st := h.loginLockout.accountLocked(c, u.ID); st != nil {
+		respondLockedOut(c, st)
+		return
+	}
+
+	// 已启用两步验证时，外部账号只作为第一因子，与密码登录一样需要提交第二因子
+	mfaMethods, err := h.userService.MFAMethods(u)
+	if err != nil {
+		h.logger.Error("查询两步验证方式失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+	if len(mfaMethods) > 0 {
+		mfaToken, err := h.sessionManager.CreateMFAChallenge(c.Request.Context(), session.MFAChallenge{
+			UserID:     u.ID,
+			AuthMethod: login.AuthMethod,
+			DeviceName: req.DeviceName,
+			ClientIP:   c.ClientIP(),
+		}, h.config.MFA.ChallengeTTL)
+		if err != nil {
+			h.logger.Error("创建两步验证挑战失败",
+				zap.Uint("user_id", u.ID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+			return
+		}
+
+		h.logger.Info("外部账号验证通过，等待两步验证",
+			zap.Uint("user_id", u.ID),
+			zap.String("auth_method", login.AuthMethod),
+			zap.String("client_ip", c.ClientIP()),
+		)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"mfa_methods":  mfaMethods,
+			"expires_in":   int64(h.config.MFA.ChallengeTTL.Seconds()),
+		})
 		return
 	}
 
-	// 8. 生成 JWT 令牌
-	token, err := jwt.GenerateToken(u.ID, u.Username, h.config.JWT.Secret, 24*time.Hour)
+	// 创建登录会话并签发访问令牌和刷新令牌
+	pair, err := startUserSession(c, h.sessionManager, h.tokenManager, h.config, u, login.AuthMethod, req.DeviceName)
 	if err != nil {
 		h.logger.Error("生成 JWT 令牌失败",
 			zap.Uint("user_id", u.ID),
-			zap.String("username", u.Username),
-			zap.String("session_id", sessionID),
+			zap.String("client_ip", c.ClientIP()),
 			zap.Error(err),
 		)
-		h.redirectToError(c, "生成令牌失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
 		return
 	}
 
-	// 9. 记录登录成功日志
-	h.logger.Info("GitHub OAuth2 登录成功",
+	h.logger.Info("兑换登录码成功",
 		zap.Uint("user_id", u.ID),
-		zap.String("username", u.Username),
-		zap.String("auth_type", u.AuthType),
-		zap.String("github_login", githubUser.Login),
-		zap.String("session_id", sessionID),
-		zap.String("stored_ip", stateInfo.ClientIP),
+		zap.String("auth_method", login.AuthMethod),
+		zap.String("stored_ip", login.ClientIP),
 		zap.String("current_ip", c.ClientIP()),
 	)
 
-	// 10. 重定向到前端成功页面
-	successURL := fmt.Sprintf("%s%s?token=%s&user_id=%d&username=%s&auth_type=%s",
-		h.config.UI.BaseURL,
-		h.config.UI.LoginSuccessPath,
-		token,
-		u.ID,
-		u.Username,
-		u.AuthType,
-	)
-	c.Redirect(http.StatusTemporaryRedirect, successURL)
+	c.JSON(http.StatusOK, gin.H{
+		"token":              pair.AccessToken,
+		"refresh_token":      pair.RefreshToken,
+		"token_type":         pair.TokenType,
+		"expires_in":         pair.ExpiresIn,
+		"refresh_expires_in": pair.RefreshExpiresIn,
+		"session_id":         pair.SessionID,
+		"user_id":            u.ID,
+		"username":           u.Username,
+		"auth_type":          u.AuthType,
+	})
 }
 
 // redirectToError 重定向到错误页面
 func (h *OAuth2Handler) redirectToError(c *gin.Context, message string) {
-	errorURL := fmt.Sprintf("%s%s?message=%s",
-		h.config.UI.BaseURL,
-		h.config.UI.LoginErrorPath,
-		message,
-	)
-	c.Redirect(http.StatusTemporaryRedirect, errorURL)
+	h.redirectToUI(c, h.config.UI.LoginErrorPath, url.Values{"message": {message}})
 }
 
 // generateRandomState 生成随机状态码
@@ -0,0 +1,764 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.uber.org/zap"
+
+	"auth-service/internal/config"
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/captcha"
+	"auth-service/pkg/lockout"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/mail"
+	"auth-service/pkg/passkey"
+	"auth-service/pkg/session"
+	"auth-service/pkg/token"
+)
+
+// PasskeyRegisterBeginRequest 开始注册通行密钥请求参数结构体：添加凭证前再次验证身份
+// 已启用两步验证或已有通行密钥时须提交验证码，或使用已有凭证认证（ceremony_token 和 credential）；否则提交当前密码
+type PasskeyRegisterBeginRequest struct {
+	CurrentPassword string          `json:"current_password"`
+	Code            string          `json:"code" binding:"omitempty,min=6,max=32"` // TOTP 验证码或恢复码
+	CeremonyToken   string          `json:"ceremony_token"`                        // /auth/passkeys/verify/begin 返回的仪式令牌
+	Credential      json.RawMessage `json:"credential"`                            // navigator.credentials.get() 的结果
+}
+
+// PasskeyRegisterRequest 完成通行密钥注册请求参数结构体
+type PasskeyRegisterRequest struct {
+	CeremonyToken string          `json:"ceremony_token" binding:"required"`
+	Name          string          `json:"name" binding:"max=64"`         // 可选：凭证名称，如"MacBook 指纹"
+	Credential    json.RawMessage `json:"credential" binding:"required"` // navigator.credentials.create() 的结果
+}
+
+// PasskeyLoginRequest 完成通行密钥登录请求参数结构体
+type PasskeyLoginRequest struct {
+	CeremonyToken string          `json:"ceremony_token" binding:"required"`
+	Credential    json.RawMessage `json:"credential" binding:"required"` // navigator.credentials.get() 的结果
+	DeviceName    string          `json:"device_name" binding:"max=64"`
+}
+
+// PasskeyMFABeginRequest 开始安全密钥两步验证请求参数结构体
+type PasskeyMFABeginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// PasskeyMFAFinishRequest 完成安全密钥两步验证请求参数结构体
+type PasskeyMFAFinishRequest struct {
+	MFAToken      string          `json:"mfa_token" binding:"required"`
+	CeremonyToken string          `json:"ceremony_token" binding:"required"`
+	Credential    json.RawMessage `json:"credential" binding:"required"`
+}
+
+// PasskeyHandler 通行密钥（WebAuthn）处理器
+type PasskeyHandler struct {
+	userService     *user.Service
+	passkeyManager  *passkey.Manager
+	sessionManager  *session.Manager
+	tokenManager    *token.Manager
+	loginLockout    *loginLockout     // 登录失败锁定
+	mailSender      mail.Sender       // 添加通行密钥时通知用户
+	captchaAssessor *captcha.Assessor // 两步验证通过后信任设备
+	config          *config.Config
+	logger          *logger.ZapLogger
+}
+
+// NewPasskeyHandler 创建通行密钥处理器实例
+func NewPasskeyHandler(userService *user.Service, passkeyManager *passkey.Manager, sessionManager *session.Manager, tokenManager *token.Manager, lockoutManager *lockout.Manager, mailSender mail.Sender, captchaAssessor *captcha.Assessor, cfg *config.Config, logger *logger.ZapLogger) *PasskeyHandler {
+	return &PasskeyHandler{
+		userService:     userService,
+		passkeyManager:  passkeyManager,
+		sessionManager:  sessionManager,
+		tokenManager:    tokenManager,
+		loginLockout:    newLoginLockout(lockoutManager, sessionManager, tokenManager, mailSender, logger),
+		mailSender:      mailSender,
+		captchaAssessor: captchaAssessor,
+		config:          cfg,
+		logger:          logger,
+	}
+}
+
+// ListPasskeys 查询当前用户的通行密钥
+// @Summary 查询通行密钥
+// @Description 列出当前用户注册的通行密钥和安全密钥
+// @Tags passkey
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} gin.H{passkeys:[]user.WebauthnCredential}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/passkeys [get]
+func (h *PasskeyHandler) ListPasskeys(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("查询通行密钥失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	credentials, err := h.userService.ListPasskeys(claims.UserID)
+	if err != nil {
+		h.logger.Error("查询通行密钥失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询通行密钥失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"passkeys": credentials})
+}
+
+// BeginStepUp 开始使用已有通行密钥验证身份
+// @Summary 使用已有通行密钥验证身份
+// @Description 添加通行密钥前使用已注册的凭证验证身份，返回 navigator.credentials.get() 所需的选项和仪式令牌
+// @Tags passkey
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} gin.H{ceremony_token:string, options:object}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/passkeys/verify/begin [post]
+func (h *PasskeyHandler) BeginStepUp(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("通行密钥身份验证失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	account, err := h.loadAccount(claims.UserID)
+	if err != nil {
+		h.logger.Error("通行密钥身份验证失败：加载用户失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证身份失败"})
+		return
+	}
+	if len(account.Credentials) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该账号未注册通行密钥"})
+		return
+	}
+
+	options, ceremonyToken, err := h.passkeyManager.BeginLogin(c.Request.Context(), account)
+	if err != nil {
+		h.logger.Error("通行密钥身份验证失败：生成认证选项失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证身份失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ceremony_token": ceremonyToken,
+		"options":        options,
+	})
+}
+
+// BeginRegistration 开始注册通行密钥
+// @Summary 开始注册通行密钥
+// @Description 再次验证身份后返回 navigator.credentials.create() 所需的选项和仪式令牌，支持平台认证器、漫游安全密钥和可发现凭证。
+// @Description 通行密钥登录同时满足两个因子，因此已启用两步验证或已有通行密钥时只接受验证码或已有凭证，否则接受当前密码；验证失败计入账号的登录失败次数
+// @Tags passkey
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body PasskeyRegisterBeginRequest true "身份验证"
+// @Success 200 {object} gin.H{ceremony_token:string, options:object}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 403 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/passkeys/register/begin [post]
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("注册通行密钥失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req PasskeyRegisterBeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	u, err := h.userService.GetByID(claims.UserID)
+	if err != nil {
+		h.logger.Error("注册通行密钥失败：查询用户失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册通行密钥失败"})
+		return
+	}
+	if h.loginLockout.stepUpLocked(c, u.ID) {
+		return
+	}
+
+	account, err := h.loadAccount(u.ID)
+	if err != nil {
+		h.logger.Error("注册通行密钥失败：加载用户失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册通行密钥失败"})
+		return
+	}
+	if !h.verifyStepUp(c, u, account, &req) {
+		return
+	}
+
+	options, ceremonyToken, err := h.passkeyManager.BeginRegistration(c.Request.Context(), account)
+	if err != nil {
+		h.logger.Error("注册通行密钥失败：生成注册选项失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册通行密钥失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ceremony_token": ceremonyToken,
+		"options":        options,
+	})
+}
+
+// FinishRegistration 完成注册通行密钥
+// @Summary 完成注册通行密钥
+// @Description 提交认证器的注册响应，校验通过后保存凭证
+// @Tags passkey
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body PasskeyRegisterRequest true "注册响应"
+// @Success 201 {object} gin.H{passkey:user.WebauthnCredential}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 409 {object} gin.H{error:string}
+// @Router /auth/passkeys/register/finish [post]
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("注册通行密钥失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req PasskeyRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	account, err := h.loadAccount(claims.UserID)
+	if err != nil {
+		h.logger.Error("注册通行密钥失败：加载用户失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册通行密钥失败"})
+		return
+	}
+
+	credential, discoverable, err := h.passkeyManager.FinishRegistration(c.Request.Context(), account, req.CeremonyToken, req.Credential)
+	if err != nil {
+		h.logger.Warn("注册通行密钥失败：注册响应无效",
+			zap.Uint("user_id", claims.UserID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "通行密钥注册失败，请重试"})
+		return
+	}
+
+	saved, err := h.userService.AddPasskey(claims.UserID, req.Name, credential, discoverable)
+	if err != nil {
+		if errors.Is(err, user.ErrPasskeyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("注册通行密钥失败：保存凭证失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册通行密钥失败"})
+		return
+	}
+
+	h.logger.Info("用户已注册通行密钥",
+		zap.Uint("user_id", claims.UserID),
+		zap.Uint("passkey_id", saved.ID),
+		zap.String("attachment", saved.Attachment),
+		zap.Bool("discoverable", saved.Discoverable),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	// 通知用户，访问令牌被盗用时用户可以及时发现
+	if u, err := h.userService.GetByID(claims.UserID); err == nil && u.Email != "" {
+		go h.sendNotice(*u, saved.Name, c.ClientIP())
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"passkey": saved})
+}
+
+// DeletePasskey 删除通行密钥
+// @Summary 删除通行密钥
+// @Description 删除当前用户的指定通行密钥或安全密钥
+// @Tags passkey
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "通行密钥ID"
+// @Success 200 {object} gin.H{message:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 404 {object} gin.H{error:string}
+// @Router /auth/passkeys/{id} [delete]
+func (h *PasskeyHandler) DeletePasskey(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("删除通行密钥失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": user.ErrPasskeyNotFound.Error()})
+		return
+	}
+
+	if err := h.userService.DeletePasskey(claims.UserID, uint(id)); err != nil {
+		if errors.Is(err, user.ErrPasskeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("删除通行密钥失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Uint64("passkey_id", id),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除通行密钥失败"})
+		return
+	}
+
+	h.logger.Info("用户已删除通行密钥",
+		zap.Uint("user_id", claims.UserID),
+		zap.Uint64("passkey_id", id),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "通行密钥已删除"})
+}
+
+// BeginLogin 开始通行密钥免密登录
+// @Summary 开始通行密钥登录
+// @Description 返回 navigator.credentials.get() 所需的选项和仪式令牌，无需用户名，由认证器选择可发现凭证
+// @Tags passkey
+// @Produce json
+// @Success 200 {object} gin.H{ceremony_token:string, options:object}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/passkey/login/begin [post]
+func (h *PasskeyHandler) BeginLogin(c *gin.Context) {
+	options, ceremonyToken, err := h.passkeyManager.BeginLogin(c.Request.Context(), nil)
+	if err != nil {
+		h.logger.Error("通行密钥登录失败：生成认证选项失败",
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ceremony_token": ceremonyToken,
+		"options":        options,
+	})
+}
+
+// FinishLogin 完成通行密钥免密登录
+// @Summary 完成通行密钥登录
+// @Description 提交认证器的认证响应，校验通过后签发与密码登录相同的令牌
+// @Tags passkey
+// @Accept json
+// @Produce json
+// @Param request body PasskeyLoginRequest true "认证响应"
+// @Success 200 {object} gin.H{token:string, refresh_token:string, expires_in:int64, user_id:uint, username:string}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Router /auth/passkey/login/finish [post]
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	var req PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	account, ok := h.finishAssertion(c, req.CeremonyToken, req.Credential, nil)
+	if !ok {
+		return
+	}
+
+	h.issueTokens(c, account.ID, session.AuthMethodPasskey, req.DeviceName, nil)
+}
+
+// BeginMFA 开始安全密钥两步验证
+// @Summary 开始安全密钥两步验证
+// @Description 密码或外部账号验证通过后，使用登录接口（或外部账号登录码兑换接口）返回的 mfa_token 获取 navigator.credentials.get() 所需的选项
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyMFABeginRequest true "两步验证挑战令牌"
+// @Success 200 {object} gin.H{ceremony_token:string, options:object}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Router /auth/login/mfa/webauthn/begin [post]
+func (h *PasskeyHandler) BeginMFA(c *gin.Context) {
+	var req PasskeyMFABeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	challenge, err := h.sessionManager.GetMFAChallenge(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		h.respondChallengeError(c, err)
+		return
+	}
+
+	account, err := h.loadAccount(challenge.UserID)
+	if err != nil {
+		h.logger.Error("安全密钥两步验证失败：加载用户失败",
+			zap.Uint("user_id", challenge.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+	if len(account.Credentials) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该账号未注册安全密钥"})
+		return
+	}
+
+	options, ceremonyToken, err := h.passkeyManager.BeginLogin(c.Request.Context(), account)
+	if err != nil {
+		h.logger.Error("安全密钥两步验证失败：生成认证选项失败",
+			zap.Uint("user_id", challenge.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ceremony_token": ceremonyToken,
+		"options":        options,
+	})
+}
+
+// FinishMFA 完成安全密钥两步验证
+// @Summary 完成安全密钥两步验证
+// @Description 提交认证器的认证响应完成登录，每个 mfa_token 最多尝试5次，验证失败计入账号的登录失败次数
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body PasskeyMFAFinishRequest true "认证响应"
+// @Success 200 {object} gin.H{token:string, refresh_token:string, expires_in:int64, user_id:uint, username:string, device_id:string}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 423 {object} gin.H{error:string, retry_after:int}
+// @Router /auth/login/mfa/webauthn/finish [post]
+func (h *PasskeyHandler) FinishMFA(c *gin.Context) {
+	var req PasskeyMFAFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	challenge, err := h.sessionManager.AttemptMFAChallenge(c.Request.Context(), req.MFAToken, h.config.MFA.ChallengeTTL)
+	if err != nil {
+		h.respondChallengeError(c, err)
+		return
+	}
+
+	account, ok := h.finishAssertion(c, req.CeremonyToken, req.Credential, challenge)
+	if !ok {
+		return
+	}
+	// 仪式在开始时已绑定挑战所属用户，这里再次确认
+	if account.ID != challenge.UserID {
+		h.logger.Warn("安全密钥两步验证失败：凭证不属于当前用户",
+			zap.Uint("user_id", challenge.UserID),
+			zap.Uint("credential_user_id", account.ID),
+			zap.String("client_ip", c.ClientIP()),
+		)
+		h.recordMFAFailure(c, challenge)
+		return
+	}
+
+	if err := h.sessionManager.DeleteMFAChallenge(c.Request.Context(), req.MFAToken); err != nil {
+		h.logger.Warn("删除两步验证挑战失败", zap.Error(err))
+	}
+
+	h.issueTokens(c, account.ID, challenge.CompleteAuthMethod(session.SecondFactorWebAuthn), challenge.DeviceName, challenge)
+}
+
+// finishAssertion 校验认证响应并记录签名计数，失败时已写入响应
+// challenge 非空表示两步验证，认证失败计入挑战所属账号的登录失败次数
+func (h *PasskeyHandler) finishAssertion(c *gin.Context, ceremonyToken string, response []byte, challenge *session.MFAChallenge) (*passkey.Account, bool) {
+	account, credential, err := h.passkeyManager.FinishLogin(c.Request.Context(), ceremonyToken, response, h.loadAccount)
+	if err != nil {
+		if errors.Is(err, passkey.ErrCeremonyNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		h.logger.Warn("通行密钥认证失败",
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		if challenge != nil {
+			h.recordMFAFailure(c, challenge)
+			return nil, false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "安全密钥验证失败"})
+		return nil, false
+	}
+
+	if err := h.userService.RecordPasskeyUse(credential); err != nil {
+		if errors.Is(err, user.ErrPasskeyCloned) {
+			// 签名计数回退：同一凭证可能存在于两个认证器中
+			h.logger.Warn("检测到克隆的认证器，已停用该凭证",
+				zap.Uint("user_id", account.ID),
+				zap.Uint32("sign_count", credential.Authenticator.SignCount),
+				zap.String("client_ip", c.ClientIP()),
+			)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		h.logger.Error("记录通行密钥使用失败",
+			zap.Uint("user_id", account.ID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return nil, false
+	}
+
+	return account, true
+}
+
+// issueTokens 为认证通过的用户创建登录会话并签发令牌，账号已锁定时拒绝
+// challenge 非空表示两步验证登录，第二因子通过后信任设备并清除登录失败次数
+func (h *PasskeyHandler) issueTokens(c *gin.Context, userID uint, authMethod, deviceName string, challenge *session.MFAChallenge) {
+	u, err := h.userService.GetByID(userID)
+	if err != nil {
+		h.logger.Error("通行密钥登录失败：查询用户失败",
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+	if st := h.loginLockout.accountLocked(c, u.ID); st != nil {
+		respondLockedOut(c, st)
+		return
+	}
+	var deviceID string
+	if challenge != nil {
+		deviceID = recordLoginSuccess(c, h.captchaAssessor, h.loginLockout, h.logger, u, challenge.DeviceID)
+	}
+
+	pair, err := startUserSession(c, h.sessionManager, h.tokenManager, h.config, u, authMethod, deviceName)
+	if err != nil {
+		h.logger.Error("JWT令牌生成失败",
+			zap.Uint("user_id", u.ID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+
+	h.logger.Info("用户通行密钥登录成功",
+		zap.String("username", u.Username),
+		zap.Uint("user_id", u.ID),
+		zap.String("auth_method", authMethod),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	resp := gin.H{
+		"token":              pair.AccessToken,
+		"refresh_token":      pair.RefreshToken,
+		"token_type":         pair.TokenType,
+		"expires_in":         pair.ExpiresIn,
+		"refresh_expires_in": pair.RefreshExpiresIn,
+		"session_id":         pair.SessionID,
+		"user_id":            u.ID,
+		"username":           u.Username,
+	}
+	if deviceID != "" {
+		resp["device_id"] = deviceID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// recordMFAFailure 安全密钥两步验证失败：计入挑战所属账号的登录失败次数，并返回认证失败（触发锁定时返回锁定）
+func (h *PasskeyHandler) recordMFAFailure(c *gin.Context, challenge *session.MFAChallenge) {
+	u, err := h.userService.GetByID(challenge.UserID)
+	if err != nil {
+		h.logger.Warn("记录两步验证失败：查询用户失败",
+			zap.Uint("user_id", challenge.UserID),
+			zap.Error(err),
+		)
+	} else if st := h.loginLockout.recordFailure(c, u); st != nil && st.Locked {
+		respondLockedOut(c, st)
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "安全密钥验证失败"})
+}
+
+// respondChallengeError 将两步验证挑战错误映射为 HTTP 响应
+func (h *PasskeyHandler) respondChallengeError(c *gin.Context, err error) {
+	if errors.Is(err, session.ErrMFAChallengeNotFound) || errors.Is(err, session.ErrMFAChallengeExhausted) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.Error("查询两步验证挑战失败",
+		zap.String("client_ip", c.ClientIP()),
+		zap.Error(err),
+	)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+}
+
+// verifyStepUp 添加通行密钥前再次验证身份，失败时已写入响应
+// 通行密钥登录同时满足两个因子，已启用两步验证或已有凭证时只接受第二因子，仅凭密码不能绕过它
+func (h *PasskeyHandler) verifyStepUp(c *gin.Context, u *user.User, account *passkey.Account, req *PasskeyRegisterBeginRequest) bool {
+	secondFactor := u.TOTPEnabled || len(account.Credentials) > 0
+
+	switch {
+	case req.CeremonyToken != "" && len(req.Credential) > 0 && len(account.Credentials) > 0:
+		asserted, credential, err := h.passkeyManager.FinishLogin(c.Request.Context(), req.CeremonyToken, req.Credential, h.loadAccount)
+		if errors.Is(err, passkey.ErrCeremonyNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		if err == nil && asserted.ID != u.ID {
+			err = errors.New("凭证不属于当前用户")
+		}
+		if err == nil {
+			err = h.userService.RecordPasskeyUse(credential)
+		}
+		if err != nil {
+			h.logger.Warn("注册通行密钥失败：已有凭证验证失败",
+				zap.Uint("user_id", u.ID),
+				zap.String("client_ip", c.ClientIP()),
+				zap.Error(err),
+			)
+			h.loginLockout.stepUpFailed(c, u, "安全密钥验证失败")
+			return false
+		}
+		return true
+
+	case req.Code != "" && u.TOTPEnabled:
+		if err := h.userService.VerifySecondFactor(u, req.Code); err != nil {
+			if errors.Is(err, user.ErrMFACodeInvalid) {
+				h.logger.Warn("注册通行密钥失败：验证码错误",
+					zap.Uint("user_id", u.ID),
+					zap.String("client_ip", c.ClientIP()),
+				)
+				h.loginLockout.stepUpFailed(c, u, err.Error())
+				return false
+			}
+			h.logger.Error("注册通行密钥失败：校验验证码失败",
+				zap.Uint("user_id", u.ID),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "注册通行密钥失败"})
+			return false
+		}
+		return true
+
+	case req.CurrentPassword != "" && !secondFactor && u.Password != "":
+		if !u.CheckPassword(req.CurrentPassword) {
+			h.logger.Warn("注册通行密钥失败：当前密码错误",
+				zap.Uint("user_id", u.ID),
+				zap.String("client_ip", c.ClientIP()),
+			)
+			h.loginLockout.stepUpFailed(c, u, user.ErrPasswordWrong.Error())
+			return false
+		}
+		return true
+	}
+
+	switch {
+	case secondFactor:
+		c.JSON(http.StatusForbidden, gin.H{"error": "请使用两步验证码或已注册的通行密钥验证身份"})
+	case u.Password != "":
+		c.JSON(http.StatusForbidden, gin.H{"error": "请输入当前密码验证身份"})
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": "请先通过找回密码设置登录密码或启用两步验证，再添加通行密钥"})
+	}
+	return false
+}
+
+// sendNotice 通知用户账号添加了通行密钥（异步调用，失败只记录日志）
+func (h *PasskeyHandler) sendNotice(u user.User, name, clientIP string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if name == "" {
+		name = "未命名"
+	}
+	if err := h.mailSender.Send(ctx, &mail.Message{
+		To:      u.Email,
+		Subject: "账号添加了通行密钥",
+		Body: fmt.Sprintf("%s，您好：\n\n您的账号于 %s 添加了通行密钥“%s”（IP %s），之后可使用它免密登录。\n\n如非本人操作，请立即删除该通行密钥、修改密码并下线所有设备。\n",
+			u.Username, time.Now().Format("2006-01-02 15:04:05"), name, clientIP),
+	}); err != nil {
+		h.logger.Warn("发送通行密钥提醒邮件失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// loadAccount 加载用户及其可用的 WebAuthn 凭证（已停用的疑似克隆凭证除外）
+func (h *PasskeyHandler) loadAccount(userID uint) (*passkey.Account, error) {
+	u, err := h.userService.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := h.userService.ListPasskeys(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(saved))
+	for i := range saved {
+		if saved[i].CloneWarning {
+			continue
+		}
+		credentials = append(credentials, saved[i].Credential())
+	}
+
+	return &passkey.Account{
+		ID:          u.ID,
+		Name:        u.Username,
+		DisplayName: u.Username,
+		Credentials: credentials,
+	}, nil
+}
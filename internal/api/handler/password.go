@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/internal/config"
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/lockout"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/mail"
+	"auth-service/pkg/password"
+	"auth-service/pkg/passwordreset"
+	"auth-service/pkg/session"
+	"auth-service/pkg/token"
+)
+
+// ForgotPasswordRequest 忘记密码请求参数结构体
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求参数结构体
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"` // 需符合密码策略
+}
+
+// PasswordHandler 密码管理处理器
+type PasswordHandler struct {
+	userService    *user.Service
+	resetManager   *passwordreset.Manager
+	mailSender     mail.Sender
+	sessionManager *session.Manager
+	tokenManager   *token.Manager
+	lockoutManager *lockout.Manager
+	config         *config.Config
+	logger         *logger.ZapLogger
+}
+
+// NewPasswordHandler 创建密码管理处理器实例
+func NewPasswordHandler(userService *user.Service, resetManager *passwordreset.Manager, mailSender mail.Sender, sessionManager *session.Manager, tokenManager *token.Manager, lockoutManager *lockout.Manager, cfg *config.Config, logger *logger.ZapLogger) *PasswordHandler {
+	return &PasswordHandler{
+		userService:    userService,
+		resetManager:   resetManager,
+		mailSender:     mailSender,
+		sessionManager: sessionManager,
+		tokenManager:   tokenManager,
+		lockoutManager: lockoutManager,
+		config:         cfg,
+		logger:         logger,
+	}
+}
+
+// ForgotPassword 发送重置密码邮件
+// @Summary 忘记密码
+// @Description 向账号邮箱发送一次性的重置密码链接；无论邮箱是否已注册都返回相同结果，避免泄露账号是否存在
+// @Tags password
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "账号邮箱"
+// @Success 200 {object} gin.H{message:string}
+// @Failure 400 {object} gin.H{error:string}
+// @Router /auth/password/forgot [post]
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	// 频率受限时同样返回成功，不泄露任何信息
+	allowed, err := h.resetManager.AllowSend(c.Request.Context(), req.Email)
+	if err != nil {
+		h.logger.Error("发送重置密码邮件失败：检查发送频率失败",
+			zap.String("email", req.Email),
+			zap.Error(err),
+		)
+	}
+	if allowed {
+		// 异步查询用户并发送邮件，使响应时间与账号是否存在无关
+		go h.sendResetEmail(req.Email, c.ClientIP())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，您将收到重置密码邮件"})
+}
+
+// ResetPassword 使用重置令牌设置新密码
+// @Summary 重置密码
+// @Description 校验重置令牌并设置新密码，令牌只能使用一次；新密码需符合密码策略，不符合时返回违反的规则，令牌不会失效；重置成功后该用户所有设备上的会话和令牌全部失效，登录失败锁定随之解除
+// @Tags password
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "重置令牌和新密码"
+// @Success 200 {object} gin.H{message:string}
+// @Failure 400 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/password/reset [post]
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数: " + err.Error()})
+		return
+	}
+
+	// 令牌与签发时的密码哈希绑定，密码修改后旧令牌自动失效
+	lookup := func(userID uint) (string, error) {
+		u, err := h.userService.GetByID(userID)
+		if err != nil {
+			return "", err
+		}
+		return u.Password, nil
+	}
+
+	// 先校验令牌和新密码，新密码不符合策略时令牌仍可继续使用
+	userID, err := h.resetManager.Verify(c.Request.Context(), req.Token, lookup)
+	if err == nil {
+		var u *user.User
+		if u, err = h.userService.GetByID(userID); err == nil {
+			err = h.userService.ValidatePassword(u, req.NewPassword)
+		}
+	}
+	if err == nil {
+		userID, err = h.resetManager.Consume(c.Request.Context(), req.Token, lookup)
+	}
+	if err != nil {
+		if respondPolicyError(c, "new_password", err) {
+			return
+		}
+		if errors.Is(err, passwordreset.ErrTokenInvalid) {
+			h.logger.Warn("重置密码失败：令牌无效",
+				zap.String("client_ip", c.ClientIP()),
+			)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("重置密码失败：校验令牌失败",
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		return
+	}
+
+	if err := h.userService.ResetPassword(userID, req.NewPassword); err != nil {
+		if respondPolicyError(c, "new_password", err) {
+			return
+		}
+		h.logger.Error("重置密码失败：更新密码失败",
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		return
+	}
+
+	// 吊销该用户此前签发的全部令牌和会话
+	if err := h.tokenManager.RevokeAllForUser(c.Request.Context(), userID); err != nil {
+		h.logger.Error("重置密码后吊销令牌失败",
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码已重置，但注销其他设备失败"})
+		return
+	}
+	if _, err := h.sessionManager.DeleteAllUserSessions(c.Request.Context(), userID); err != nil {
+		h.logger.Warn("删除登录会话失败",
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+	}
+	// 能通过邮箱重置密码说明是账号本人，解除登录失败锁定
+	if err := h.lockoutManager.Unlock(c.Request.Context(), userID); err != nil {
+		h.logger.Warn("重置密码后解除账号锁定失败",
+			zap.Uint("user_id", userID),
+			zap.Error(err),
+		)
+	}
+
+	h.logger.Info("用户已重置密码",
+		zap.Uint("user_id", userID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已重置，请使用新密码登录"})
+}
+
+// sendResetEmail 查询邮箱对应的用户并发送重置密码邮件
+func (h *PasswordHandler) sendResetEmail(email, clientIP string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	u, err := h.userService.GetByEmail(email)
+	if err != nil {
+		h.logger.Info("忘记密码：邮箱未注册",
+			zap.String("email", email),
+			zap.String("client_ip", clientIP),
+		)
+		return
+	}
+	if u.Password == "" {
+		// 仅通过第三方登录的账号没有密码，无需重置
+		h.logger.Info("忘记密码：账号未设置密码",
+			zap.Uint("user_id", u.ID),
+			zap.String("client_ip", clientIP),
+		)
+		return
+	}
+
+	resetToken, err := h.resetManager.Issue(u.ID, u.Password)
+	if err != nil {
+		h.logger.Error("发送重置密码邮件失败：签发令牌失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	link := h.config.UI.BaseURL + h.config.PasswordReset.Path + "?token=" + url.QueryEscape(resetToken)
+	minutes := int(h.resetManager.TTL().Minutes())
+	if err := h.mailSender.Send(ctx, &mail.Message{
+		To:      u.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s，您好：\n\n我们收到了重置您账号密码的请求，请在 %d 分钟内打开以下链接设置新密码（链接只能使用一次）：\n\n%s\n\n如非本人操作，请忽略本邮件，您的密码不会被修改。\n",
+			u.Username, minutes, link),
+	}); err != nil {
+		h.logger.Error("发送重置密码邮件失败",
+			zap.Uint("user_id", u.ID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	h.logger.Info("重置密码邮件已发送",
+		zap.Uint("user_id", u.ID),
+		zap.String("client_ip", clientIP),
+	)
+}
+
+// respondPolicyError 密码不符合策略时返回 400 及违反的规则列表，供前端展示在对应输入框旁；返回是否已处理
+func respondPolicyError(c *gin.Context, field string, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "密码不符合安全要求",
+		"field":      field,
+		"violations": policyErr.Violations,
+	})
+	return true
+}
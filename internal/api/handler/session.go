@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"auth-service/internal/config"
+	"auth-service/internal/domain/user"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/session"
+	"auth-service/pkg/token"
+)
+
+// SessionResponse 登录会话响应结构体
+type SessionResponse struct {
+	*session.UserSession
+	Current bool `json:"current"` // 是否为当前请求所属的会话
+}
+
+// SessionHandler 登录会话（设备）管理处理器
+type SessionHandler struct {
+	sessionManager *session.Manager
+	tokenManager   *token.Manager
+	logger         *logger.ZapLogger
+}
+
+// NewSessionHandler 创建登录会话管理处理器实例
+func NewSessionHandler(sessionManager *session.Manager, tokenManager *token.Manager, logger *logger.ZapLogger) *SessionHandler {
+	return &SessionHandler{
+		sessionManager: sessionManager,
+		tokenManager:   tokenManager,
+		logger:         logger,
+	}
+}
+
+// ListSessions 查询当前用户的登录会话
+// @Summary 查询登录设备
+// @Description 列出当前用户所有有效的登录会话，包括设备、IP、登录方式和最近活跃时间
+// @Tags session
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} gin.H{sessions:[]SessionResponse}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/sessions [get]
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("查询登录会话失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	sessions, err := h.sessionManager.ListUserSessions(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.logger.Error("查询登录会话失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询登录会话失败"})
+		return
+	}
+
+	resp := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, SessionResponse{
+			UserSession: s,
+			Current:     s.ID == claims.SessionID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": resp})
+}
+
+// RevokeSession 吊销指定登录会话
+// @Summary 下线登录设备
+// @Description 吊销指定会话，该设备上的访问令牌和刷新令牌立即失效
+// @Tags session
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "会话ID"
+// @Success 200 {object} gin.H{message:string}
+// @Failure 401 {object} gin.H{error:string}
+// @Failure 404 {object} gin.H{error:string}
+// @Failure 500 {object} gin.H{error:string}
+// @Router /auth/sessions/{id} [delete]
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	claims, ok := currentClaims(c)
+	if !ok {
+		h.logger.Warn("吊销登录会话失败：JWT中间件未设置claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	sessionID := c.Param("id")
+	s, err := h.sessionManager.GetUserSession(c.Request.Context(), sessionID)
+	// 不属于当前用户的会话同样返回 404，避免泄露会话是否存在
+	if errors.Is(err, session.ErrSessionNotFound) || (err == nil && s.UserID != claims.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+		return
+	}
+	if err == nil {
+		err = revokeUserSession(c, h.sessionManager, h.tokenManager, claims.UserID, sessionID)
+	}
+	if err != nil {
+		h.logger.Error("吊销登录会话失败",
+			zap.Uint("user_id", claims.UserID),
+			zap.String("session_id", sessionID),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销登录会话失败"})
+		return
+	}
+
+	h.logger.Info("登录会话已吊销",
+		zap.Uint("user_id", claims.UserID),
+		zap.String("session_id", sessionID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "设备已下线"})
+}
+
+// startUserSession 为登录成功的用户创建登录会话并签发令牌
+func startUserSession(c *gin.Context, sessionManager *session.Manager, tokenManager *token.Manager, cfg *config.Config, u *user.User, authMethod, deviceName string) (*token.Pair, error) {
+	s, err := sessionManager.CreateUserSession(
+		c.Request.Context(),
+		u.ID,
+		deviceName,
+		c.GetHeader("User-Agent"),
+		c.ClientIP(),
+		authMethod,
+		cfg.JWT.RefreshTokenTTL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenManager.Issue(c.Request.Context(), u.ID, u.Username, s.ID)
+}
+
+// revokeUserSession 吊销登录会话及其令牌族
+func revokeUserSession(c *gin.Context, sessionManager *session.Manager, tokenManager *token.Manager, userID uint, sessionID string) error {
+	if err := tokenManager.RevokeFamily(c.Request.Context(), sessionID); err != nil {
+		return err
+	}
+	return sessionManager.DeleteUserSession(c.Request.Context(), userID, sessionID)
+}
+
+// revokeOtherUserSessions 吊销用户除 keepSessionID 以外的全部登录会话，返回吊销的会话数
+func revokeOtherUserSessions(c *gin.Context, sessionManager *session.Manager, tokenManager *token.Manager, userID uint, keepSessionID string) (int, error) {
+	sessions, err := sessionManager.ListUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, s := range sessions {
+		if s.ID == keepSessionID {
+			continue
+		}
+		if err := revokeUserSession(c, sessionManager, tokenManager, userID, s.ID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
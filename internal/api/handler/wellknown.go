@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"auth-service/pkg/jwt"
+)
+
+// WellKnownHandler 公开元数据处理器
+type WellKnownHandler struct {
+	keys jwt.KeyProvider
+}
+
+// NewWellKnownHandler 创建公开元数据处理器实例
+func NewWellKnownHandler(keys jwt.KeyProvider) *WellKnownHandler {
+	return &WellKnownHandler{
+		keys: keys,
+	}
+}
+
+// JWKS 发布令牌验证公钥
+// @Summary JWKS
+// @Description 发布当前所有非对称签名密钥的公钥，供其他服务验证令牌；对称密钥不会公开
+// @Tags well-known
+// @Produce json
+// @Success 200 {object} jwt.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *WellKnownHandler) JWKS(c *gin.Context) {
+	// 允许下游服务短时间缓存公钥
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
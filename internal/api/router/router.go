@@ -3,6 +3,8 @@ package router
 import (
 	"auth-service/internal/api/handler"
 	"auth-service/internal/api/handler/middleware"
+	"auth-service/internal/config"
+	"auth-service/pkg/token"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,29 +13,111 @@ import (
 func Setup(r *gin.Engine,
 	authHandler *handler.AuthHandler,
 	oauth2Handler *handler.OAuth2Handler,
-	jwtSecret string) {
+	wellKnownHandler *handler.WellKnownHandler,
+	adminHandler *handler.AdminHandler,
+	introspectionHandler *handler.IntrospectionHandler,
+	sessionHandler *handler.SessionHandler,
+	mfaHandler *handler.MFAHandler,
+	passkeyHandler *handler.PasskeyHandler,
+	passwordHandler *handler.PasswordHandler,
+	accountHandler *handler.AccountHandler,
+	rateLimiter *middleware.RateLimiter,
+	tokenManager *token.Manager,
+	cfg *config.Config) {
 	// 应用全局安全中间件
 	r.Use(middleware.SecurityHeaders()) // 安全头部中间件
 	r.Use(middleware.HTTPSOnly())       // 强制HTTPS中间件
 
+	// 公开元数据（允许缓存）
+	r.GET("/.well-known/jwks.json", wellKnownHandler.JWKS)
+
 	// 公开路由（无需登录）
 	public := r.Group("/auth")
 	public.Use(middleware.NoCache()) // 认证相关接口不缓存
 	{
 		// 传统认证路由
-		public.POST("/login", authHandler.Login)       // 登录
-		public.POST("/register", authHandler.Register) // 注册
+		public.POST("/login", rateLimiter.For("login"), authHandler.Login)                                   // 登录
+		public.GET("/login/captcha", rateLimiter.For("login_captcha"), authHandler.LoginCaptcha)             // 查询登录是否需要人机验证
+		public.GET("/captcha/challenge", rateLimiter.For("captcha_challenge"), authHandler.CaptchaChallenge) // 获取内置人机验证挑战
+		public.POST("/register", rateLimiter.For("register"), authHandler.Register)                          // 注册
+		public.POST("/email/code", rateLimiter.For("email_code"), authHandler.SendEmailCode)                 // 发送邮箱验证码
+		public.POST("/token/refresh", rateLimiter.For("token_refresh"), authHandler.RefreshToken)            // 刷新令牌
+
+		// 找回密码
+		public.POST("/password/forgot", rateLimiter.For("password_forgot"), passwordHandler.ForgotPassword) // 发送重置密码邮件
+		public.POST("/password/reset", rateLimiter.For("password_reset"), passwordHandler.ResetPassword)    // 重置密码
+
+		// 两步验证登录（密码验证通过后）
+		public.POST("/login/mfa", rateLimiter.For("login_mfa"), authHandler.LoginMFA)                              // TOTP 验证码或恢复码
+		public.POST("/login/mfa/webauthn/begin", rateLimiter.For("login_mfa_webauthn"), passkeyHandler.BeginMFA)   // 开始安全密钥验证
+		public.POST("/login/mfa/webauthn/finish", rateLimiter.For("login_mfa_webauthn"), passkeyHandler.FinishMFA) // 完成安全密钥验证
+
+		// 通行密钥免密登录
+		public.POST("/passkey/login/begin", rateLimiter.For("passkey_login"), passkeyHandler.BeginLogin)
+		public.POST("/passkey/login/finish", rateLimiter.For("passkey_login"), passkeyHandler.FinishLogin)
 
 		// OAuth2 认证路由
 		public.GET("/oauth2/github/login", oauth2Handler.GitHubLogin)
 		public.GET("/oauth2/github/callback", oauth2Handler.GitHubCallback)
+		public.GET("/oauth2/:provider/login", oauth2Handler.OIDCLogin)                                 // 通用 OpenID Connect 登录，provider 为配置中的名称
+		public.GET("/oauth2/:provider/callback", oauth2Handler.OIDCCallback)                           // 通用 OpenID Connect 回调
+		public.POST("/oauth2/token", rateLimiter.For("oauth2_token"), oauth2Handler.ExchangeLoginCode) // 兑换一次性登录码
 	}
 
 	// 需认证的路由（JWT 验证）
 	protected := r.Group("/auth")
-	protected.Use(middleware.JWTAuth(jwtSecret)) // 传入JWT密钥
-	protected.Use(middleware.NoCache())          // 禁用缓存
+	protected.Use(middleware.JWTAuth(tokenManager)) // 传入令牌管理器
+	protected.Use(middleware.NoCache())             // 禁用缓存
+	{
+		protected.GET("/user/me", authHandler.GetCurrentUser)                                              // 获取当前用户信息
+		protected.PUT("/user/password", rateLimiter.For("password_change"), accountHandler.ChangePassword) // 修改密码
+		protected.PUT("/user/email", accountHandler.ChangeEmail)                                           // 发起修改邮箱
+		protected.POST("/user/email/confirm", accountHandler.ConfirmEmailChange)                           // 确认修改邮箱
+
+		// 外部账号关联
+		protected.GET("/user/identities", oauth2Handler.ListIdentities)          // 查询已关联的外部账号
+		protected.GET("/user/identities/pending", oauth2Handler.GetPendingLink)  // 查询待确认的关联
+		protected.POST("/user/identities/confirm", oauth2Handler.ConfirmLink)    // 确认关联（外部账号邮箱与本账号一致时）
+		protected.POST("/user/identities/:provider", oauth2Handler.LinkIdentity) // 发起关联外部账号
+		protected.DELETE("/user/identities/:id", oauth2Handler.UnlinkIdentity)   // 解除关联
+
+		protected.POST("/logout", authHandler.Logout)                   // 注销当前令牌
+		protected.POST("/logout-all", authHandler.LogoutAll)            // 注销全部设备
+		protected.GET("/sessions", sessionHandler.ListSessions)         // 查询登录设备
+		protected.DELETE("/sessions/:id", sessionHandler.RevokeSession) // 下线指定设备
+
+		// 两步验证管理
+		protected.GET("/mfa", mfaHandler.Status)                                                                 // 查询两步验证状态
+		protected.POST("/mfa/totp/enroll", rateLimiter.For("mfa_manage"), mfaHandler.EnrollTOTP)                 // 绑定 TOTP（已启用时为重新绑定）
+		protected.POST("/mfa/totp/confirm", rateLimiter.For("mfa_manage"), mfaHandler.ConfirmTOTP)               // 确认绑定 TOTP
+		protected.POST("/mfa/totp/disable", rateLimiter.For("mfa_manage"), mfaHandler.DisableTOTP)               // 关闭两步验证
+		protected.POST("/mfa/recovery-codes", rateLimiter.For("mfa_manage"), mfaHandler.RegenerateRecoveryCodes) // 重新生成恢复码
+
+		// 通行密钥管理
+		protected.GET("/passkeys", passkeyHandler.ListPasskeys)                                                           // 查询通行密钥
+		protected.POST("/passkeys/verify/begin", rateLimiter.For("passkey_register"), passkeyHandler.BeginStepUp)         // 使用已有通行密钥验证身份
+		protected.POST("/passkeys/register/begin", rateLimiter.For("passkey_register"), passkeyHandler.BeginRegistration) // 开始注册通行密钥（需再次验证身份）
+		protected.POST("/passkeys/register/finish", passkeyHandler.FinishRegistration)                                    // 完成注册通行密钥
+		protected.DELETE("/passkeys/:id", passkeyHandler.DeletePasskey)                                                   // 删除通行密钥
+	}
+
+	// 资源服务器接口（客户端凭证验证）
+	resource := r.Group("/oauth2")
+	resource.Use(middleware.ClientAuth(cfg.OAuth2.ResourceServers))
+	resource.Use(middleware.NoCache())
+	{
+		resource.POST("/introspect", introspectionHandler.Introspect) // 令牌内省（RFC 7662）
+		resource.POST("/revoke", introspectionHandler.Revoke)         // 令牌吊销（RFC 7009）
+	}
+
+	// 管理接口（管理密钥验证）
+	admin := r.Group("/admin")
+	admin.Use(middleware.AdminAuth(cfg.Admin.APIKey))
+	admin.Use(middleware.NoCache())
 	{
-		protected.GET("/user/me", authHandler.GetCurrentUser) // 获取当前用户信息
+		admin.GET("/keys", adminHandler.ListSigningKeys)             // 查询签名密钥
+		admin.POST("/keys/rotate", adminHandler.RotateSigningKey)    // 轮换签名密钥
+		admin.GET("/users/:id/lockout", adminHandler.GetUserLockout) // 查询账号锁定状态
+		admin.POST("/users/:id/unlock", adminHandler.UnlockUser)     // 解除账号锁定
 	}
 }
@@ -3,20 +3,34 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config 应用配置
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	DB       DBConfig       `mapstructure:"db"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Log      LogConfig      `mapstructure:"log"`
-	OAuth2   OAuth2Config   `mapstructure:"oauth2"`
-	UI       UIConfig       `mapstructure:"ui"`       // 新增 UI 配置
-	HCaptcha HCaptchaConfig `mapstructure:"hcaptcha"` // 新增 hCaptcha 配置
+	Server          ServerConfig          `mapstructure:"server"`
+	DB              DBConfig              `mapstructure:"db"`
+	Redis           RedisConfig           `mapstructure:"redis"`
+	JWT             JWTConfig             `mapstructure:"jwt"`
+	Log             LogConfig             `mapstructure:"log"`
+	OAuth2          OAuth2Config          `mapstructure:"oauth2"`
+	UI              UIConfig              `mapstructure:"ui"`               // 新增 UI 配置
+	HCaptcha        HCaptchaConfig        `mapstructure:"hcaptcha"`         // 已弃用，仅在未配置 captcha 时兼容读取
+	Captcha         CaptchaConfig         `mapstructure:"captcha"`          // 人机验证配置
+	AdaptiveCaptcha AdaptiveCaptchaConfig `mapstructure:"adaptive_captcha"` // 按登录风险要求人机验证
+	Admin           AdminConfig           `mapstructure:"admin"`            // 管理接口配置
+	MFA             MFAConfig             `mapstructure:"mfa"`              // 两步验证配置
+	WebAuthn        WebAuthnConfig        `mapstructure:"webauthn"`         // WebAuthn / 通行密钥配置
+	Mail            MailConfig            `mapstructure:"mail"`             // 邮件发送配置
+	EmailCode       EmailCodeConfig       `mapstructure:"email_code"`       // 邮箱验证码配置
+	PasswordReset   PasswordResetConfig   `mapstructure:"password_reset"`   // 密码重置配置
+	PasswordHash    PasswordHashConfig    `mapstructure:"password_hash"`    // 密码哈希配置
+	PasswordPolicy  PasswordPolicyConfig  `mapstructure:"password_policy"`  // 密码策略配置
+	RateLimit       RateLimitConfig       `mapstructure:"rate_limit"`       // 接口限流配置
+	Lockout         LockoutConfig         `mapstructure:"lockout"`          // 登录失败锁定配置
 }
 
 // RedisConfig Redis 配置
@@ -52,7 +66,135 @@ type DBConfig struct {
 
 // JWTConfig JWT配置
 type JWTConfig struct {
-	Secret string `mapstructure:"secret"` // JWT签名密钥
+	Secret          string            `mapstructure:"secret"`            // JWT签名密钥（HMAC）
+	Algorithm       string            `mapstructure:"algorithm"`         // 签名算法：HS256, RS256, ES256, EdDSA 等，为空时按密钥类型推断
+	KeyID           string            `mapstructure:"key_id"`            // 密钥ID（kid），为空时非对称密钥由公钥派生，HMAC 密钥使用固定值
+	PrivateKeyFile  string            `mapstructure:"private_key_file"`  // 非对称签名私钥 PEM 文件路径，配置后优先于 Secret
+	Scope           string            `mapstructure:"scope"`             // 访问令牌的 scope，以空格分隔，为空时不写入
+	Audience        []string          `mapstructure:"audience"`          // 访问令牌的 aud：接收令牌的资源服务器 client_id，只有这些资源服务器可以吊销令牌
+	AccessTokenTTL  time.Duration     `mapstructure:"access_token_ttl"`  // 访问令牌有效期，如"15m"
+	RefreshTokenTTL time.Duration     `mapstructure:"refresh_token_ttl"` // 刷新令牌有效期，如"720h"
+	Rotation        KeyRotationConfig `mapstructure:"rotation"`          // 签名密钥轮换
+
+	// LegacySecretUntil 改用私钥文件或密钥轮换后，旧 HMAC Secret 签发的令牌的验证截止时间（RFC 3339，如"2026-11-01T00:00:00Z"）
+	// 同时配置私钥文件和 Secret 时必须设置；启用密钥轮换时为空表示首个轮换密钥生效后再过一个访问令牌有效期
+	LegacySecretUntil string `mapstructure:"legacy_secret_until"`
+}
+
+// KeyRotationConfig 签名密钥轮换配置
+type KeyRotationConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`           // 是否启用密钥环（密钥保存在数据库中）
+	Algorithm        string        `mapstructure:"algorithm"`         // 新生成密钥的算法：RS256, ES256, EdDSA 等
+	Interval         time.Duration `mapstructure:"interval"`          // 自动轮换周期，0 表示只能由管理员触发
+	PropagationDelay time.Duration `mapstructure:"propagation_delay"` // 新密钥预发布多久后开始签名，不能小于 ReloadInterval
+	ReloadInterval   time.Duration `mapstructure:"reload_interval"`   // 各副本从数据库重新加载密钥的周期
+	EncryptionKey    string        `mapstructure:"encryption_key"`    // 加密数据库中私钥的 AES-256 密钥（base64 编码的 32 字节），也可通过环境变量 JWT_KEY_ENCRYPTION_KEY 配置
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	APIKey string `mapstructure:"api_key"` // 管理接口访问密钥，为空时禁用管理接口
+}
+
+// MFAConfig 两步验证配置
+type MFAConfig struct {
+	Issuer       string        `mapstructure:"issuer"`        // 认证器应用中显示的服务名称
+	ChallengeTTL time.Duration `mapstructure:"challenge_ttl"` // 密码验证通过后提交第二因子的时限，如"5m"
+}
+
+// WebAuthnConfig WebAuthn（通行密钥、安全密钥）配置
+type WebAuthnConfig struct {
+	RPID          string        `mapstructure:"rp_id"`           // 依赖方ID，即站点域名，如"example.com"
+	RPDisplayName string        `mapstructure:"rp_display_name"` // 认证器中显示的站点名称
+	RPOrigins     []string      `mapstructure:"rp_origins"`      // 允许发起认证的前端源，如"https://example.com"
+	Timeout       time.Duration `mapstructure:"timeout"`         // 注册和认证仪式的有效期，如"5m"
+}
+
+// MailConfig 邮件发送配置
+type MailConfig struct {
+	Driver  string     `mapstructure:"driver"`   // 发送方式：smtp, file, memory
+	From    string     `mapstructure:"from"`     // 发件人，如"Auth Service <noreply@example.com>"
+	FileDir string     `mapstructure:"file_dir"` // driver 为 file 时邮件的保存目录
+	SMTP    SMTPConfig `mapstructure:"smtp"`
+}
+
+// SMTPConfig SMTP 服务器配置
+type SMTPConfig struct {
+	Host        string        `mapstructure:"host"`
+	Port        string        `mapstructure:"port"`
+	Username    string        `mapstructure:"username"`
+	Password    string        `mapstructure:"password"`
+	Security    string        `mapstructure:"security"`     // 加密方式：starttls（默认）, tls, none
+	DialTimeout time.Duration `mapstructure:"dial_timeout"` // 建立 TCP 连接的超时时间
+	Timeout     time.Duration `mapstructure:"timeout"`      // 单封邮件从连接到发送完成的总超时时间，防止服务器无响应时请求一直阻塞
+}
+
+// EmailCodeConfig 邮箱验证码配置
+type EmailCodeConfig struct {
+	TTL            time.Duration `mapstructure:"ttl"`             // 验证码有效期，如"10m"
+	MaxAttempts    int           `mapstructure:"max_attempts"`    // 每个验证码允许的最大尝试次数
+	ResendInterval time.Duration `mapstructure:"resend_interval"` // 同一邮箱两次发送的最小间隔，如"60s"
+}
+
+// PasswordResetConfig 密码重置配置
+type PasswordResetConfig struct {
+	Secret   string        `mapstructure:"secret"`    // 重置令牌签名密钥
+	TokenTTL time.Duration `mapstructure:"token_ttl"` // 重置链接有效期，如"30m"
+	Path     string        `mapstructure:"path"`      // 前端重置密码页面路径，链接为 ui.base_url + path + "?token=..."
+	Cooldown time.Duration `mapstructure:"cooldown"`  // 同一邮箱两次发送重置邮件的最小间隔
+}
+
+// PasswordHashConfig 密码哈希配置，修改算法或参数后，旧哈希会在用户下次登录时自动升级
+type PasswordHashConfig struct {
+	Algorithm string       `mapstructure:"algorithm"` // 新密码使用的算法：argon2id, scrypt, bcrypt
+	Argon2    Argon2Config `mapstructure:"argon2"`
+	Scrypt    ScryptConfig `mapstructure:"scrypt"`
+	Bcrypt    BcryptConfig `mapstructure:"bcrypt"`
+}
+
+// Argon2Config argon2id 参数
+type Argon2Config struct {
+	Memory      uint32 `mapstructure:"memory"`      // 内存开销（KiB）
+	Iterations  uint32 `mapstructure:"iterations"`  // 迭代次数
+	Parallelism uint8  `mapstructure:"parallelism"` // 并行度
+	SaltLength  int    `mapstructure:"salt_length"` // 盐长度（字节）
+	KeyLength   uint32 `mapstructure:"key_length"`  // 哈希长度（字节）
+}
+
+// ScryptConfig scrypt 参数
+type ScryptConfig struct {
+	LogN       uint8 `mapstructure:"log_n"`       // CPU/内存开销 N 的以2为底的对数
+	R          int   `mapstructure:"r"`           // 块大小
+	P          int   `mapstructure:"p"`           // 并行度
+	SaltLength int   `mapstructure:"salt_length"` // 盐长度（字节）
+	KeyLength  int   `mapstructure:"key_length"`  // 哈希长度（字节）
+}
+
+// BcryptConfig bcrypt 参数
+type BcryptConfig struct {
+	Cost int `mapstructure:"cost"` // 开销因子，4~31
+}
+
+// PasswordPolicyConfig 密码策略配置，注册、重置密码和修改密码时生效
+type PasswordPolicyConfig struct {
+	MinLength           int          `mapstructure:"min_length"`            // 最小长度（字符数）
+	MaxBytes            int          `mapstructure:"max_bytes"`             // 最大字节数，bcrypt 只使用前 72 字节；0 表示不限制
+	RequireLower        bool         `mapstructure:"require_lower"`         // 必须包含小写字母
+	RequireUpper        bool         `mapstructure:"require_upper"`         // 必须包含大写字母
+	RequireDigit        bool         `mapstructure:"require_digit"`         // 必须包含数字
+	RequireSymbol       bool         `mapstructure:"require_symbol"`        // 必须包含特殊字符
+	MinCharClasses      int          `mapstructure:"min_char_classes"`      // 大写、小写、数字、特殊字符中至少包含的种类数
+	ForbidUserInfo      bool         `mapstructure:"forbid_user_info"`      // 禁止包含用户名或邮箱前缀
+	CommonPasswordsFile string       `mapstructure:"common_passwords_file"` // 常见密码列表文件（每行一个），为空时使用内置列表
+	HistorySize         int          `mapstructure:"history_size"`          // 禁止重复使用最近 N 个密码，0 表示不检查
+	Breach              BreachConfig `mapstructure:"breach"`                // 泄露密码库（离线）
+}
+
+// BreachConfig 离线泄露密码库配置（Pwned Passwords）
+type BreachConfig struct {
+	Source   string `mapstructure:"source"`    // 格式：range（按 SHA-1 前5位分片的目录）、bloom（authctl build-pwned-bloom 生成的文件），为空表示不检查
+	Path     string `mapstructure:"path"`      // 目录或文件路径
+	MinCount int    `mapstructure:"min_count"` // range 格式：出现次数不少于该值才视为泄露（bloom 格式在构建时指定）
 }
 
 // LogConfig 日志配置
@@ -63,13 +205,45 @@ type LogConfig struct {
 // UIConfig 前端页面配置
 type UIConfig struct {
 	BaseURL          string `mapstructure:"base_url"`           // 前端基础URL
-	LoginSuccessPath string `mapstructure:"login_success_path"` // 登录成功页面路径
+	LoginSuccessPath string `mapstructure:"login_success_path"` // 外部账号登录成功页面路径，参数 code 为一次性登录码，前端调用 /auth/oauth2/token 换取令牌
 	LoginErrorPath   string `mapstructure:"login_error_path"`   // 登录失败页面路径
+	LinkResultPath   string `mapstructure:"link_result_path"`   // 关联外部账号结果页面路径，参数为 provider 和 linked 或 error
+	LinkConfirmPath  string `mapstructure:"link_confirm_path"`  // 外部账号邮箱与已有用户一致时的确认页面路径，用户登录原账号后确认关联
 }
 
 // OAuth2Config OAuth2 配置
 type OAuth2Config struct {
-	GitHub GitHubOAuth2Config `mapstructure:"github"`
+	GitHub          GitHubOAuth2Config            `mapstructure:"github"`
+	Providers       map[string]OIDCProviderConfig `mapstructure:"providers"`        // 通用 OpenID Connect 登录，键为路由中的 provider 名称（小写字母、数字和连字符）
+	ResourceServers []ResourceServerConfig        `mapstructure:"resource_servers"` // 允许调用令牌内省/吊销接口的资源服务器，只能吊销 aud 包含其 client_id 的访问令牌
+}
+
+// OIDCProviderConfig OpenID Connect 提供方配置，如 Google、Microsoft Entra、Keycloak、GitLab
+// 服务地址通过 {issuer}/.well-known/openid-configuration 自动发现
+type OIDCProviderConfig struct {
+	DisplayName  string           `mapstructure:"display_name"` // 显示名称，为空时使用 provider 名称
+	Issuer       string           `mapstructure:"issuer"`       // 签发者地址，必须与 ID Token 的 iss 完全一致
+	ClientID     string           `mapstructure:"client_id"`
+	ClientSecret string           `mapstructure:"client_secret"` // 也可通过环境变量 OAUTH2_<PROVIDER>_CLIENT_SECRET 配置
+	RedirectURL  string           `mapstructure:"redirect_url"`  // 回调地址：{服务地址}/auth/oauth2/{provider}/callback
+	Scopes       []string         `mapstructure:"scopes"`        // 为空时使用 openid email profile
+	UserInfo     bool             `mapstructure:"userinfo"`      // 是否从 userinfo 接口补充 ID Token 中缺少的声明
+	Claims       OIDCClaimMapping `mapstructure:"claims"`        // 声明与用户字段的对应关系
+}
+
+// OIDCClaimMapping 声明映射，值为声明名称，嵌套声明用 . 分隔（如 realm_access.username）；为空时使用标准声明
+type OIDCClaimMapping struct {
+	Username      string `mapstructure:"username"`       // 默认 preferred_username，缺失时取邮箱前缀
+	Email         string `mapstructure:"email"`          // 默认 email
+	EmailVerified string `mapstructure:"email_verified"` // 默认 email_verified；Entra 等不返回该声明的提供方可配置为其他布尔声明
+	Name          string `mapstructure:"name"`           // 默认 name
+	Picture       string `mapstructure:"picture"`        // 默认 picture
+}
+
+// ResourceServerConfig 资源服务器客户端凭证
+type ResourceServerConfig struct {
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
 }
 
 // GitHubOAuth2Config GitHub OAuth2 配置
@@ -79,13 +253,84 @@ type GitHubOAuth2Config struct {
 	RedirectURL  string `mapstructure:"redirect_url"`
 }
 
-// HCaptchaConfig hCaptcha 配置
+// CaptchaConfig 人机验证配置
+type CaptchaConfig struct {
+	Provider  string        `mapstructure:"provider"`   // hcaptcha（默认）、recaptcha、turnstile、slider（内置滑块）、pow（工作量证明）
+	Enabled   bool          `mapstructure:"enabled"`    // 是否启用验证
+	SiteKey   string        `mapstructure:"site_key"`   // 站点密钥（前端使用）
+	SecretKey string        `mapstructure:"secret_key"` // 服务端密钥；pow 用于签名挑战
+	VerifyURL string        `mapstructure:"verify_url"` // 校验接口地址，为空时使用服务商默认地址；可指向国内镜像或本地测试服务
+	Timeout   time.Duration `mapstructure:"timeout"`    // 校验请求超时时间
+	MinScore  float64       `mapstructure:"min_score"`  // reCAPTCHA v3：低于该分数（0~1）视为机器人，大于 0 时拒绝没有评分的 v2 令牌；使用 v2 时设为 0
+	Action    string        `mapstructure:"action"`     // reCAPTCHA v3 / Turnstile：期望的 action，为空时不检查
+	Slider    SliderConfig  `mapstructure:"slider"`     // 内置滑块验证
+	PoW       PoWConfig     `mapstructure:"pow"`        // 工作量证明
+}
+
+// PoWConfig 工作量证明配置，难度为哈希值要求的前导零位数，每增加一位客户端平均计算量翻倍
+type PoWConfig struct {
+	Difficulty      int           `mapstructure:"difficulty"`        // 基础难度
+	MaxDifficulty   int           `mapstructure:"max_difficulty"`    // 难度上限
+	FailuresPerStep int           `mapstructure:"failures_per_step"` // 全站每分钟登录失败每达到该次数，难度加一；0 表示固定难度
+	TTL             time.Duration `mapstructure:"ttl"`               // 挑战有效期
+}
+
+// SliderConfig 内置滑块验证配置
+type SliderConfig struct {
+	Width       int           `mapstructure:"width"`        // 背景图宽度（像素）
+	Height      int           `mapstructure:"height"`       // 背景图高度（像素）
+	PieceSize   int           `mapstructure:"piece_size"`   // 拼图块边长（不含凸起）
+	Tolerance   int           `mapstructure:"tolerance"`    // 允许的位置误差（像素）
+	MinDuration time.Duration `mapstructure:"min_duration"` // 拖动耗时下限，过快视为程序操作
+	TTL         time.Duration `mapstructure:"ttl"`          // 挑战有效期
+}
+
+// HCaptchaConfig hCaptcha 配置（已弃用，请使用 CaptchaConfig）
 type HCaptchaConfig struct {
 	SecretKey string `mapstructure:"secret_key"`
 	SiteKey   string `mapstructure:"site_key"`
 	Enabled   bool   `mapstructure:"enabled"` // 是否启用验证
 }
 
+// AdaptiveCaptchaConfig 自适应人机验证配置：只在登录存在风险时要求人机验证
+type AdaptiveCaptchaConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`           // 关闭时每次登录都要求人机验证
+	FailureThreshold int           `mapstructure:"failure_threshold"` // IP 或账号在统计窗口内登录失败达到该次数后要求验证
+	FailureWindow    time.Duration `mapstructure:"failure_window"`    // 登录失败统计窗口
+	VelocityLimit    int           `mapstructure:"velocity_limit"`    // 同一 IP 在 VelocityWindow 内的登录请求超过该次数后要求验证，0 表示不检查
+	VelocityWindow   time.Duration `mapstructure:"velocity_window"`   // 请求频率统计窗口
+	NewDevice        bool          `mapstructure:"new_device"`        // 没有成功登录过该账号的设备要求验证
+	DeviceTTL        time.Duration `mapstructure:"device_ttl"`        // 设备成功登录后免验证的有效期
+}
+
+// LockoutConfig 登录失败锁定配置
+type LockoutConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	AccountThreshold int           `mapstructure:"account_threshold"` // 同一账号连续失败多少次后锁定
+	IPThreshold      int           `mapstructure:"ip_threshold"`      // 同一 IP 连续失败多少次后锁定
+	FailureWindow    time.Duration `mapstructure:"failure_window"`    // 失败次数统计窗口，窗口内没有新的失败时清零
+	BaseDuration     time.Duration `mapstructure:"base_duration"`     // 首次锁定时长，之后每次锁定翻倍
+	MaxDuration      time.Duration `mapstructure:"max_duration"`      // 单次锁定的最长时长
+	PermanentAfter   int           `mapstructure:"permanent_after"`   // 账号累计锁定多少次后永久锁定（需管理员解锁或重置密码），0（默认）表示不永久锁定
+	History          time.Duration `mapstructure:"history"`           // 累计锁定次数的保留时长，期间没有再次锁定时清零
+}
+
+// RateLimitConfig 接口限流配置
+type RateLimitConfig struct {
+	Enabled bool                       `mapstructure:"enabled"`
+	Backend string                     `mapstructure:"backend"` // redis（默认，多实例共享）或 memory（仅当前进程）
+	Routes  map[string][]RateLimitRule `mapstructure:"routes"`  // 键为路由名：login、login_captcha、captcha_challenge、login_mfa、login_mfa_webauthn、passkey_login、oauth2_token、token_refresh、register、email_code、password_forgot、password_reset、password_change、passkey_register、mfa_manage
+}
+
+// RateLimitRule 限流规则：按 By 中的维度组合计数，平均每 Period 允许 Limit 次请求
+// 同一路由可配置多条规则，任意一条超限即拒绝
+type RateLimitRule struct {
+	By     []string      `mapstructure:"by"`     // 计数维度：ip、username、email、user（已登录用户），可组合
+	Limit  int           `mapstructure:"limit"`  // 每个周期允许的请求数
+	Period time.Duration `mapstructure:"period"` // 周期
+	Burst  int           `mapstructure:"burst"`  // 允许连续突发的请求数，0 表示等于 Limit
+}
+
 // Load 加载配置文件
 func Load(configPath ...string) (*Config, error) {
 	var configFile string
@@ -120,6 +365,7 @@ func Load(configPath ...string) (*Config, error) {
 	}
 
 	viper.SetConfigFile(configFile)
+	setDefaults()
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("读取配置文件失败: %w", err)
@@ -130,6 +376,14 @@ func Load(configPath ...string) (*Config, error) {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
 
+	// 兼容旧版 hcaptcha 配置
+	if !viper.InConfig("captcha") && viper.InConfig("hcaptcha") {
+		cfg.Captcha.Provider = "hcaptcha"
+		cfg.Captcha.Enabled = cfg.HCaptcha.Enabled
+		cfg.Captcha.SiteKey = cfg.HCaptcha.SiteKey
+		cfg.Captcha.SecretKey = cfg.HCaptcha.SecretKey
+	}
+
 	// 从环境变量覆盖敏感配置（安全考虑）
 	overrideFromEnv(&cfg)
 
@@ -137,6 +391,131 @@ func Load(configPath ...string) (*Config, error) {
 	return &cfg, nil
 }
 
+// setDefaults 设置可选配置项的默认值
+func setDefaults() {
+	viper.SetDefault("jwt.access_token_ttl", 15*time.Minute)
+	viper.SetDefault("jwt.refresh_token_ttl", 30*24*time.Hour)
+	viper.SetDefault("jwt.rotation.algorithm", "ES256")
+	viper.SetDefault("jwt.rotation.propagation_delay", 5*time.Minute)
+	viper.SetDefault("jwt.rotation.reload_interval", time.Minute)
+	viper.SetDefault("mfa.issuer", "auth-service")
+	viper.SetDefault("mfa.challenge_ttl", 5*time.Minute)
+	viper.SetDefault("webauthn.rp_display_name", "auth-service")
+	viper.SetDefault("webauthn.timeout", 5*time.Minute)
+	viper.SetDefault("mail.driver", "smtp")
+	viper.SetDefault("mail.smtp.port", "587")
+	viper.SetDefault("mail.smtp.dial_timeout", 10*time.Second)
+	viper.SetDefault("mail.smtp.timeout", 30*time.Second)
+	viper.SetDefault("email_code.ttl", 10*time.Minute)
+	viper.SetDefault("email_code.max_attempts", 5)
+	viper.SetDefault("email_code.resend_interval", time.Minute)
+	viper.SetDefault("password_reset.token_ttl", 30*time.Minute)
+	viper.SetDefault("password_reset.path", "/reset-password")
+	viper.SetDefault("password_reset.cooldown", time.Minute)
+	viper.SetDefault("password_hash.algorithm", "argon2id")
+	viper.SetDefault("password_hash.argon2.memory", 64*1024)
+	viper.SetDefault("password_hash.argon2.iterations", 3)
+	viper.SetDefault("password_hash.argon2.parallelism", 2)
+	viper.SetDefault("password_hash.argon2.salt_length", 16)
+	viper.SetDefault("password_hash.argon2.key_length", 32)
+	viper.SetDefault("password_hash.scrypt.log_n", 15)
+	viper.SetDefault("password_hash.scrypt.r", 8)
+	viper.SetDefault("password_hash.scrypt.p", 1)
+	viper.SetDefault("password_hash.scrypt.salt_length", 16)
+	viper.SetDefault("password_hash.scrypt.key_length", 32)
+	viper.SetDefault("password_hash.bcrypt.cost", 12)
+	viper.SetDefault("password_policy.min_length", 8)
+	viper.SetDefault("password_policy.max_bytes", 72)
+	viper.SetDefault("password_policy.min_char_classes", 2)
+	viper.SetDefault("password_policy.forbid_user_info", true)
+	viper.SetDefault("password_policy.history_size", 5)
+	viper.SetDefault("password_policy.breach.min_count", 1)
+	viper.SetDefault("ui.link_result_path", "/account/identities")
+	viper.SetDefault("ui.link_confirm_path", "/account/identities/confirm")
+	viper.SetDefault("captcha.provider", "hcaptcha")
+	viper.SetDefault("captcha.timeout", 10*time.Second)
+	viper.SetDefault("captcha.min_score", 0.5)
+	viper.SetDefault("captcha.slider.width", 320)
+	viper.SetDefault("captcha.slider.height", 160)
+	viper.SetDefault("captcha.slider.piece_size", 44)
+	viper.SetDefault("captcha.slider.tolerance", 4)
+	viper.SetDefault("captcha.slider.min_duration", 300*time.Millisecond)
+	viper.SetDefault("captcha.slider.ttl", 2*time.Minute)
+	viper.SetDefault("captcha.pow.difficulty", 18)
+	viper.SetDefault("captcha.pow.max_difficulty", 24)
+	viper.SetDefault("captcha.pow.failures_per_step", 50)
+	viper.SetDefault("captcha.pow.ttl", 2*time.Minute)
+	viper.SetDefault("adaptive_captcha.enabled", true)
+	viper.SetDefault("adaptive_captcha.failure_threshold", 3)
+	viper.SetDefault("adaptive_captcha.failure_window", time.Hour)
+	viper.SetDefault("adaptive_captcha.velocity_limit", 10)
+	viper.SetDefault("adaptive_captcha.velocity_window", time.Minute)
+	viper.SetDefault("adaptive_captcha.new_device", true)
+	viper.SetDefault("adaptive_captcha.device_ttl", 90*24*time.Hour)
+	viper.SetDefault("lockout.enabled", true)
+	viper.SetDefault("lockout.account_threshold", 5)
+	viper.SetDefault("lockout.ip_threshold", 50)
+	viper.SetDefault("lockout.failure_window", 15*time.Minute)
+	viper.SetDefault("lockout.base_duration", time.Minute)
+	viper.SetDefault("lockout.max_duration", time.Hour)
+	viper.SetDefault("lockout.permanent_after", 0) // 默认关闭，否则知道用户名即可让账号永久无法登录
+	viper.SetDefault("lockout.history", 24*time.Hour)
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.backend", "redis")
+	// 用户名规则与 IP 组合计数：只按用户名计数时，任何人都能用失败请求把已知用户挡在登录之外；
+	// 跨 IP 的撞库由登录失败锁定和自适应人机验证处理
+	viper.SetDefault("rate_limit.routes.login", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 20, "period": time.Minute},
+		{"by": []string{"username", "ip"}, "limit": 10, "period": 15 * time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.login_captcha", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 60, "period": time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.captcha_challenge", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 30, "period": time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.login_mfa", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 20, "period": time.Minute},
+	})
+	// begin 和 finish 共用同一路由名和计数，一次验证计两次
+	viper.SetDefault("rate_limit.routes.login_mfa_webauthn", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 40, "period": time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.passkey_login", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 40, "period": time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.oauth2_token", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 20, "period": time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.token_refresh", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 60, "period": time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.register", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 10, "period": time.Hour},
+	})
+	viper.SetDefault("rate_limit.routes.email_code", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 10, "period": time.Hour},
+		{"by": []string{"email"}, "limit": 5, "period": time.Hour},
+	})
+	viper.SetDefault("rate_limit.routes.password_forgot", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 10, "period": time.Hour},
+		{"by": []string{"email"}, "limit": 5, "period": time.Hour},
+	})
+	viper.SetDefault("rate_limit.routes.password_reset", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 20, "period": time.Hour},
+	})
+	viper.SetDefault("rate_limit.routes.password_change", []map[string]interface{}{
+		{"by": []string{"user"}, "limit": 5, "period": 15 * time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.mfa_manage", []map[string]interface{}{
+		{"by": []string{"ip"}, "limit": 20, "period": time.Minute},
+		{"by": []string{"user"}, "limit": 10, "period": time.Minute},
+	})
+	viper.SetDefault("rate_limit.routes.passkey_register", []map[string]interface{}{
+		{"by": []string{"user"}, "limit": 10, "period": time.Minute},
+	})
+}
+
 // overrideFromEnv 从环境变量覆盖敏感配置
 func overrideFromEnv(cfg *Config) {
 	// 数据库密码
@@ -154,13 +533,46 @@ func overrideFromEnv(cfg *Config) {
 		cfg.JWT.Secret = jwtSecret
 	}
 
+	// 签名密钥加密密钥
+	if keyEncryptionKey := os.Getenv("JWT_KEY_ENCRYPTION_KEY"); keyEncryptionKey != "" {
+		cfg.JWT.Rotation.EncryptionKey = keyEncryptionKey
+	}
+
+	// SMTP 密码
+	if smtpPass := os.Getenv("SMTP_PASSWORD"); smtpPass != "" {
+		cfg.Mail.SMTP.Password = smtpPass
+	}
+
+	// 密码重置令牌签名密钥
+	if resetSecret := os.Getenv("PASSWORD_RESET_SECRET"); resetSecret != "" {
+		cfg.PasswordReset.Secret = resetSecret
+	}
+
+	// 管理接口密钥
+	if adminAPIKey := os.Getenv("ADMIN_API_KEY"); adminAPIKey != "" {
+		cfg.Admin.APIKey = adminAPIKey
+	}
+
 	// GitHub OAuth
 	if githubClientSecret := os.Getenv("GITHUB_CLIENT_SECRET"); githubClientSecret != "" {
 		cfg.OAuth2.GitHub.ClientSecret = githubClientSecret
 	}
+	for name, provider := range cfg.OAuth2.Providers {
+		env := "OAUTH2_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_CLIENT_SECRET"
+		if secret := os.Getenv(env); secret != "" {
+			provider.ClientSecret = secret
+			cfg.OAuth2.Providers[name] = provider
+		}
+	}
 
-	// hCaptcha
+	// 人机验证（HCAPTCHA_SECRET_KEY 为旧版变量名，仅在使用 hCaptcha 时生效）
 	if hcaptchaSecret := os.Getenv("HCAPTCHA_SECRET_KEY"); hcaptchaSecret != "" {
 		cfg.HCaptcha.SecretKey = hcaptchaSecret
+		if cfg.Captcha.Provider == "hcaptcha" {
+			cfg.Captcha.SecretKey = hcaptchaSecret
+		}
+	}
+	if captchaSecret := os.Getenv("CAPTCHA_SECRET_KEY"); captchaSecret != "" {
+		cfg.Captcha.SecretKey = captchaSecret
 	}
 }
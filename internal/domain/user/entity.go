@@ -2,54 +2,83 @@ package user
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"auth-service/pkg/password"
 )
 
 // User 用户实体
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"uniqueIndex;size:50;not null" json:"username"`
-	Email     string    `gorm:"uniqueIndex;size:100;not null" json:"email"`
-	Password  string    `gorm:"size:255" json:"-"` // 对于OAuth2用户，可能为空
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Username string `gorm:"uniqueIndex;size:50;not null" json:"username"`
+	Email    string `gorm:"uniqueIndex;size:100;serializer:nullstring" json:"email"` // 没有邮箱（外部账号未提供或未验证）时保存为 NULL，不占用唯一索引
+	// 邮箱是否已验证：注册、修改邮箱时通过验证码确认，或由外部身份提供方验证
+	EmailVerified bool   `gorm:"column:email_verified;default:false" json:"email_verified"`
+	Password      string `gorm:"size:255" json:"-"` // 对于OAuth2用户，可能为空
+	// 从外部系统导入的旧密码哈希算法（见 password.IsLegacy），为空表示本系统生成的哈希；首次登录成功后升级并清空
+	PasswordAlgorithm string    `gorm:"column:password_algorithm;size:32" json:"-"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 
-	// OAuth2 相关字段
-	GithubID  *int64 `gorm:"uniqueIndex" json:"github_id,omitempty"`   // GitHub 用户ID
+	// 外部登录相关字段，关联的外部账号见 UserIdentity
 	AvatarURL string `gorm:"size:255" json:"avatar_url,omitempty"`     // 头像URL
-	AuthType  string `gorm:"size:20;default:'local'" json:"auth_type"` // 认证类型：local, github
+	AuthType  string `gorm:"size:20;default:'local'" json:"auth_type"` // 注册方式：local、github 或 OpenID Connect 提供方名称
+
+	// 两步验证相关字段
+	TOTPSecret        string `gorm:"column:totp_secret;size:64" json:"-"`                   // TOTP 密钥（Base32），启用前为待确认状态
+	TOTPEnabled       bool   `gorm:"column:totp_enabled;default:false" json:"totp_enabled"` // 是否已启用 TOTP
+	TOTPLastCounter   int64  `gorm:"column:totp_last_counter;default:0" json:"-"`           // 最近一次成功使用的时间步，防止验证码重放
+	TOTPPendingSecret string `gorm:"column:totp_pending_secret;size:64" json:"-"`           // 已启用时重新绑定的新密钥，确认前原密钥继续有效
 }
 
+// 用户名长度限制，与登录、注册接口的参数校验（min=3,max=20）一致
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 20
+)
+
 // 领域错误定义：在领域层内部定义，供服务层使用
 var (
 	ErrUsernameEmpty  = errors.New("用户名不能为空")
+	ErrUsernameLength = fmt.Errorf("用户名长度须为 %d-%d 个字符", UsernameMinLength, UsernameMaxLength)
 	ErrPasswordEmpty  = errors.New("密码不能为空")
 	ErrEmailInvalid   = errors.New("邮箱格式无效")
 	ErrUsernameExists = errors.New("用户名已被注册")
 	ErrEmailExists    = errors.New("邮箱已被注册")
+	ErrEmailUnchanged = errors.New("新邮箱与当前邮箱相同")
+	ErrPasswordWrong  = errors.New("当前密码错误")
+	ErrPasswordNotSet = errors.New("账号未设置密码")
 )
 
-// HashPassword 加密密码（实体自身行为）
-func (u *User) HashPassword() error {
+// HashPassword 加密密码（实体自身行为），算法和参数由哈希器决定
+func (u *User) HashPassword(hasher *password.Hasher) error {
 	if u.Password == "" {
 		return ErrPasswordEmpty
 	}
-	hashed, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	hashed, err := hasher.Hash(u.Password)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashed)
+	u.Password = hashed
+	u.PasswordAlgorithm = ""
 	return nil
 }
 
-// CheckPassword 验证密码（实体自身行为）
+// CheckPassword 验证密码（实体自身行为），支持 argon2id、scrypt、bcrypt 以及导入的旧哈希格式
 func (u *User) CheckPassword(rawPassword string) bool {
 	if rawPassword == "" || u.Password == "" {
 		return false
 	}
-	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(rawPassword)) == nil
+
+	var ok bool
+	var err error
+	if u.PasswordAlgorithm != "" {
+		ok, err = password.VerifyLegacy(u.PasswordAlgorithm, rawPassword, u.Password)
+	} else {
+		ok, err = password.Verify(rawPassword, u.Password)
+	}
+	return err == nil && ok
 }
 
 // Validate 验证实体基础属性（领域规则）
@@ -0,0 +1,95 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ProviderGitHub GitHub 登录的提供方名称，其余提供方使用配置中的 OpenID Connect 名称
+const ProviderGitHub = "github"
+
+// UserIdentity 用户在外部身份提供方（GitHub、OpenID Connect 等）的账号，按 (provider, subject) 唯一
+// 一个用户可以关联多个提供方的账号
+type UserIdentity struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"index;not null" json:"-"`
+	Provider      string    `gorm:"size:32;not null;uniqueIndex:idx_identity_provider_subject" json:"provider"` // provider 名称
+	Subject       string    `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject" json:"-"`       // 提供方的用户唯一标识（GitHub 用户ID、OIDC sub）
+	Email         string    `gorm:"size:100" json:"email,omitempty"`                                            // 提供方返回的邮箱
+	EmailVerified bool      `gorm:"default:false" json:"email_verified"`                                        // 提供方是否已验证该邮箱
+	Profile       string    `gorm:"type:text" json:"-"`                                                         // 提供方返回的原始用户信息（JSON），每次登录更新
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ExternalAccount 外部身份提供方返回的账号信息
+type ExternalAccount struct {
+	Provider      string
+	Subject       string
+	Username      string // 建议的用户名，创建用户时使用
+	Email         string
+	EmailVerified bool
+	AvatarURL     string
+	Profile       string // 原始用户信息（JSON）
+}
+
+// 外部账号相关错误
+var (
+	ErrIdentityNotFound = errors.New("外部账号未关联用户")
+	ErrIdentityLinked   = errors.New("该外部账号已关联其他用户")
+	ErrLastLoginMethod  = errors.New("这是账号唯一的登录方式，请先设置密码、添加通行密钥或关联其他账号")
+)
+
+// LinkConfirmationError 外部账号的邮箱与已有用户一致，但双方邮箱未都经过验证，
+// 需要该用户登录原账号后确认关联
+type LinkConfirmationError struct {
+	UserID uint // 邮箱一致的已有用户
+}
+
+func (e *LinkConfirmationError) Error() string {
+	return "该邮箱已被注册，需要登录原账号确认关联"
+}
+
+// LinkIdentity 为已登录的用户关联外部账号；该外部账号已关联当前用户时更新其信息
+func (s *Service) LinkIdentity(userID uint, account *ExternalAccount) (*UserIdentity, error) {
+	identity, err := s.repo.FindIdentity(account.Provider, account.Subject)
+	switch {
+	case err == nil && identity.UserID != userID:
+		return nil, ErrIdentityLinked
+	case err == nil:
+		identity.Email = account.Email
+		identity.EmailVerified = account.EmailVerified
+		identity.Profile = account.Profile
+		if err := s.repo.UpdateIdentity(identity); err != nil {
+			return nil, fmt.Errorf("更新外部账号信息失败: %w", err)
+		}
+		return identity, nil
+	case !errors.Is(err, ErrIdentityNotFound):
+		return nil, fmt.Errorf("查询外部账号失败: %w", err)
+	}
+
+	identity = &UserIdentity{
+		UserID:        userID,
+		Provider:      account.Provider,
+		Subject:       account.Subject,
+		Email:         account.Email,
+		EmailVerified: account.EmailVerified,
+		Profile:       account.Profile,
+	}
+	if err := s.repo.CreateIdentity(identity); err != nil {
+		return nil, fmt.Errorf("关联外部账号失败: %w", err)
+	}
+	return identity, nil
+}
+
+// ListIdentities 查询用户关联的全部外部账号
+func (s *Service) ListIdentities(userID uint) ([]UserIdentity, error) {
+	return s.repo.ListIdentities(userID)
+}
+
+// UnlinkIdentity 解除外部账号关联；解除后账号没有任何登录方式（密码、可用的通行密钥、其他外部账号）时拒绝
+// 只计入未停用的可发现凭证：仅能作为第二因子的安全密钥无法单独登录
+func (s *Service) UnlinkIdentity(userID, identityID uint) error {
+	return s.repo.DeleteIdentity(userID, identityID)
+}
@@ -0,0 +1,76 @@
+package user
+
+import (
+	"net/mail"
+	"time"
+	"unicode/utf8"
+
+	"auth-service/pkg/password"
+)
+
+// ImportRecord 从外部系统导入的用户记录，密码哈希原样保存
+type ImportRecord struct {
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	PasswordHash      string     `json:"password_hash"`
+	PasswordAlgorithm string     `json:"password_algorithm"`   // argon2id, scrypt, bcrypt, sha1_salted, md5_crypt, pbkdf2_sha256
+	CreatedAt         *time.Time `json:"created_at,omitempty"` // 原系统的注册时间，为空时使用导入时间
+}
+
+// ImportUser 导入一个用户：校验记录和哈希格式、检查唯一性后创建用户
+// dryRun 为 true 时只做校验，不写入数据库
+func (s *Service) ImportUser(rec *ImportRecord, dryRun bool) (*User, error) {
+	if rec.Username == "" {
+		return nil, ErrUsernameEmpty
+	}
+	// 与登录接口按字符数校验，超出范围的用户名导入后无法使用密码登录
+	if n := utf8.RuneCountInString(rec.Username); n < UsernameMinLength || n > UsernameMaxLength {
+		return nil, ErrUsernameLength
+	}
+	if addr, err := mail.ParseAddress(rec.Email); err != nil || addr.Address != rec.Email {
+		return nil, ErrEmailInvalid
+	}
+	if rec.PasswordHash == "" {
+		return nil, ErrPasswordEmpty
+	}
+	if err := password.CheckFormat(rec.PasswordAlgorithm, rec.PasswordHash); err != nil {
+		return nil, err
+	}
+
+	usernameExists, err := s.repo.ExistsByUsername(rec.Username)
+	if err != nil {
+		return nil, err
+	}
+	if usernameExists {
+		return nil, ErrUsernameExists
+	}
+	emailExists, err := s.repo.ExistsByEmail(rec.Email)
+	if err != nil {
+		return nil, err
+	}
+	if emailExists {
+		return nil, ErrEmailExists
+	}
+
+	u := &User{
+		Username: rec.Username,
+		Email:    rec.Email,
+		Password: rec.PasswordHash,
+		AuthType: "local",
+	}
+	// 本系统支持的格式自带算法标识，只有旧算法需要单独标记
+	if password.IsLegacy(rec.PasswordAlgorithm) {
+		u.PasswordAlgorithm = rec.PasswordAlgorithm
+	}
+	if rec.CreatedAt != nil {
+		u.CreatedAt = *rec.CreatedAt
+	}
+
+	if dryRun {
+		return u, nil
+	}
+	if err := s.repo.Create(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,276 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"auth-service/pkg/totp"
+)
+
+// RecoveryCode 两步验证恢复码（只保存哈希值，每个恢复码只能使用一次）
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"index;not null"`
+	CodeHash  string     `gorm:"size:64;not null"`
+	UsedAt    *time.Time // 使用时间，为空表示未使用
+	CreatedAt time.Time
+}
+
+// TOTPEnrollment TOTP 绑定信息
+type TOTPEnrollment struct {
+	Secret string // Base32 密钥，供无法扫码时手动输入
+	URI    string // otpauth:// 链接
+}
+
+// 两步验证相关错误
+var (
+	ErrMFAAlreadyEnabled     = errors.New("两步验证已启用")
+	ErrMFANotEnabled         = errors.New("两步验证未启用")
+	ErrMFAEnrollmentNotFound = errors.New("请先发起两步验证绑定")
+	ErrMFACodeInvalid        = errors.New("验证码错误")
+	ErrMFACodeRequired       = errors.New("两步验证已启用，重新绑定需要提供当前的验证码或恢复码")
+)
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+// BeginTOTPEnrollment 发起 TOTP 绑定：生成新的密钥，待用户用第一个验证码确认后才生效
+// 已启用两步验证时为重新绑定（更换认证器），需要提供当前的验证码或恢复码，确认前原密钥继续有效
+func (s *Service) BeginTOTPEnrollment(userID uint, issuer, currentCode string) (*TOTPEnrollment, error) {
+	u, err := s.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabled {
+		if currentCode == "" {
+			return nil, ErrMFACodeRequired
+		}
+		if err := s.VerifySecondFactor(u, currentCode); err != nil {
+			return nil, err
+		}
+		// 重新查询，避免覆盖 verifyTOTP 中更新的时间步
+		if u, err = s.GetByID(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabled {
+		u.TOTPPendingSecret = secret
+	} else {
+		u.TOTPSecret = secret
+		u.TOTPLastCounter = 0
+	}
+	if err := s.update(u); err != nil {
+		return nil, err
+	}
+
+	account := u.Email
+	if account == "" {
+		account = u.Username
+	}
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(secret, issuer, account),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment 使用第一个验证码确认 TOTP 绑定，返回一次性恢复码明文（仅此一次）
+func (s *Service) ConfirmTOTPEnrollment(userID uint, code string) ([]string, error) {
+	u, err := s.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabled {
+		return s.confirmTOTPReenrollment(u, code)
+	}
+	if u.TOTPSecret == "" {
+		return nil, ErrMFAEnrollmentNotFound
+	}
+
+	if err := s.verifyTOTP(u, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 重新查询，避免覆盖 verifyTOTP 中更新的时间步
+	u, err = s.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	u.TOTPEnabled = true
+	if err := s.update(u); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// confirmTOTPReenrollment 使用新密钥的验证码确认重新绑定：替换密钥并重新生成恢复码
+func (s *Service) confirmTOTPReenrollment(u *User, code string) ([]string, error) {
+	if u.TOTPPendingSecret == "" {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	counter, err := totp.Validate(u.TOTPPendingSecret, code, time.Now())
+	if err != nil {
+		if errors.Is(err, totp.ErrInvalidCode) {
+			return nil, ErrMFACodeInvalid
+		}
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	u, err = s.GetByID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	u.TOTPSecret = u.TOTPPendingSecret
+	u.TOTPPendingSecret = ""
+	u.TOTPLastCounter = counter
+	if err := s.update(u); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifySecondFactor 校验第二因子：6 位 TOTP 验证码或恢复码
+func (s *Service) VerifySecondFactor(u *User, code string) error {
+	if !u.TOTPEnabled {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.verifyTOTP(u, code)
+	}
+
+	used, err := s.repo.UseRecoveryCode(u.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrMFACodeInvalid
+	}
+	return nil
+}
+
+// DisableTOTP 关闭两步验证，需要提供有效的验证码或恢复码
+func (s *Service) DisableTOTP(userID uint, code string) error {
+	u, err := s.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.VerifySecondFactor(u, code); err != nil {
+		return err
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(u.ID, nil); err != nil {
+		return err
+	}
+
+	u, err = s.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	u.TOTPEnabled = false
+	u.TOTPSecret = ""
+	u.TOTPPendingSecret = ""
+	u.TOTPLastCounter = 0
+	return s.update(u)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部作废，需要提供有效的 TOTP 验证码
+func (s *Service) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	u, err := s.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !u.TOTPEnabled {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.verifyTOTP(u, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(u.ID)
+}
+
+// CountRecoveryCodes 查询剩余可用的恢复码数量
+func (s *Service) CountRecoveryCodes(userID uint) (int64, error) {
+	return s.repo.CountUnusedRecoveryCodes(userID)
+}
+
+// verifyTOTP 校验 TOTP 验证码，同一时间步的验证码只能使用一次
+func (s *Service) verifyTOTP(u *User, code string) error {
+	counter, err := totp.Validate(u.TOTPSecret, code, time.Now())
+	if err != nil {
+		if errors.Is(err, totp.ErrInvalidCode) {
+			return ErrMFACodeInvalid
+		}
+		return err
+	}
+
+	updated, err := s.repo.UpdateTOTPCounter(u.ID, counter)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		// 验证码已被使用过
+		return ErrMFACodeInvalid
+	}
+	return nil
+}
+
+// replaceRecoveryCodes 生成新的恢复码并替换旧恢复码，返回明文
+func (s *Service) replaceRecoveryCodes(userID uint) ([]string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	codes := make([]RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		plain = append(plain, code)
+		codes = append(codes, RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(userID, codes); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// generateRecoveryCode 生成恢复码，格式如 ABCD-EFGH-IJKL-MNOP（80 位随机数）
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := base32.StdEncoding.EncodeToString(b) // 16 个字符，无填充
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16], nil
+}
+
+// hashRecoveryCode 计算恢复码哈希（忽略大小写、空格和连字符）
+// 恢复码熵足够高，使用 SHA-256 即可抵御离线暴力破解
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
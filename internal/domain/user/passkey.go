@@ -0,0 +1,167 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebauthnCredential WebAuthn 凭证（通行密钥、平台认证器或安全密钥）
+type WebauthnCredential struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"index;not null" json:"-"`
+	CredentialID    []byte     `gorm:"size:255;uniqueIndex;not null" json:"-"` // 认证器生成的凭证ID
+	PublicKey       []byte     `gorm:"not null" json:"-"`                      // COSE 编码的公钥
+	AttestationType string     `gorm:"size:32" json:"-"`
+	AAGUID          []byte     `gorm:"column:aaguid;size:16" json:"-"`       // 认证器型号标识
+	Transports      string     `gorm:"size:128" json:"transports,omitempty"` // 传输方式，逗号分隔：internal, usb, nfc, ble, hybrid
+	Attachment      string     `gorm:"size:32" json:"attachment,omitempty"`  // platform（平台认证器）或 cross-platform（漫游认证器）
+	SignCount       uint32     `gorm:"default:0" json:"-"`                   // 签名计数，用于检测克隆的认证器
+	CloneWarning    bool       `gorm:"default:false" json:"clone_warning"`   // 检测到签名计数回退，该凭证已停用
+	BackupEligible  bool       `gorm:"default:false" json:"backup_eligible"` // 是否可同步（多设备通行密钥）
+	BackupState     bool       `gorm:"default:false" json:"backup_state"`    // 是否已同步备份
+	Discoverable    bool       `gorm:"default:false" json:"discoverable"`    // 是否为可发现凭证，只有可发现凭证能用于无用户名登录
+	Name            string     `gorm:"size:64" json:"name"`                  // 用户设置的名称
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// 通行密钥相关错误
+var (
+	ErrPasskeyNotFound = errors.New("通行密钥不存在")
+	ErrPasskeyExists   = errors.New("该认证器已注册")
+	ErrPasskeyCloned   = errors.New("检测到认证器可能已被克隆，该凭证已停用")
+)
+
+// 第二因子类型
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+	MFAMethodWebAuthn     = "webauthn"
+)
+
+// Credential 转换为 webauthn 库的凭证
+func (c *WebauthnCredential) Credential() webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	for _, t := range strings.Split(c.Transports, ",") {
+		if t != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              c.CredentialID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: c.BackupEligible,
+			BackupState:    c.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       c.AAGUID,
+			SignCount:    c.SignCount,
+			CloneWarning: c.CloneWarning,
+			Attachment:   protocol.AuthenticatorAttachment(c.Attachment),
+		},
+	}
+}
+
+// ListPasskeys 查询用户已注册的 WebAuthn 凭证
+func (s *Service) ListPasskeys(userID uint) ([]WebauthnCredential, error) {
+	return s.repo.ListWebauthnCredentials(userID)
+}
+
+// AddPasskey 保存新注册的 WebAuthn 凭证，discoverable 为客户端报告的是否可发现
+func (s *Service) AddPasskey(userID uint, name string, credential *webauthn.Credential, discoverable bool) (*WebauthnCredential, error) {
+	if _, err := s.repo.FindWebauthnCredential(credential.ID); err == nil {
+		return nil, ErrPasskeyExists
+	} else if !errors.Is(err, ErrPasskeyNotFound) {
+		return nil, err
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+
+	c := &WebauthnCredential{
+		UserID:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		Transports:      strings.Join(transports, ","),
+		Attachment:      string(credential.Authenticator.Attachment),
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Discoverable:    discoverable,
+		Name:            name,
+	}
+	if err := s.repo.CreateWebauthnCredential(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// RecordPasskeyUse 记录一次成功的 WebAuthn 认证：更新签名计数和备份状态
+// 签名计数未递增时标记凭证为疑似克隆并返回 ErrPasskeyCloned，该凭证此后无法再用于登录
+func (s *Service) RecordPasskeyUse(credential *webauthn.Credential) error {
+	c, err := s.repo.FindWebauthnCredential(credential.ID)
+	if err != nil {
+		return err
+	}
+	if c.CloneWarning {
+		return ErrPasskeyCloned
+	}
+
+	now := time.Now()
+	c.CloneWarning = credential.Authenticator.CloneWarning
+	c.BackupState = credential.Flags.BackupState
+	c.LastUsedAt = &now
+	if !c.CloneWarning {
+		c.SignCount = credential.Authenticator.SignCount
+	}
+
+	if err := s.repo.UpdateWebauthnCredential(c); err != nil {
+		return err
+	}
+	if c.CloneWarning {
+		return ErrPasskeyCloned
+	}
+	return nil
+}
+
+// DeletePasskey 删除用户的 WebAuthn 凭证
+func (s *Service) DeletePasskey(userID, id uint) error {
+	deleted, err := s.repo.DeleteWebauthnCredential(userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
+
+// MFAMethods 返回用户可用于密码登录第二步的验证方式，为空表示无需第二因子
+// 注册了 WebAuthn 凭证的用户，密码登录同样需要使用该凭证完成第二步验证
+func (s *Service) MFAMethods(u *User) ([]string, error) {
+	var methods []string
+	if u.TOTPEnabled {
+		methods = append(methods, MFAMethodTOTP, MFAMethodRecoveryCode)
+	}
+
+	count, err := s.repo.CountWebauthnCredentials(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+	return methods, nil
+}
@@ -0,0 +1,93 @@
+package user
+
+import (
+	"fmt"
+	"time"
+
+	"auth-service/pkg/password"
+)
+
+// PasswordHistory 用户以前使用过的密码哈希，用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	Hash      string `gorm:"size:255;not null"`
+	Algorithm string `gorm:"size:32"` // 同 User.PasswordAlgorithm
+	CreatedAt time.Time
+}
+
+// ValidatePassword 检查密码是否符合密码策略（包括离线泄露密码库）；用户已存在（u.ID 非零）时同时检查是否为最近使用过的密码
+// 不符合时返回 *password.PolicyError
+func (s *Service) ValidatePassword(u *User, rawPassword string) error {
+	violations, err := s.policy.Check(rawPassword, password.UserInfo{Username: u.Username, Email: u.Email})
+	if err != nil {
+		return err
+	}
+
+	if n := s.policy.HistorySize(); u.ID != 0 && n > 0 {
+		reused, err := s.isRecentPassword(u, rawPassword, n)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, password.Violation{
+				Rule:    password.RuleReused,
+				Message: fmt.Sprintf("不能使用最近 %d 次用过的密码", n),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &password.PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// setPassword 校验密码策略并设置新密码，原密码哈希写入历史记录；调用方负责保存用户
+func (s *Service) setPassword(u *User, rawPassword string) error {
+	if err := s.ValidatePassword(u, rawPassword); err != nil {
+		return err
+	}
+
+	hashed, err := s.hasher.Hash(rawPassword)
+	if err != nil {
+		return err
+	}
+
+	// 当前密码本身总会被检查，历史记录只需保留之前的 N-1 个
+	if keep := s.policy.HistorySize() - 1; u.ID != 0 && u.Password != "" && keep > 0 {
+		if err := s.repo.AddPasswordHistory(&PasswordHistory{
+			UserID:    u.ID,
+			Hash:      u.Password,
+			Algorithm: u.PasswordAlgorithm,
+		}, keep); err != nil {
+			return fmt.Errorf("保存历史密码失败: %w", err)
+		}
+	}
+
+	u.Password = hashed
+	u.PasswordAlgorithm = ""
+	return nil
+}
+
+// isRecentPassword 判断密码是否为当前密码或最近 n-1 个历史密码之一
+func (s *Service) isRecentPassword(u *User, rawPassword string, n int) (bool, error) {
+	if u.CheckPassword(rawPassword) {
+		return true, nil
+	}
+	if n <= 1 {
+		return false, nil
+	}
+
+	history, err := s.repo.ListPasswordHistory(u.ID, n-1)
+	if err != nil {
+		return false, fmt.Errorf("查询历史密码失败: %w", err)
+	}
+	for _, h := range history {
+		old := User{Password: h.Hash, PasswordAlgorithm: h.Algorithm}
+		if old.CheckPassword(rawPassword) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -2,13 +2,34 @@ package user
 
 // Repository 仓库接口：定义用户数据访问的抽象方法
 type Repository interface {
-	Create(u *User) error                           // 保存用户
-	FindByUsername(username string) (*User, error)  // 根据用户名查询
-	FindByID(id uint) (*User, error)                // 根据ID查询
-	ExistsByUsername(username string) (bool, error) // 检查用户名是否存在
-	ExistsByEmail(email string) (bool, error)       // 检查邮箱是否存在
-	// OAuth2 相关方法
-	FindByGitHubID(githubID int64) (*User, error)
+	Create(u *User) error                                           // 保存用户
+	FindByUsername(username string) (*User, error)                  // 根据用户名查询
+	FindByID(id uint) (*User, error)                                // 根据ID查询
+	ExistsByUsername(username string) (bool, error)                 // 检查用户名是否存在
+	ExistsByEmail(email string) (bool, error)                       // 检查邮箱是否存在
+	ExistsByEmailExcept(email string, excludeID uint) (bool, error) // 检查邮箱是否已被其他用户使用
 	FindByEmail(email string) (*User, error)
 	Update(user *User) error
+	// 外部账号相关方法
+	FindIdentity(provider, subject string) (*UserIdentity, error) // 根据提供方和用户标识查询，不存在时返回 ErrIdentityNotFound
+	CreateIdentity(identity *UserIdentity) error                  // 关联外部账号
+	UpdateIdentity(identity *UserIdentity) error                  // 更新外部账号信息
+	CreateWithIdentity(u *User, identity *UserIdentity) error     // 在同一事务中创建用户并关联外部账号
+	ListIdentities(userID uint) ([]UserIdentity, error)           // 查询用户关联的全部外部账号
+	DeleteIdentity(userID, id uint) error                         // 解除关联，不存在时返回 ErrIdentityNotFound，没有其他登录方式时返回 ErrLastLoginMethod
+	// 历史密码相关方法
+	AddPasswordHistory(h *PasswordHistory, keep int) error                 // 保存历史密码，只保留最近 keep 条
+	ListPasswordHistory(userID uint, limit int) ([]PasswordHistory, error) // 查询最近的历史密码（新的在前）
+	// 两步验证相关方法
+	UpdateTOTPCounter(userID uint, counter int64) (bool, error)   // 仅当时间步大于上次记录时更新，返回是否更新成功
+	ReplaceRecoveryCodes(userID uint, codes []RecoveryCode) error // 替换用户的全部恢复码
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)   // 标记恢复码已使用，返回是否为首次使用
+	CountUnusedRecoveryCodes(userID uint) (int64, error)          // 统计剩余可用的恢复码
+	// WebAuthn 凭证相关方法
+	CreateWebauthnCredential(c *WebauthnCredential) error                    // 保存凭证
+	FindWebauthnCredential(credentialID []byte) (*WebauthnCredential, error) // 根据凭证ID查询，不存在时返回 ErrPasskeyNotFound
+	ListWebauthnCredentials(userID uint) ([]WebauthnCredential, error)       // 查询用户的全部凭证
+	UpdateWebauthnCredential(c *WebauthnCredential) error                    // 更新签名计数、备份状态和使用时间
+	DeleteWebauthnCredential(userID, id uint) (bool, error)                  // 删除凭证，返回是否删除成功
+	CountWebauthnCredentials(userID uint) (int64, error)                     // 统计用户可用（未停用）的凭证
 }
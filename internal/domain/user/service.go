@@ -1,21 +1,31 @@
 package user
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"auth-service/pkg/oauth2"
+	"auth-service/pkg/password"
 )
 
 // Service 领域服务：封装用户领域的业务逻辑
 type Service struct {
-	repo Repository // 依赖仓库接口（抽象），而非具体实现
+	repo   Repository       // 依赖仓库接口（抽象），而非具体实现
+	hasher *password.Hasher // 密码哈希器
+	policy *password.Policy // 密码策略
 }
 
 // NewService 创建领域服务实例（通过依赖注入仓库接口）
-func NewService(repo Repository) *Service {
+func NewService(repo Repository, hasher *password.Hasher, policy *password.Policy) *Service {
 	return &Service{
-		repo: repo,
+		repo:   repo,
+		hasher: hasher,
+		policy: policy,
 	}
 }
 
@@ -46,8 +56,8 @@ func (s *Service) Register(u *User) (*User, error) {
 		}
 	}
 
-	// 4. 加密密码（调用实体自身的加密方法）
-	if err := u.HashPassword(); err != nil {
+	// 4. 校验密码策略并加密密码
+	if err := s.setPassword(u, u.Password); err != nil {
 		return nil, err
 	}
 
@@ -75,51 +85,282 @@ func (s *Service) GetByID(id uint) (*User, error) {
 	return s.repo.FindByID(id)
 }
 
-// LoginWithGitHub 使用 GitHub OAuth2 登录或注册
-func (s *Service) LoginWithGitHub(githubUser *oauth2.GitHubUser) (*User, error) {
-	// 1. 先通过 GitHub ID 查找用户
-	existingUser, err := s.repo.FindByGitHubID(githubUser.ID)
+// GetByEmail 根据邮箱查询用户
+func (s *Service) GetByEmail(email string) (*User, error) {
+	if email == "" {
+		return nil, ErrEmailInvalid
+	}
+	return s.repo.FindByEmail(email)
+}
+
+// ResetPassword 设置新密码（调用方负责验证用户身份）
+func (s *Service) ResetPassword(userID uint, newPassword string) error {
+	u, err := s.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.setPassword(u, newPassword); err != nil {
+		return err
+	}
+	return s.update(u)
+}
+
+// RehashPasswordIfNeeded 密码验证通过后，若哈希使用了过时的算法或参数（包括导入的旧哈希），则用当前配置重新计算并保存
+// 返回是否进行了重新哈希
+func (s *Service) RehashPasswordIfNeeded(u *User, rawPassword string) (bool, error) {
+	if u.Password == "" || (u.PasswordAlgorithm == "" && !s.hasher.NeedsRehash(u.Password)) {
+		return false, nil
+	}
+
+	hashed, err := s.hasher.Hash(rawPassword)
+	if err != nil {
+		return false, err
+	}
+	u.Password = hashed
+	u.PasswordAlgorithm = ""
+	if err := s.update(u); err != nil {
+		return false, fmt.Errorf("保存新密码哈希失败: %w", err)
+	}
+	return true, nil
+}
+
+// ChangePassword 校验当前密码后设置新密码
+func (s *Service) ChangePassword(userID uint, currentPassword, newPassword string) error {
+	u, err := s.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if u.Password == "" {
+		return ErrPasswordNotSet
+	}
+	if !u.CheckPassword(currentPassword) {
+		return ErrPasswordWrong
+	}
+
+	if err := s.setPassword(u, newPassword); err != nil {
+		return err
+	}
+	return s.update(u)
+}
+
+// CheckEmailChange 检查用户能否将邮箱修改为 newEmail（邮箱未变化或已被其他用户使用时返回错误）
+func (s *Service) CheckEmailChange(userID uint, newEmail string) (*User, error) {
+	u, err := s.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(u.Email, newEmail) {
+		return nil, ErrEmailUnchanged
+	}
+
+	exists, err := s.repo.ExistsByEmailExcept(newEmail, userID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrEmailExists
+	}
+	return u, nil
+}
+
+// ChangeEmail 修改用户邮箱（调用方负责验证新邮箱的所有权）
+func (s *Service) ChangeEmail(userID uint, newEmail string) (*User, error) {
+	u, err := s.CheckEmailChange(userID, newEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Email = newEmail
+	u.EmailVerified = true
+	if err := s.update(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// UsernameExists 检查用户名是否已被注册
+func (s *Service) UsernameExists(username string) (bool, error) {
+	return s.repo.ExistsByUsername(username)
+}
+
+// EmailExists 检查邮箱是否已被注册
+func (s *Service) EmailExists(email string) (bool, error) {
+	return s.repo.ExistsByEmail(email)
+}
+
+// update 保存用户信息，保存前检查邮箱是否已被其他用户使用
+func (s *Service) update(u *User) error {
+	if u.Email != "" {
+		exists, err := s.repo.ExistsByEmailExcept(u.Email, u.ID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrEmailExists
+		}
+	}
+	return s.repo.Update(u)
+}
+
+// GitHubAccount 将 GitHub 用户信息转换为外部账号
+func GitHubAccount(githubUser *oauth2.GitHubUser) *ExternalAccount {
+	profile, _ := json.Marshal(githubUser)
+	return &ExternalAccount{
+		Provider:      ProviderGitHub,
+		Subject:       strconv.FormatInt(githubUser.ID, 10),
+		Username:      githubUser.Login,
+		Email:         githubUser.Email,
+		EmailVerified: githubUser.EmailVerified,
+		AvatarURL:     githubUser.AvatarURL,
+		Profile:       string(profile),
+	}
+}
+
+// OIDCAccount 将 OpenID Connect 用户信息转换为外部账号
+func OIDCAccount(oidcUser *oauth2.OIDCUser) *ExternalAccount {
+	profile, _ := json.Marshal(oidcUser.Claims)
+	return &ExternalAccount{
+		Provider:      oidcUser.Provider,
+		Subject:       oidcUser.Subject,
+		Username:      oidcUser.Username,
+		Email:         oidcUser.Email,
+		EmailVerified: oidcUser.EmailVerified,
+		AvatarURL:     oidcUser.Picture,
+		Profile:       string(profile),
+	}
+}
+
+// LoginWithExternal 使用外部账号登录或注册
+// 外部账号的邮箱与已有用户一致时，只有双方邮箱都已验证才自动关联；否则返回 *LinkConfirmationError，
+// 需要该用户登录原账号后确认关联，避免用未验证的邮箱接管他人账号
+func (s *Service) LoginWithExternal(account *ExternalAccount) (*User, error) {
+	// 1. 先通过外部账号查找用户
+	identity, err := s.repo.FindIdentity(account.Provider, account.Subject)
 	if err == nil {
-		// 用户已存在，更新信息并返回
-		existingUser.AvatarURL = githubUser.AvatarURL
-		if err := s.repo.Update(existingUser); err != nil {
-			return nil, fmt.Errorf("更新用户信息失败: %w", err)
+		existingUser, err := s.repo.FindByID(identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("查询用户失败: %w", err)
+		}
+		identity.Email = account.Email
+		identity.EmailVerified = account.EmailVerified
+		identity.Profile = account.Profile
+		if err := s.repo.UpdateIdentity(identity); err != nil {
+			return nil, fmt.Errorf("更新外部账号信息失败: %w", err)
+		}
+		if account.AvatarURL != "" && existingUser.AvatarURL != account.AvatarURL {
+			existingUser.AvatarURL = account.AvatarURL
+			if err := s.update(existingUser); err != nil {
+				return nil, fmt.Errorf("更新用户信息失败: %w", err)
+			}
 		}
 		return existingUser, nil
 	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return nil, fmt.Errorf("查询外部账号失败: %w", err)
+	}
 
-	// 2. 如果通过 GitHub ID 找不到，尝试通过邮箱查找
-	if githubUser.Email != "" {
-		existingUser, err := s.repo.FindByEmail(githubUser.Email)
+	identity = &UserIdentity{
+		Provider:      account.Provider,
+		Subject:       account.Subject,
+		Email:         account.Email,
+		EmailVerified: account.EmailVerified,
+		Profile:       account.Profile,
+	}
+
+	// 2. 通过邮箱查找用户
+	if account.Email != "" {
+		existingUser, err := s.repo.FindByEmail(account.Email)
 		if err == nil {
-			// 邮箱已存在，绑定 GitHub 账号
-			existingUser.GithubID = &githubUser.ID
-			existingUser.AvatarURL = githubUser.AvatarURL
-			existingUser.AuthType = "github"
-			if err := s.repo.Update(existingUser); err != nil {
-				return nil, fmt.Errorf("绑定 GitHub 账号失败: %w", err)
+			if !account.EmailVerified || !existingUser.EmailVerified {
+				return nil, &LinkConfirmationError{UserID: existingUser.ID}
+			}
+			identity.UserID = existingUser.ID
+			if err := s.repo.CreateIdentity(identity); err != nil {
+				return nil, fmt.Errorf("关联外部账号失败: %w", err)
 			}
 			return existingUser, nil
 		}
 	}
 
 	// 3. 用户不存在，创建新用户
+	// 邮箱只有经提供方验证后才写入用户，否则任何人都能用自建或不验证邮箱的提供方占用他人邮箱，
+	// 使邮箱的真正所有者无法再注册；未验证的邮箱仍记录在外部账号中
 	newUser := &User{
-		Username:  githubUser.Login,
-		Email:     githubUser.Email,
-		GithubID:  &githubUser.ID,
-		AvatarURL: githubUser.AvatarURL,
-		AuthType:  "github",
+		AvatarURL: account.AvatarURL,
+		AuthType:  account.Provider,
+	}
+	if account.EmailVerified {
+		newUser.Email = account.Email
+		newUser.EmailVerified = true
 	}
+	if err := s.createExternalUser(newUser, identity, account); err != nil {
+		return nil, err
+	}
+	return newUser, nil
+}
+
+// maxUsernameAttempts 为外部账号生成用户名的最大尝试次数
+const maxUsernameAttempts = 5
 
-	// 检查用户名是否已存在，如果存在则添加后缀
-	if exists, _ := s.repo.ExistsByUsername(newUser.Username); exists {
-		newUser.Username = fmt.Sprintf("%s_%d", githubUser.Login, githubUser.ID)
+// createExternalUser 为外部账号选择可用的用户名并创建用户
+// 建议的用户名不可用、过长或已存在时，加上由提供方和用户标识生成的后缀；
+// 并发创建时用户名仍可能在检查之后被占用，此时换一个后缀重试
+func (s *Service) createExternalUser(newUser *User, identity *UserIdentity, account *ExternalAccount) error {
+	base := externalUsername(account.Username)
+	valid := len(base) >= 3 && len(base) <= 40
+	if !valid {
+		base = account.Provider
 	}
 
-	if err := s.repo.Create(newUser); err != nil {
-		return nil, fmt.Errorf("创建用户失败: %w", err)
+	for i := 0; i < maxUsernameAttempts; i++ {
+		candidate := base
+		if !valid || i > 0 {
+			candidate = base + "_" + subjectSuffix(account.Provider, account.Subject, i)
+		}
+		exists, err := s.repo.ExistsByUsername(candidate)
+		if err != nil {
+			return fmt.Errorf("查询用户名失败: %w", err)
+		}
+		if exists {
+			continue
+		}
+
+		newUser.Username = candidate
+		err = s.repo.CreateWithIdentity(newUser, identity)
+		if err == nil {
+			return nil
+		}
+		// 只有用户名被并发占用时才重试，其他错误（包括外部账号已被并发创建）直接返回
+		if taken, checkErr := s.repo.ExistsByUsername(candidate); checkErr != nil || !taken {
+			return fmt.Errorf("创建用户失败: %w", err)
+		}
+		newUser.ID = 0
+		identity.UserID = 0
 	}
+	return errors.New("创建用户失败: 无法生成可用的用户名")
+}
 
-	return newUser, nil
+// externalUsername 规范化外部账号建议的用户名
+// OpenID Connect 的 preferred_username、email 等声明由提供方（最终是外部用户）填写，可能包含空格、&、=、# 等字符，
+// 只保留邮箱形式的 @ 之前部分中的字母、数字和 _ . -，其余字符丢弃
+func externalUsername(username string) string {
+	username, _, _ = strings.Cut(username, "@")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		}
+		return -1
+	}, username)
+}
+
+// subjectSuffix 由外部账号生成简短的用户名后缀，attempt 为重试序号（0 与旧版本生成的后缀相同）
+func subjectSuffix(provider, subject string, attempt int) string {
+	seed := provider + ":" + subject
+	if attempt > 0 {
+		seed += ":" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:4])
 }
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"auth-service/internal/domain/user"
+)
+
+// legacyGitHubIndex 旧版 users.github_id 列的唯一索引
+const legacyGitHubIndex = "idx_users_github_id"
+
+// AutoMigrate 创建或更新所有数据表结构
+func AutoMigrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(
+		&user.User{},
+		&user.PasswordHistory{},
+		&user.RecoveryCode{},
+		&user.WebauthnCredential{},
+		&user.UserIdentity{},
+		&signingKey{},
+	); err != nil {
+		return err
+	}
+	if err := clearEmptyEmails(db); err != nil {
+		return err
+	}
+	return copyGitHubIdentities(db)
+}
+
+// clearEmptyEmails 将旧版本保存的空邮箱改为 NULL，users.email 改为可空后多个没有邮箱的用户不再违反唯一索引
+func clearEmptyEmails(db *gorm.DB) error {
+	if err := db.Table("users").Where("email = ?", "").Update("email", gorm.Expr("NULL")).Error; err != nil {
+		return fmt.Errorf("清理空邮箱失败: %w", err)
+	}
+	return nil
+}
+
+// copyGitHubIdentities 将旧版 users.github_id 复制到 user_identities
+//
+// github_id 列已弃用、不再读取，但会保留到执行 authctl drop-legacy-github-id 为止，回滚到旧版本时关联不会丢失；
+// 每次启动都会复制（已复制的记录不会重复插入），回滚期间旧版本新关联的 GitHub 账号在重新升级后同样会被迁移
+func copyGitHubIdentities(db *gorm.DB) error {
+	if !hasLegacyGitHubColumn(db) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID       uint
+			GithubID int64
+		}
+		if err := tx.Table("users").Select("id, github_id").Where("github_id IS NOT NULL").Scan(&rows).Error; err != nil {
+			return fmt.Errorf("读取 github_id 失败: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		identities := make([]user.UserIdentity, 0, len(rows))
+		for _, row := range rows {
+			identities = append(identities, user.UserIdentity{
+				UserID:   row.ID,
+				Provider: user.ProviderGitHub,
+				Subject:  strconv.FormatInt(row.GithubID, 10),
+			})
+		}
+		// 邮箱、验证状态和原始用户信息在下次 GitHub 登录时补全
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(identities, 500).Error; err != nil {
+			return fmt.Errorf("迁移 GitHub 账号失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// DropLegacyGitHubID 删除已弃用的 users.github_id 列及其索引（authctl drop-legacy-github-id）
+// 删除前再复制一次并确认每个 github_id 都已存在于 user_identities；删除后无法再回滚到使用 github_id 的旧版本
+func DropLegacyGitHubID(db *gorm.DB) error {
+	if !hasLegacyGitHubColumn(db) {
+		return nil
+	}
+	if err := copyGitHubIdentities(db); err != nil {
+		return err
+	}
+
+	var missing int64
+	if err := db.Table("users").
+		Where("github_id IS NOT NULL").
+		Where("NOT EXISTS (SELECT 1 FROM user_identities ui WHERE ui.user_id = users.id AND ui.provider = ?)", user.ProviderGitHub).
+		Count(&missing).Error; err != nil {
+		return fmt.Errorf("核对 GitHub 账号迁移结果失败: %w", err)
+	}
+	if missing > 0 {
+		return fmt.Errorf("有 %d 个用户的 github_id 未能迁移到 user_identities（可能已被其他用户关联），请处理后重试", missing)
+	}
+
+	migrator := db.Migrator()
+	if migrator.HasIndex("users", legacyGitHubIndex) {
+		if err := migrator.DropIndex("users", legacyGitHubIndex); err != nil {
+			return fmt.Errorf("删除 github_id 索引失败: %w", err)
+		}
+	}
+	if err := migrator.DropColumn("users", "github_id"); err != nil {
+		return fmt.Errorf("删除 github_id 列失败: %w", err)
+	}
+	return nil
+}
+
+// hasLegacyGitHubColumn 已弃用的 users.github_id 列是否仍然存在
+func hasLegacyGitHubColumn(db *gorm.DB) bool {
+	return db.Migrator().HasColumn("users", "github_id")
+}
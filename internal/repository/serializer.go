@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("nullstring", nullStringSerializer{})
+}
+
+// nullStringSerializer 字符串字段的空值与 NULL 互相转换（gorm 标签 serializer:nullstring）
+// 用于允许为空的唯一列（如 users.email）：多个空值保存为 NULL 时不会违反唯一索引
+type nullStringSerializer struct{}
+
+// Scan 实现 schema.SerializerInterface，NULL 读取为空字符串
+func (nullStringSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var ns sql.NullString
+	if err := ns.Scan(dbValue); err != nil {
+		return fmt.Errorf("读取字段 %s 失败: %w", field.Name, err)
+	}
+	return field.Set(ctx, dst, ns.String)
+}
+
+// Value 实现 schema.SerializerValuerInterface，空字符串保存为 NULL
+func (nullStringSerializer) Value(_ context.Context, _ *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	if s, _ := fieldValue.(string); s != "" {
+		return s, nil
+	}
+	return nil, nil
+}
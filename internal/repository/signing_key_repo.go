@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"auth-service/pkg/jwt"
+)
+
+// signingKey 签名密钥表模型
+type signingKey struct {
+	ID         string     `gorm:"primaryKey;size:64"`
+	Algorithm  string     `gorm:"size:20;not null"`
+	PrivateKey string     `gorm:"type:text;not null"` // AES-GCM 加密的 PKCS#8 PEM，见 encryptedKeyPrefix
+	NotBefore  time.Time  `gorm:"index;not null"`
+	NotAfter   *time.Time `gorm:"index"`
+	CreatedAt  time.Time
+}
+
+// TableName 指定表名
+func (signingKey) TableName() string {
+	return "signing_keys"
+}
+
+// encryptedKeyPrefix 加密私钥的前缀，其后为 base64(nonce || 密文)；没有前缀的是旧版本保存的明文 PEM
+const encryptedKeyPrefix = "enc:v1:"
+
+// signingKeyRepository 签名密钥仓库实现：基于GORM持久化密钥环，私钥加密后保存
+type signingKeyRepository struct {
+	db   *gorm.DB
+	aead cipher.AEAD
+}
+
+// NewSigningKeyRepository 创建签名密钥仓库实例
+// encryptionKey 为 base64 编码的 32 字节 AES-256 密钥，只读取数据库不足以伪造令牌
+func NewSigningKeyRepository(db *gorm.DB, encryptionKey string) (jwt.KeyStore, error) {
+	if encryptionKey == "" {
+		return nil, errors.New("启用密钥轮换时必须配置签名密钥加密密钥")
+	}
+	key, err := base64.StdEncoding.DecodeString(encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("签名密钥加密密钥格式无效: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("签名密钥加密密钥长度应为 32 字节，实际为 %d 字节", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &signingKeyRepository{
+		db:   db,
+		aead: aead,
+	}, nil
+}
+
+// ListKeys 查询全部签名密钥
+func (r *signingKeyRepository) ListKeys(ctx context.Context) ([]jwt.KeyRecord, error) {
+	var models []signingKey
+	if err := r.db.WithContext(ctx).Order("not_before").Find(&models).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]jwt.KeyRecord, 0, len(models))
+	for _, m := range models {
+		privatePEM, err := r.decrypt(m.ID, m.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(m.PrivateKey, encryptedKeyPrefix) {
+			if err := r.encryptPlaintext(ctx, m); err != nil {
+				return nil, err
+			}
+		}
+
+		record := jwt.KeyRecord{
+			ID:            m.ID,
+			Algorithm:     m.Algorithm,
+			PrivateKeyPEM: privatePEM,
+			NotBefore:     m.NotBefore,
+			CreatedAt:     m.CreatedAt,
+		}
+		if m.NotAfter != nil {
+			record.NotAfter = *m.NotAfter
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// SaveKey 新增或更新签名密钥
+func (r *signingKeyRepository) SaveKey(ctx context.Context, record jwt.KeyRecord) error {
+	privateKey, err := r.encrypt(record.ID, record.PrivateKeyPEM)
+	if err != nil {
+		return err
+	}
+
+	m := signingKey{
+		ID:         record.ID,
+		Algorithm:  record.Algorithm,
+		PrivateKey: privateKey,
+		NotBefore:  record.NotBefore,
+		CreatedAt:  record.CreatedAt,
+	}
+	if !record.NotAfter.IsZero() {
+		notAfter := record.NotAfter
+		m.NotAfter = &notAfter
+	}
+	return r.db.WithContext(ctx).Save(&m).Error
+}
+
+// encryptPlaintext 加密旧版本保存的明文私钥，条件更新避免覆盖其他副本同时写入的结果
+func (r *signingKeyRepository) encryptPlaintext(ctx context.Context, m signingKey) error {
+	privateKey, err := r.encrypt(m.ID, []byte(m.PrivateKey))
+	if err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Model(&signingKey{}).
+		Where("id = ? AND private_key = ?", m.ID, m.PrivateKey).
+		Update("private_key", privateKey).Error; err != nil {
+		return fmt.Errorf("加密签名密钥 %s 失败: %w", m.ID, err)
+	}
+	return nil
+}
+
+// encrypt 使用 AES-GCM 加密私钥，密钥ID作为附加数据，密文不能挪用到其他记录
+func (r *signingKeyRepository) encrypt(id string, plaintext []byte) (string, error) {
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := r.aead.Seal(nonce, nonce, plaintext, []byte(id))
+	return encryptedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt 解密私钥；旧版本保存的明文 PEM 原样返回
+func (r *signingKeyRepository) decrypt(id, stored string) ([]byte, error) {
+	if !strings.HasPrefix(stored, encryptedKeyPrefix) {
+		if !strings.HasPrefix(stored, "-----BEGIN") {
+			return nil, fmt.Errorf("签名密钥 %s 格式无效", id)
+		}
+		return []byte(stored), nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedKeyPrefix))
+	if err != nil || len(sealed) < r.aead.NonceSize() {
+		return nil, fmt.Errorf("签名密钥 %s 格式无效", id)
+	}
+	nonce, ciphertext := sealed[:r.aead.NonceSize()], sealed[r.aead.NonceSize():]
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("解密签名密钥 %s 失败（加密密钥是否正确？）: %w", id, err)
+	}
+	return plaintext, nil
+}
@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"auth-service/internal/domain/user"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // userRepository 仓库实现：基于GORM实现数据访问逻辑
@@ -63,14 +67,14 @@ func (r *userRepository) ExistsByEmail(email string) (bool, error) {
 	return count > 0, nil
 }
 
-// FindByGitHubID 根据 GitHub ID 查询用户
-func (r *userRepository) FindByGitHubID(githubID int64) (*user.User, error) {
-	var u user.User
-	result := r.db.Where("github_id = ?", githubID).First(&u)
+// ExistsByEmailExcept 检查邮箱是否已被指定用户以外的用户使用
+func (r *userRepository) ExistsByEmailExcept(email string, excludeID uint) (bool, error) {
+	var count int64
+	result := r.db.Model(&user.User{}).Where("email = ? AND id <> ?", email, excludeID).Count(&count)
 	if result.Error != nil {
-		return nil, result.Error
+		return false, result.Error
 	}
-	return &u, nil
+	return count > 0, nil
 }
 
 // FindByEmail 根据邮箱查询用户
@@ -87,3 +91,230 @@ func (r *userRepository) FindByEmail(email string) (*user.User, error) {
 func (r *userRepository) Update(u *user.User) error {
 	return r.db.Save(u).Error
 }
+
+// FindIdentity 根据提供方和用户标识查询外部账号
+func (r *userRepository) FindIdentity(provider, subject string) (*user.UserIdentity, error) {
+	var identity user.UserIdentity
+	result := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, user.ErrIdentityNotFound
+		}
+		return nil, result.Error
+	}
+	return &identity, nil
+}
+
+// CreateIdentity 关联外部账号
+func (r *userRepository) CreateIdentity(identity *user.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// UpdateIdentity 更新外部账号信息
+func (r *userRepository) UpdateIdentity(identity *user.UserIdentity) error {
+	return r.db.Save(identity).Error
+}
+
+// CreateWithIdentity 在同一事务中创建用户并关联外部账号
+func (r *userRepository) CreateWithIdentity(u *user.User, identity *user.UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+		identity.UserID = u.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// ListIdentities 查询用户关联的全部外部账号，按关联时间排序
+func (r *userRepository) ListIdentities(userID uint) ([]user.UserIdentity, error) {
+	var identities []user.UserIdentity
+	result := r.db.Where("user_id = ?", userID).Order("id").Find(&identities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return identities, nil
+}
+
+// DeleteIdentity 解除外部账号关联
+// 在同一事务中锁定用户行并检查剩余的登录方式（密码、可用的可发现凭证、其他外部账号），
+// 没有剩余方式时返回 ErrLastLoginMethod；并发的解除关联因行锁串行执行，不会同时删掉最后两个。
+// 注册方式指向被解除的提供方时改为剩余的登录方式。
+// 已弃用的 users.github_id 列尚未删除时同时清空，避免启动迁移时重新关联已解除的 GitHub 账号
+func (r *userRepository) DeleteIdentity(userID, id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var u user.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&u, userID).Error; err != nil {
+			return err
+		}
+
+		var identity user.UserIdentity
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&identity).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return user.ErrIdentityNotFound
+			}
+			return err
+		}
+
+		var remaining []user.UserIdentity
+		if err := tx.Where("user_id = ? AND id <> ?", userID, id).Order("id").Find(&remaining).Error; err != nil {
+			return err
+		}
+		if u.Password == "" && len(remaining) == 0 {
+			var passkeys int64
+			if err := tx.Model(&user.WebauthnCredential{}).
+				Where("user_id = ? AND clone_warning = ? AND discoverable = ?", userID, false, true).
+				Count(&passkeys).Error; err != nil {
+				return err
+			}
+			if passkeys == 0 {
+				return user.ErrLastLoginMethod
+			}
+		}
+
+		if err := tx.Delete(&identity).Error; err != nil {
+			return err
+		}
+
+		if u.AuthType == identity.Provider {
+			authType := "local"
+			if u.Password == "" && len(remaining) > 0 {
+				authType = remaining[0].Provider
+			}
+			if err := tx.Model(&user.User{}).Where("id = ?", userID).Update("auth_type", authType).Error; err != nil {
+				return err
+			}
+		}
+
+		if identity.Provider == user.ProviderGitHub && hasLegacyGitHubColumn(tx) {
+			return tx.Table("users").Where("id = ?", userID).Update("github_id", nil).Error
+		}
+		return nil
+	})
+}
+
+// AddPasswordHistory 保存历史密码，并删除最近 keep 条以外的记录
+func (r *userRepository) AddPasswordHistory(h *user.PasswordHistory, keep int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(h).Error; err != nil {
+			return err
+		}
+
+		var keepIDs []uint
+		if err := tx.Model(&user.PasswordHistory{}).
+			Where("user_id = ?", h.UserID).
+			Order("id DESC").
+			Limit(keep).
+			Pluck("id", &keepIDs).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND id NOT IN ?", h.UserID, keepIDs).Delete(&user.PasswordHistory{}).Error
+	})
+}
+
+// ListPasswordHistory 查询用户最近的历史密码
+func (r *userRepository) ListPasswordHistory(userID uint, limit int) ([]user.PasswordHistory, error) {
+	var history []user.PasswordHistory
+	result := r.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&history)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return history, nil
+}
+
+// UpdateTOTPCounter 仅当时间步大于上次记录时更新（原子操作，防止验证码并发重放）
+func (r *userRepository) UpdateTOTPCounter(userID uint, counter int64) (bool, error) {
+	result := r.db.Model(&user.User{}).
+		Where("id = ? AND totp_last_counter < ?", userID, counter).
+		Update("totp_last_counter", counter)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReplaceRecoveryCodes 替换用户的全部恢复码
+func (r *userRepository) ReplaceRecoveryCodes(userID uint, codes []user.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&user.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode 标记恢复码已使用（原子操作，同一恢复码只能使用一次）
+func (r *userRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&user.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// CountUnusedRecoveryCodes 统计剩余可用的恢复码
+func (r *userRepository) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	result := r.db.Model(&user.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
+
+// CreateWebauthnCredential 保存 WebAuthn 凭证
+func (r *userRepository) CreateWebauthnCredential(c *user.WebauthnCredential) error {
+	return r.db.Create(c).Error
+}
+
+// FindWebauthnCredential 根据凭证ID查询 WebAuthn 凭证
+func (r *userRepository) FindWebauthnCredential(credentialID []byte) (*user.WebauthnCredential, error) {
+	var c user.WebauthnCredential
+	result := r.db.Where("credential_id = ?", credentialID).First(&c)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, user.ErrPasskeyNotFound
+		}
+		return nil, result.Error
+	}
+	return &c, nil
+}
+
+// ListWebauthnCredentials 查询用户的全部 WebAuthn 凭证，按注册时间排序
+func (r *userRepository) ListWebauthnCredentials(userID uint) ([]user.WebauthnCredential, error) {
+	var credentials []user.WebauthnCredential
+	result := r.db.Where("user_id = ?", userID).Order("id").Find(&credentials)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return credentials, nil
+}
+
+// UpdateWebauthnCredential 更新 WebAuthn 凭证的使用状态
+func (r *userRepository) UpdateWebauthnCredential(c *user.WebauthnCredential) error {
+	return r.db.Model(c).Select("sign_count", "clone_warning", "backup_state", "last_used_at").Updates(c).Error
+}
+
+// DeleteWebauthnCredential 删除用户的 WebAuthn 凭证
+func (r *userRepository) DeleteWebauthnCredential(userID, id uint) (bool, error) {
+	result := r.db.Where("user_id = ? AND id = ?", userID, id).Delete(&user.WebauthnCredential{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// CountWebauthnCredentials 统计用户可用（未停用）的 WebAuthn 凭证
+func (r *userRepository) CountWebauthnCredentials(userID uint) (int64, error) {
+	var count int64
+	result := r.db.Model(&user.WebauthnCredential{}).Where("user_id = ? AND clone_warning = ?", userID, false).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
@@ -0,0 +1,216 @@
+package userimport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"auth-service/internal/domain/user"
+)
+
+// 支持的导入文件格式
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// maxLineSize JSONL 单行最大长度
+const maxLineSize = 1 << 20
+
+// RowError 单行导入失败信息
+type RowError struct {
+	Line     int    `json:"line"`
+	Username string `json:"username,omitempty"`
+	Error    string `json:"error"`
+}
+
+// Report 导入结果
+type Report struct {
+	DryRun   bool       `json:"dry_run"`
+	Total    int        `json:"total"`    // 读取的记录数
+	Imported int        `json:"imported"` // 导入成功（试运行时为校验通过）的记录数
+	Failed   int        `json:"failed"`
+	Errors   []RowError `json:"errors,omitempty"`
+}
+
+// FormatFromPath 根据文件扩展名推断导入格式
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	}
+	return ""
+}
+
+// Run 从 r 读取用户记录并逐条导入，单行失败不影响其他行
+// CSV 第一行为表头，列名为 username, email, password_hash, password_algorithm, created_at（可选，RFC 3339）；
+// JSONL 每行一个 JSON 对象，字段名与 CSV 列名相同
+func Run(userService *user.Service, r io.Reader, format string, dryRun bool) (*Report, error) {
+	im := &importer{
+		userService: userService,
+		report:      &Report{DryRun: dryRun},
+		usernames:   make(map[string]int),
+		emails:      make(map[string]int),
+	}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		err = im.readCSV(r)
+	case FormatJSONL:
+		err = im.readJSONL(r)
+	default:
+		return nil, fmt.Errorf("不支持的导入格式: %q", format)
+	}
+	return im.report, err
+}
+
+// importer 单次导入的状态
+type importer struct {
+	userService *user.Service
+	report      *Report
+	usernames   map[string]int // 文件内已出现的用户名及其行号，试运行时也能发现文件内重复
+	emails      map[string]int
+}
+
+// readCSV 读取 CSV 格式的用户记录
+func (im *importer) readCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("读取 CSV 表头失败: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"username", "email", "password_hash", "password_algorithm"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("CSV 缺少列: %s", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				im.skip(parseErr.StartLine, "", err)
+				continue
+			}
+			return fmt.Errorf("读取 CSV 失败: %w", err)
+		}
+		// 只能在成功读取后调用：解析失败时当前记录没有字段，FieldPos 会 panic
+		line, _ := cr.FieldPos(0)
+
+		rec := &user.ImportRecord{
+			Username:          field(row, "username"),
+			Email:             field(row, "email"),
+			PasswordHash:      field(row, "password_hash"),
+			PasswordAlgorithm: field(row, "password_algorithm"),
+		}
+		if v := field(row, "created_at"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				im.skip(line, rec.Username, fmt.Errorf("created_at 格式无效: %w", err))
+				continue
+			}
+			rec.CreatedAt = &t
+		}
+		im.importRecord(line, rec)
+	}
+}
+
+// readJSONL 读取 JSONL 格式的用户记录，空行忽略
+func (im *importer) readJSONL(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var rec user.ImportRecord
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			im.skip(line, "", fmt.Errorf("JSON 格式无效: %w", err))
+			continue
+		}
+		rec.Username = strings.TrimSpace(rec.Username)
+		rec.Email = strings.TrimSpace(rec.Email)
+		im.importRecord(line, &rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 JSONL 失败（第 %d 行之后）: %w", line, err)
+	}
+	return nil
+}
+
+// importRecord 导入单条记录并记录结果
+func (im *importer) importRecord(line int, rec *user.ImportRecord) {
+	im.report.Total++
+
+	usernameKey := strings.ToLower(rec.Username)
+	emailKey := strings.ToLower(rec.Email)
+	if prev, ok := im.usernames[usernameKey]; ok && rec.Username != "" {
+		im.fail(line, rec.Username, fmt.Errorf("用户名与第 %d 行重复", prev))
+		return
+	}
+	if prev, ok := im.emails[emailKey]; ok && rec.Email != "" {
+		im.fail(line, rec.Username, fmt.Errorf("邮箱与第 %d 行重复", prev))
+		return
+	}
+
+	// 无论本行成功与否都记录，后续重复行在试运行和正式导入时得到相同的结果
+	if rec.Username != "" {
+		im.usernames[usernameKey] = line
+	}
+	if rec.Email != "" {
+		im.emails[emailKey] = line
+	}
+
+	if _, err := im.userService.ImportUser(rec, im.report.DryRun); err != nil {
+		im.fail(line, rec.Username, err)
+		return
+	}
+	im.report.Imported++
+}
+
+// skip 记录无法解析为用户记录的行
+func (im *importer) skip(line int, username string, err error) {
+	im.report.Total++
+	im.fail(line, username, err)
+}
+
+// fail 记录单行失败
+func (im *importer) fail(line int, username string, err error) {
+	im.report.Failed++
+	im.report.Errors = append(im.report.Errors, RowError{
+		Line:     line,
+		Username: username,
+		Error:    err.Error(),
+	})
+}
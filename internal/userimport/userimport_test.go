@@ -0,0 +1,28 @@
+package userimport
+
+import (
+	"strings"
+	"testing"
+)
+
+// 首个字段解析失败的行应记为失败并继续读取，而不是 panic
+func TestRunCSVMalformedRows(t *testing.T) {
+	input := strings.Join([]string{
+		"username,email,password_hash,password_algorithm",
+		`al"ice,a@b.com,x,bcrypt`,
+		`bob,"b@b.com,x,bcrypt`,
+	}, "\n")
+
+	report, err := Run(nil, strings.NewReader(input), FormatCSV, true)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Total != 2 || report.Failed != 2 || report.Imported != 0 {
+		t.Fatalf("report = total %d, failed %d, imported %d; want 2, 2, 0", report.Total, report.Failed, report.Imported)
+	}
+	for i, want := range []int{2, 3} {
+		if got := report.Errors[i].Line; got != want {
+			t.Errorf("Errors[%d].Line = %d, want %d", i, got, want)
+		}
+	}
+}
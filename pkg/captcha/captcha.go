@@ -11,65 +11,106 @@ import (
 
 	"auth-service/internal/config"
 	"auth-service/pkg/logger"
+	"auth-service/pkg/redis"
 )
 
-// HCaptchaService hCaptcha 验证服务
-type HCaptchaService struct {
-	config     *config.HCaptchaConfig
-	httpClient *http.Client
-	logger     *logger.ZapLogger
+// 人机验证服务商
+const (
+	ProviderHCaptcha  = "hcaptcha"
+	ProviderRecaptcha = "recaptcha"
+	ProviderTurnstile = "turnstile"
+	ProviderSlider    = "slider" // 内置滑块验证
+	ProviderPoW       = "pow"    // 内置工作量证明
+)
+
+// Verifier 人机验证服务，校验前端提交的令牌
+type Verifier interface {
+	// VerifyToken 校验令牌，未启用验证时直接返回 nil
+	VerifyToken(ctx context.Context, token, clientIP string) error
+	// IsEnabled 是否启用验证
+	IsEnabled() bool
+	// GetSiteKey 站点密钥（用于前端）
+	GetSiteKey() string
+	// Provider 服务商名称，前端据此加载对应的组件
+	Provider() string
 }
 
-// HCaptchaVerifyRequest hCaptcha 验证请求
-type HCaptchaVerifyRequest struct {
-	Secret   string `json:"secret"`
-	Response string `json:"response"`
-	RemoteIP string `json:"remoteip,omitempty"`
-	SiteKey  string `json:"sitekey,omitempty"`
+// New 根据配置创建人机验证服务，内置验证方式使用 redisClient 保存挑战
+func New(cfg *config.CaptchaConfig, redisClient *redis.Client, logger *logger.ZapLogger) (Verifier, error) {
+	switch cfg.Provider {
+	case ProviderHCaptcha, "":
+		return NewHCaptchaService(cfg, logger), nil
+	case ProviderRecaptcha:
+		return NewRecaptchaService(cfg, logger), nil
+	case ProviderTurnstile:
+		return NewTurnstileService(cfg, logger), nil
+	case ProviderSlider:
+		return NewSliderService(cfg, redisClient, logger), nil
+	case ProviderPoW:
+		return NewPoWService(cfg, redisClient, logger)
+	}
+	return nil, fmt.Errorf("不支持的人机验证服务商: %q", cfg.Provider)
 }
 
-// HCaptchaVerifyResponse hCaptcha 验证响应
-type HCaptchaVerifyResponse struct {
+// SiteVerifyResponse 校验接口响应，hCaptcha、reCAPTCHA、Turnstile 格式兼容
+type SiteVerifyResponse struct {
 	Success     bool     `json:"success"`
 	ChallengeTS string   `json:"challenge_ts,omitempty"`
 	Hostname    string   `json:"hostname,omitempty"`
 	ErrorCodes  []string `json:"error-codes,omitempty"`
-	Credit      bool     `json:"credit,omitempty"`
+	Score       *float64 `json:"score,omitempty"`  // reCAPTCHA v3
+	Action      string   `json:"action,omitempty"` // reCAPTCHA v3、Turnstile
 }
 
-const hCaptchaVerifyURL = "https://hcaptcha.com/siteverify"
+// siteVerifier 各服务商共用的 siteverify 校验逻辑：以表单提交 secret、response、remoteip，返回 JSON
+type siteVerifier struct {
+	name       string // 服务商显示名称，用于日志和错误信息
+	provider   string
+	config     *config.CaptchaConfig
+	verifyURL  string
+	httpClient *http.Client
+	logger     *logger.ZapLogger
+}
 
-// NewHCaptchaService 创建 hCaptcha 验证服务
-func NewHCaptchaService(cfg *config.HCaptchaConfig, logger *logger.ZapLogger) *HCaptchaService {
-	return &HCaptchaService{
-		config: cfg,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		logger: logger,
+func newSiteVerifier(name, provider, defaultURL string, cfg *config.CaptchaConfig, logger *logger.ZapLogger) siteVerifier {
+	verifyURL := cfg.VerifyURL
+	if verifyURL == "" {
+		verifyURL = defaultURL
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return siteVerifier{
+		name:       name,
+		provider:   provider,
+		config:     cfg,
+		verifyURL:  verifyURL,
+		httpClient: &http.Client{Timeout: timeout},
+		logger:     logger,
 	}
 }
 
-// VerifyToken 验证 hCaptcha 令牌
-func (s *HCaptchaService) VerifyToken(ctx context.Context, token, clientIP string) error {
+// verify 调用校验接口，extra 为服务商特有的表单参数，check 对校验通过的响应做额外检查
+func (v *siteVerifier) verify(ctx context.Context, token, clientIP string, extra url.Values, check func(*SiteVerifyResponse) error) error {
 	// 如果未启用验证，直接返回成功
-	if !s.config.Enabled {
-		s.logger.Debug("hCaptcha 验证已禁用，跳过验证")
+	if !v.config.Enabled {
+		v.logger.Debug(v.name + " 验证已禁用，跳过验证")
 		return nil
 	}
 
 	// 检查必要的配置
-	if s.config.SecretKey == "" {
-		return fmt.Errorf("hCaptcha SecretKey 未配置")
+	if v.config.SecretKey == "" {
+		return fmt.Errorf("%s SecretKey 未配置", v.name)
 	}
 
 	if token == "" {
-		return fmt.Errorf("hCaptcha 令牌不能为空")
+		return fmt.Errorf("%s 令牌不能为空", v.name)
 	}
 
 	// 构建请求参数
 	data := url.Values{
-		"secret":   {s.config.SecretKey},
+		"secret":   {v.config.SecretKey},
 		"response": {token},
 	}
 
@@ -77,50 +118,63 @@ func (s *HCaptchaService) VerifyToken(ctx context.Context, token, clientIP strin
 	if clientIP != "" {
 		data.Set("remoteip", clientIP)
 	}
+	for key, values := range extra {
+		data[key] = values
+	}
 
 	// 创建请求
-	req, err := http.NewRequestWithContext(ctx, "POST", hCaptchaVerifyURL, bytes.NewBufferString(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", v.verifyURL, bytes.NewBufferString(data.Encode()))
 	if err != nil {
-		return fmt.Errorf("创建 hCaptcha 验证请求失败: %w", err)
+		return fmt.Errorf("创建 %s 验证请求失败: %w", v.name, err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// 发送请求
-	resp, err := s.httpClient.Do(req)
+	resp, err := v.httpClient.Do(req)
 	if err != nil {
-		s.logger.Error("hCaptcha 验证请求失败",
+		v.logger.Error(v.name+" 验证请求失败",
 			logger.String("client_ip", clientIP),
 			logger.Error(err),
 		)
-		return fmt.Errorf("hCaptcha 验证请求失败: %w", err)
+		return fmt.Errorf("%s 验证请求失败: %w", v.name, err)
 	}
 	defer resp.Body.Close()
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("hCaptcha API 返回错误状态码: %d", resp.StatusCode)
+		return fmt.Errorf("%s API 返回错误状态码: %d", v.name, resp.StatusCode)
 	}
 
 	// 解析响应
-	var verifyResp HCaptchaVerifyResponse
+	var verifyResp SiteVerifyResponse
 	if err := json.NewDecoder(resp.Body).Decode(&verifyResp); err != nil {
-		return fmt.Errorf("解析 hCaptcha 响应失败: %w", err)
+		return fmt.Errorf("解析 %s 响应失败: %w", v.name, err)
 	}
 
 	// 验证结果
 	if !verifyResp.Success {
-		s.logger.Warn("hCaptcha 验证失败",
+		v.logger.Warn(v.name+" 验证失败",
 			logger.String("client_ip", clientIP),
 			logger.Any("error_codes", verifyResp.ErrorCodes),
 		)
 
 		// 根据错误代码返回具体错误信息
-		errorMsg := s.getErrorMessage(verifyResp.ErrorCodes)
-		return fmt.Errorf("hCaptcha 验证失败: %s", errorMsg)
+		return fmt.Errorf("%s 验证失败: %s", v.name, getErrorMessage(verifyResp.ErrorCodes))
 	}
 
-	s.logger.Debug("hCaptcha 验证成功",
+	if check != nil {
+		if err := check(&verifyResp); err != nil {
+			v.logger.Warn(v.name+" 验证未通过",
+				logger.String("client_ip", clientIP),
+				logger.String("action", verifyResp.Action),
+				logger.Error(err),
+			)
+			return fmt.Errorf("%s 验证失败: %w", v.name, err)
+		}
+	}
+
+	v.logger.Debug(v.name+" 验证成功",
 		logger.String("client_ip", clientIP),
 		logger.String("hostname", verifyResp.Hostname),
 		logger.String("challenge_ts", verifyResp.ChallengeTS),
@@ -129,8 +183,31 @@ func (s *HCaptchaService) VerifyToken(ctx context.Context, token, clientIP strin
 	return nil
 }
 
+// checkAction 配置了期望的 action 时，检查响应中的 action 是否一致（防止其他页面获取的令牌被挪用）
+func (v *siteVerifier) checkAction(resp *SiteVerifyResponse) error {
+	if v.config.Action != "" && resp.Action != v.config.Action {
+		return fmt.Errorf("action 不匹配: %q", resp.Action)
+	}
+	return nil
+}
+
+// IsEnabled 检查是否启用验证
+func (v *siteVerifier) IsEnabled() bool {
+	return v.config.Enabled
+}
+
+// GetSiteKey 获取站点密钥（用于前端）
+func (v *siteVerifier) GetSiteKey() string {
+	return v.config.SiteKey
+}
+
+// Provider 服务商名称
+func (v *siteVerifier) Provider() string {
+	return v.provider
+}
+
 // getErrorMessage 根据错误代码获取错误消息
-func (s *HCaptchaService) getErrorMessage(errorCodes []string) string {
+func getErrorMessage(errorCodes []string) string {
 	if len(errorCodes) == 0 {
 		return "未知错误"
 	}
@@ -142,8 +219,10 @@ func (s *HCaptchaService) getErrorMessage(errorCodes []string) string {
 		"invalid-input-response":           "响应参数无效或格式错误",
 		"bad-request":                      "请求格式错误",
 		"invalid-or-already-seen-response": "响应参数无效或已被使用",
+		"timeout-or-duplicate":             "令牌已过期或已被使用",
 		"not-using-dummy-passcode":         "未使用测试通行码",
 		"sitekey-secret-mismatch":          "站点密钥与密钥不匹配",
+		"internal-error":                   "验证服务内部错误",
 	}
 
 	// 返回第一个错误的中文描述
@@ -153,13 +232,3 @@ func (s *HCaptchaService) getErrorMessage(errorCodes []string) string {
 
 	return fmt.Sprintf("验证码错误 (%s)", errorCodes[0])
 }
-
-// IsEnabled 检查 hCaptcha 是否启用
-func (s *HCaptchaService) IsEnabled() bool {
-	return s.config.Enabled
-}
-
-// GetSiteKey 获取站点密钥（用于前端）
-func (s *HCaptchaService) GetSiteKey() string {
-	return s.config.SiteKey
-}
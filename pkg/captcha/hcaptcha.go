@@ -0,0 +1,33 @@
+package captcha
+
+import (
+	"context"
+	"net/url"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/logger"
+)
+
+const hCaptchaVerifyURL = "https://hcaptcha.com/siteverify"
+
+// HCaptchaService hCaptcha 验证服务
+type HCaptchaService struct {
+	siteVerifier
+}
+
+// NewHCaptchaService 创建 hCaptcha 验证服务
+func NewHCaptchaService(cfg *config.CaptchaConfig, logger *logger.ZapLogger) *HCaptchaService {
+	return &HCaptchaService{
+		siteVerifier: newSiteVerifier("hCaptcha", ProviderHCaptcha, hCaptchaVerifyURL, cfg, logger),
+	}
+}
+
+// VerifyToken 验证 hCaptcha 令牌
+func (s *HCaptchaService) VerifyToken(ctx context.Context, token, clientIP string) error {
+	// 携带站点密钥，防止其他站点获取的令牌被挪用
+	var extra url.Values
+	if s.config.SiteKey != "" {
+		extra = url.Values{"sitekey": {s.config.SiteKey}}
+	}
+	return s.verify(ctx, token, clientIP, extra, nil)
+}
@@ -0,0 +1,239 @@
+package captcha
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/redis"
+)
+
+// maxPoWDifficulty 难度上限的硬性限制，避免配置错误导致客户端无法完成计算
+const maxPoWDifficulty = 32
+
+// PoWChallenge 工作量证明挑战
+//
+// 客户端需要找到一个 nonce，使 SHA-256(challenge + ":" + nonce) 的前 difficulty 位均为 0，
+// 然后将 PoWSolution 的 JSON 作为 captcha_token 提交
+type PoWChallenge struct {
+	Type       string `json:"type"`      // 固定为 pow
+	Algorithm  string `json:"algorithm"` // 固定为 SHA-256
+	Challenge  string `json:"challenge"` // 服务端签名的挑战，原样参与哈希计算
+	Difficulty int    `json:"difficulty"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+// PoWSolution 前端提交的工作量证明结果
+type PoWSolution struct {
+	Challenge string `json:"challenge"`
+	Nonce     string `json:"nonce"`
+}
+
+// PoWService 内置工作量证明验证：挑战由服务端签名、无需存储，校验通过后在 Redis 中标记已使用；
+// 难度随全站登录失败频率自动提高，不需要用户交互，适合无法使用第三方验证的辅助工具和隐私浏览器
+type PoWService struct {
+	config      *config.CaptchaConfig
+	redisClient *redis.Client
+	logger      *logger.ZapLogger
+}
+
+// NewPoWService 创建工作量证明验证服务
+func NewPoWService(cfg *config.CaptchaConfig, redisClient *redis.Client, logger *logger.ZapLogger) (*PoWService, error) {
+	if cfg.Enabled && cfg.SecretKey == "" {
+		return nil, errors.New("工作量证明需要配置 SecretKey 用于签名挑战")
+	}
+	if cfg.PoW.Difficulty < 1 || cfg.PoW.MaxDifficulty < cfg.PoW.Difficulty || cfg.PoW.MaxDifficulty > maxPoWDifficulty {
+		return nil, fmt.Errorf("工作量证明难度配置无效: %d~%d", cfg.PoW.Difficulty, cfg.PoW.MaxDifficulty)
+	}
+	return &PoWService{
+		config:      cfg,
+		redisClient: redisClient,
+		logger:      logger,
+	}, nil
+}
+
+// IssueChallenge 按当前登录失败频率生成挑战
+func (s *PoWService) IssueChallenge(ctx context.Context, clientIP string) (interface{}, error) {
+	difficulty, err := s.currentDifficulty(ctx)
+	if err != nil {
+		// 统计不可用时使用最高难度
+		s.logger.Warn("读取登录失败频率失败，使用最高难度",
+			logger.String("client_ip", clientIP),
+			logger.Error(err),
+		)
+		difficulty = s.config.PoW.MaxDifficulty
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(s.config.PoW.TTL).Unix()
+	payload := fmt.Sprintf("%s.%d.%d", id, difficulty, expiresAt)
+
+	return &PoWChallenge{
+		Type:       "pow",
+		Algorithm:  "SHA-256",
+		Challenge:  payload + "." + s.sign(payload),
+		Difficulty: difficulty,
+		ExpiresIn:  int64(s.config.PoW.TTL.Seconds()),
+	}, nil
+}
+
+// VerifyToken 校验工作量证明：签名、有效期、哈希前导零，通过后标记挑战已使用
+func (s *PoWService) VerifyToken(ctx context.Context, token, clientIP string) error {
+	if !s.config.Enabled {
+		s.logger.Debug("工作量证明已禁用，跳过验证")
+		return nil
+	}
+	if token == "" {
+		return errors.New("工作量证明结果不能为空")
+	}
+
+	var solution PoWSolution
+	if err := json.Unmarshal([]byte(token), &solution); err != nil || solution.Nonce == "" || len(solution.Nonce) > 64 {
+		return errors.New("工作量证明结果格式错误")
+	}
+
+	id, difficulty, expiresAt, err := s.parseChallenge(solution.Challenge)
+	if err != nil {
+		s.logger.Warn("工作量证明挑战无效",
+			logger.String("client_ip", clientIP),
+			logger.Error(err),
+		)
+		return err
+	}
+	ttl := time.Until(time.Unix(expiresAt, 0))
+	if ttl <= 0 {
+		return errors.New("工作量证明已过期，请刷新后重试")
+	}
+
+	sum := sha256.Sum256([]byte(solution.Challenge + ":" + solution.Nonce))
+	if leadingZeroBits(sum[:]) < difficulty {
+		return errors.New("工作量证明结果不正确")
+	}
+
+	// 挑战在有效期内只能使用一次
+	ok, err := s.redisClient.SetNX(ctx, powUsedKey(id), 1, ttl)
+	if err != nil {
+		return fmt.Errorf("标记工作量证明已使用失败: %w", err)
+	}
+	if !ok {
+		s.logger.Warn("工作量证明重复提交",
+			logger.String("client_ip", clientIP),
+		)
+		return errors.New("工作量证明已被使用，请刷新后重试")
+	}
+	return nil
+}
+
+// parseChallenge 校验签名并解析挑战：id.difficulty.expiresAt.signature
+func (s *PoWService) parseChallenge(challenge string) (id string, difficulty int, expiresAt int64, err error) {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 4 {
+		return "", 0, 0, errors.New("工作量证明挑战格式错误")
+	}
+	payload := strings.Join(parts[:3], ".")
+	if subtle.ConstantTimeCompare([]byte(parts[3]), []byte(s.sign(payload))) != 1 {
+		return "", 0, 0, errors.New("工作量证明挑战签名无效")
+	}
+	if difficulty, err = strconv.Atoi(parts[1]); err != nil {
+		return "", 0, 0, errors.New("工作量证明挑战格式错误")
+	}
+	if expiresAt, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+		return "", 0, 0, errors.New("工作量证明挑战格式错误")
+	}
+	return parts[0], difficulty, expiresAt, nil
+}
+
+// currentDifficulty 根据最近一分钟的全站登录失败次数计算难度
+func (s *PoWService) currentDifficulty(ctx context.Context) (int, error) {
+	cfg := s.config.PoW
+	if cfg.FailuresPerStep <= 0 {
+		return cfg.Difficulty, nil
+	}
+	failures, err := s.failureRate(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return min(cfg.Difficulty+failures/cfg.FailuresPerStep, cfg.MaxDifficulty), nil
+}
+
+// failureRate 估算最近一分钟的登录失败次数：当前分钟的计数加上上一分钟按剩余比例折算的计数
+func (s *PoWService) failureRate(ctx context.Context, now time.Time) (int, error) {
+	current, err := s.count(ctx, globalFailuresKey(now))
+	if err != nil {
+		return 0, err
+	}
+	previous, err := s.count(ctx, globalFailuresKey(now.Add(-time.Minute)))
+	if err != nil {
+		return 0, err
+	}
+	elapsed := float64(now.Second()) / 60
+	return current + int(float64(previous)*(1-elapsed)), nil
+}
+
+func (s *PoWService) count(ctx context.Context, key string) (int, error) {
+	value, err := s.redisClient.Get(ctx, key)
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询登录失败频率失败: %w", err)
+	}
+	n, _ := strconv.Atoi(value)
+	return n, nil
+}
+
+func (s *PoWService) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.SecretKey))
+	mac.Write([]byte("pow:" + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEnabled 检查是否启用验证
+func (s *PoWService) IsEnabled() bool {
+	return s.config.Enabled
+}
+
+// GetSiteKey 工作量证明没有站点密钥
+func (s *PoWService) GetSiteKey() string {
+	return ""
+}
+
+// Provider 服务商名称
+func (s *PoWService) Provider() string {
+	return ProviderPoW
+}
+
+// leadingZeroBits 计算哈希值的前导零位数
+func leadingZeroBits(sum []byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+func powUsedKey(id string) string {
+	return "captcha:pow:used:" + id
+}
+
+// globalFailuresKey 全站登录失败次数，按分钟分桶
+func globalFailuresKey(t time.Time) string {
+	return "captcha:failures:global:" + strconv.FormatInt(t.Unix()/60, 10)
+}
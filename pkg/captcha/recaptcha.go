@@ -0,0 +1,46 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/logger"
+)
+
+// recaptchaVerifyURL reCAPTCHA 校验接口；无法访问 google.com 时可配置为 https://www.recaptcha.net/recaptcha/api/siteverify
+const recaptchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+
+// RecaptchaService Google reCAPTCHA 验证服务，支持 v2（复选框 / 隐形）和 v3（评分）
+type RecaptchaService struct {
+	siteVerifier
+}
+
+// NewRecaptchaService 创建 reCAPTCHA 验证服务
+func NewRecaptchaService(cfg *config.CaptchaConfig, logger *logger.ZapLogger) *RecaptchaService {
+	return &RecaptchaService{
+		siteVerifier: newSiteVerifier("reCAPTCHA", ProviderRecaptcha, recaptchaVerifyURL, cfg, logger),
+	}
+}
+
+// VerifyToken 验证 reCAPTCHA 令牌；v3 令牌的响应带有评分，低于阈值或 action 不匹配时拒绝
+// 配置了 MinScore 时站点按 v3 校验，没有评分的 v2 令牌会被拒绝，否则可以用 v2 令牌绕过评分
+func (s *RecaptchaService) VerifyToken(ctx context.Context, token, clientIP string) error {
+	return s.verify(ctx, token, clientIP, nil, func(resp *SiteVerifyResponse) error {
+		if resp.Score == nil {
+			if s.config.MinScore > 0 {
+				return errors.New("响应缺少评分，不接受 v2 令牌")
+			}
+			// v2 令牌没有评分
+			return nil
+		}
+		if *resp.Score < s.config.MinScore {
+			return fmt.Errorf("评分过低: %.1f", *resp.Score)
+		}
+		if resp.Action == "" {
+			return errors.New("响应缺少 action")
+		}
+		return s.checkAction(resp)
+	})
+}
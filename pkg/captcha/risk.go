@@ -0,0 +1,221 @@
+package captcha
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/redis"
+)
+
+// 要求人机验证的原因
+const (
+	ReasonAlwaysRequired  = "always"           // 未启用自适应验证，每次都要求
+	ReasonIPFailures      = "ip_failures"      // 该 IP 近期登录失败次数过多
+	ReasonAccountFailures = "account_failures" // 该账号近期登录失败次数过多
+	ReasonNewDevice       = "new_device"       // 该设备没有成功登录过该账号
+	ReasonHighVelocity    = "high_velocity"    // 该 IP 请求频率过高
+)
+
+// incrScript 原子地将计数加一，首次创建时设置过期时间
+// KEYS[1] 计数；ARGV[1] 过期时间（毫秒）；返回加一后的值
+const incrScript = `
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`
+
+// LoginSignals 评估登录风险使用的信息
+type LoginSignals struct {
+	IP       string
+	Username string
+	DeviceID string // 客户端保存的设备标识，登录成功后由服务端下发
+}
+
+// Assessment 风险评估结果
+type Assessment struct {
+	CaptchaRequired bool
+	Reasons         []string
+}
+
+// Assessor 登录风险评估器，决定本次登录是否需要人机验证
+//
+// 近期登录失败过多的 IP 或账号、没有成功登录过该账号的设备、请求频率过高的 IP 需要验证，
+// 其余登录（如常用设备）免验证。统计数据保存在 Redis 中，各实例共享。
+type Assessor struct {
+	cfg         config.AdaptiveCaptchaConfig
+	redisClient *redis.Client
+}
+
+// NewAssessor 创建登录风险评估器
+func NewAssessor(cfg *config.AdaptiveCaptchaConfig, redisClient *redis.Client) *Assessor {
+	return &Assessor{cfg: *cfg, redisClient: redisClient}
+}
+
+// Assess 评估登录是否需要人机验证，不改变任何统计
+func (a *Assessor) Assess(ctx context.Context, s LoginSignals) (*Assessment, error) {
+	if !a.cfg.Enabled {
+		return &Assessment{CaptchaRequired: true, Reasons: []string{ReasonAlwaysRequired}}, nil
+	}
+
+	result := &Assessment{}
+	add := func(reason string) {
+		result.CaptchaRequired = true
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	if n, err := a.count(ctx, ipFailuresKey(s.IP)); err != nil {
+		return nil, err
+	} else if n >= a.cfg.FailureThreshold {
+		add(ReasonIPFailures)
+	}
+	if n, err := a.count(ctx, accountFailuresKey(s.Username)); err != nil {
+		return nil, err
+	} else if n >= a.cfg.FailureThreshold {
+		add(ReasonAccountFailures)
+	}
+	if n, err := a.count(ctx, velocityKey(s.IP)); err != nil {
+		return nil, err
+	} else if a.cfg.VelocityLimit > 0 && n > a.cfg.VelocityLimit {
+		add(ReasonHighVelocity)
+	}
+	if a.cfg.NewDevice {
+		trusted, err := a.deviceTrusted(ctx, s.Username, s.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+		if !trusted {
+			add(ReasonNewDevice)
+		}
+	}
+	return result, nil
+}
+
+// RecordAttempt 记录一次登录请求，用于统计请求频率
+func (a *Assessor) RecordAttempt(ctx context.Context, ip string) error {
+	if !a.cfg.Enabled || a.cfg.VelocityLimit <= 0 {
+		return nil
+	}
+	return a.incr(ctx, velocityKey(ip), a.cfg.VelocityWindow)
+}
+
+// RecordFailure 记录一次登录失败（用户名不存在也记录，避免通过是否要求验证判断账号是否存在）
+// 全站失败次数无论是否启用自适应验证都会统计，供工作量证明调整难度
+func (a *Assessor) RecordFailure(ctx context.Context, ip, username string) error {
+	if err := a.incr(ctx, globalFailuresKey(time.Now()), 2*time.Minute); err != nil {
+		return err
+	}
+	if !a.cfg.Enabled {
+		return nil
+	}
+	if err := a.incr(ctx, ipFailuresKey(ip), a.cfg.FailureWindow); err != nil {
+		return err
+	}
+	return a.incr(ctx, accountFailuresKey(username), a.cfg.FailureWindow)
+}
+
+// RecordSuccess 记录一次密码验证通过：清除账号失败次数并信任该设备，返回设备标识
+// deviceID 为空或格式不正确时生成新的设备标识，客户端应保存并在之后的登录中携带
+func (a *Assessor) RecordSuccess(ctx context.Context, username, deviceID string) (string, error) {
+	if !validDeviceID(deviceID) {
+		var err error
+		if deviceID, err = NewDeviceID(); err != nil {
+			return "", err
+		}
+	}
+	if !a.cfg.Enabled {
+		return deviceID, nil
+	}
+
+	if err := a.redisClient.Del(ctx, accountFailuresKey(username)); err != nil {
+		return "", fmt.Errorf("清除登录失败次数失败: %w", err)
+	}
+	if a.cfg.NewDevice {
+		if err := a.redisClient.Set(ctx, deviceKey(username, deviceID), 1, a.cfg.DeviceTTL); err != nil {
+			return "", fmt.Errorf("保存可信设备失败: %w", err)
+		}
+	}
+	return deviceID, nil
+}
+
+// deviceTrusted 判断设备是否成功登录过该账号
+func (a *Assessor) deviceTrusted(ctx context.Context, username, deviceID string) (bool, error) {
+	if !validDeviceID(deviceID) {
+		return false, nil
+	}
+	ok, err := a.redisClient.Exists(ctx, deviceKey(username, deviceID))
+	if err != nil {
+		return false, fmt.Errorf("查询可信设备失败: %w", err)
+	}
+	return ok, nil
+}
+
+// count 读取计数，不存在时为 0
+func (a *Assessor) count(ctx context.Context, key string) (int, error) {
+	value, err := a.redisClient.Get(ctx, key)
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询登录风险统计失败: %w", err)
+	}
+	var n int
+	fmt.Sscan(value, &n)
+	return n, nil
+}
+
+func (a *Assessor) incr(ctx context.Context, key string, window time.Duration) error {
+	if _, err := a.redisClient.Eval(ctx, incrScript, []string{key}, window.Milliseconds()); err != nil {
+		return fmt.Errorf("更新登录风险统计失败: %w", err)
+	}
+	return nil
+}
+
+// NewDeviceID 生成随机设备标识
+func NewDeviceID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成设备标识失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validDeviceID 检查设备标识格式（32位十六进制）
+func validDeviceID(deviceID string) bool {
+	if len(deviceID) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(deviceID)
+	return err == nil
+}
+
+// normalizeUsername 用户名不区分大小写
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipFailuresKey(ip string) string {
+	return "captcha:failures:ip:" + ip
+}
+
+func accountFailuresKey(username string) string {
+	return "captcha:failures:user:" + normalizeUsername(username)
+}
+
+func velocityKey(ip string) string {
+	return "captcha:velocity:" + ip
+}
+
+// deviceKey 可信设备键，只保存设备标识的哈希
+func deviceKey(username, deviceID string) string {
+	sum := sha256.Sum256([]byte(deviceID))
+	return fmt.Sprintf("captcha:device:%s:%s", normalizeUsername(username), hex.EncodeToString(sum[:16]))
+}
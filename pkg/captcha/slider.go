@@ -0,0 +1,381 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/redis"
+)
+
+// 滑动轨迹校验参数
+const (
+	minTrackPoints = 5    // 最少轨迹点数
+	minSpeedCV     = 0.05 // 各段速度的变异系数下限，低于该值视为程序匀速拖动
+)
+
+// consumeChallengeScript 原子地读取并删除挑战，每个挑战只能提交一次
+const consumeChallengeScript = `
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+end
+return v
+`
+
+// Challenger 需要先向服务端获取挑战的人机验证方式（如滑块、工作量证明）
+type Challenger interface {
+	// IssueChallenge 生成新的挑战，返回值直接序列化为 JSON 响应
+	IssueChallenge(ctx context.Context, clientIP string) (interface{}, error)
+}
+
+// SliderChallenge 滑块挑战
+type SliderChallenge struct {
+	Type        string `json:"type"` // 固定为 slider
+	ChallengeID string `json:"challenge_id"`
+	Background  string `json:"background"` // 带缺口的背景图（PNG data URL）
+	Piece       string `json:"piece"`      // 拼图块（PNG data URL，透明背景），初始位于最左侧
+	PieceY      int    `json:"piece_y"`    // 拼图块顶部在背景图中的纵坐标
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// SliderSolution 前端提交的滑块结果，序列化为 JSON 后作为 captcha_token 提交
+type SliderSolution struct {
+	ChallengeID string       `json:"challenge_id"`
+	X           float64      `json:"x"`     // 拼图块最终的横向偏移
+	Track       []TrackPoint `json:"track"` // 拖动轨迹
+}
+
+// TrackPoint 拖动轨迹点
+type TrackPoint struct {
+	X float64 `json:"x"` // 相对拖动起点的横向偏移
+	Y float64 `json:"y"`
+	T int64   `json:"t"` // 相对拖动开始的毫秒数
+}
+
+// SliderService 内置滑块验证：生成带缺口的背景图和拼图块，缺口位置只保存在 Redis 中，
+// 校验提交的偏移量和拖动轨迹（耗时、速度变化），不依赖第三方服务
+type SliderService struct {
+	config      *config.CaptchaConfig
+	redisClient *redis.Client
+	logger      *logger.ZapLogger
+}
+
+// NewSliderService 创建滑块验证服务
+func NewSliderService(cfg *config.CaptchaConfig, redisClient *redis.Client, logger *logger.ZapLogger) *SliderService {
+	return &SliderService{
+		config:      cfg,
+		redisClient: redisClient,
+		logger:      logger,
+	}
+}
+
+// IssueChallenge 生成滑块挑战
+func (s *SliderService) IssueChallenge(ctx context.Context, _ string) (interface{}, error) {
+	cfg := s.config.Slider
+	size := cfg.PieceSize
+	knob := size / 5
+	box := size + knob
+	// 缺口横坐标取值范围为 Width-2*box-15，纵坐标为 Height-box-10，均须大于 0
+	if cfg.Width < 3*box || cfg.Width-2*box-15 <= 0 || cfg.Height <= box+10 {
+		return nil, errors.New("滑块验证图片尺寸配置过小")
+	}
+
+	var seed [32]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		return nil, fmt.Errorf("生成滑块挑战失败: %w", err)
+	}
+	rng := rand.New(rand.NewChaCha8(seed))
+
+	// 缺口不贴近左侧起点，避免不拖动或轻微拖动即可通过
+	x := box + 10 + rng.IntN(cfg.Width-2*box-15)
+	y := 5 + rng.IntN(cfg.Height-box-10)
+
+	bg := drawBackground(cfg.Width, cfg.Height, rng)
+	mask := pieceMask(size, knob)
+	piece := cutPiece(bg, mask, box, x, y, rng)
+	background, err := encodePNG(bg)
+	if err != nil {
+		return nil, err
+	}
+	pieceImage, err := encodePNG(piece)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisClient.Set(ctx, sliderKey(id), x, cfg.TTL); err != nil {
+		return nil, fmt.Errorf("存储滑块挑战失败: %w", err)
+	}
+
+	return &SliderChallenge{
+		Type:        "slider",
+		ChallengeID: id,
+		Background:  background,
+		Piece:       pieceImage,
+		PieceY:      y,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		ExpiresIn:   int64(cfg.TTL.Seconds()),
+	}, nil
+}
+
+// VerifyToken 校验滑块结果，token 为 SliderSolution 的 JSON；每个挑战只能提交一次
+func (s *SliderService) VerifyToken(ctx context.Context, token, clientIP string) error {
+	if !s.config.Enabled {
+		s.logger.Debug("滑块验证已禁用，跳过验证")
+		return nil
+	}
+	if token == "" {
+		return errors.New("滑块验证结果不能为空")
+	}
+
+	var solution SliderSolution
+	if err := json.Unmarshal([]byte(token), &solution); err != nil || solution.ChallengeID == "" {
+		return errors.New("滑块验证结果格式错误")
+	}
+
+	stored, err := s.redisClient.Eval(ctx, consumeChallengeScript, []string{sliderKey(solution.ChallengeID)})
+	if errors.Is(err, redis.Nil) {
+		return errors.New("滑块验证已过期，请刷新后重试")
+	}
+	if err != nil {
+		return fmt.Errorf("读取滑块挑战失败: %w", err)
+	}
+	value, _ := stored.(string)
+	expected, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.New("滑块挑战数据损坏")
+	}
+
+	tolerance := float64(s.config.Slider.Tolerance)
+	if math.Abs(solution.X-float64(expected)) > tolerance {
+		s.logger.Debug("滑块位置不匹配",
+			logger.String("client_ip", clientIP),
+			logger.Any("x", solution.X),
+			logger.Int("expected", expected),
+		)
+		return errors.New("滑块位置不正确")
+	}
+	if err := s.checkTrack(solution.Track, solution.X); err != nil {
+		s.logger.Warn("滑块轨迹异常",
+			logger.String("client_ip", clientIP),
+			logger.Error(err),
+		)
+		return fmt.Errorf("滑块轨迹异常: %w", err)
+	}
+	return nil
+}
+
+// checkTrack 校验拖动轨迹：时间递增、耗时合理、终点与提交位置一致、速度有变化（非匀速）
+func (s *SliderService) checkTrack(track []TrackPoint, x float64) error {
+	if len(track) < minTrackPoints {
+		return errors.New("轨迹点过少")
+	}
+	for i := 1; i < len(track); i++ {
+		if track[i].T < track[i-1].T {
+			return errors.New("轨迹时间不是递增的")
+		}
+	}
+
+	duration := time.Duration(track[len(track)-1].T-track[0].T) * time.Millisecond
+	if duration < s.config.Slider.MinDuration {
+		return fmt.Errorf("拖动过快: %v", duration)
+	}
+	if duration > s.config.Slider.TTL {
+		return fmt.Errorf("拖动耗时过长: %v", duration)
+	}
+	if math.Abs(track[len(track)-1].X-x) > float64(s.config.Slider.Tolerance) {
+		return errors.New("轨迹终点与提交位置不一致")
+	}
+
+	var speeds []float64
+	for i := 1; i < len(track); i++ {
+		if dt := track[i].T - track[i-1].T; dt > 0 {
+			speeds = append(speeds, (track[i].X-track[i-1].X)/float64(dt))
+		}
+	}
+	if len(speeds) < minTrackPoints-2 {
+		return errors.New("有效轨迹点过少")
+	}
+	var mean, variance float64
+	for _, v := range speeds {
+		mean += v
+	}
+	mean /= float64(len(speeds))
+	for _, v := range speeds {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(speeds))
+	if mean <= 0 || math.Sqrt(variance)/mean < minSpeedCV {
+		return errors.New("拖动速度过于均匀")
+	}
+	return nil
+}
+
+// IsEnabled 检查是否启用验证
+func (s *SliderService) IsEnabled() bool {
+	return s.config.Enabled
+}
+
+// GetSiteKey 滑块验证没有站点密钥
+func (s *SliderService) GetSiteKey() string {
+	return ""
+}
+
+// Provider 服务商名称
+func (s *SliderService) Provider() string {
+	return ProviderSlider
+}
+
+// drawBackground 生成随机背景：渐变底色、半透明圆形色块和像素噪点
+func drawBackground(w, h int, rng *rand.Rand) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	from := randomColor(rng)
+	to := randomColor(rng)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			t := (float64(x)/float64(w) + float64(y)/float64(h)) / 2
+			img.SetRGBA(x, y, color.RGBA{
+				R: lerp(from.R, to.R, t),
+				G: lerp(from.G, to.G, t),
+				B: lerp(from.B, to.B, t),
+				A: 255,
+			})
+		}
+	}
+
+	for i := 0; i < 14; i++ {
+		c := randomColor(rng)
+		cx, cy := rng.IntN(w), rng.IntN(h)
+		r := 10 + rng.IntN(h/3)
+		for y := max(0, cy-r); y < min(h, cy+r); y++ {
+			for x := max(0, cx-r); x < min(w, cx+r); x++ {
+				if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r {
+					p := img.RGBAAt(x, y)
+					img.SetRGBA(x, y, color.RGBA{R: lerp(p.R, c.R, 0.5), G: lerp(p.G, c.G, 0.5), B: lerp(p.B, c.B, 0.5), A: 255})
+				}
+			}
+		}
+	}
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		n := rng.IntN(25) - 12
+		for j := 0; j < 3; j++ {
+			img.Pix[i+j] = clamp(int(img.Pix[i+j]) + n)
+		}
+	}
+	return img
+}
+
+// pieceMask 拼图块形状：正方形加上方和右侧两个半圆凸起，返回 (size+knob)² 的掩码
+func pieceMask(size, knob int) [][]bool {
+	box := size + knob
+	mask := make([][]bool, box)
+	for y := range mask {
+		mask[y] = make([]bool, box)
+		for x := range mask[y] {
+			inSquare := x < size && y >= knob
+			inTop := dist2(x, y, size/2, knob) <= knob*knob
+			inRight := dist2(x, y, size, knob+size/2) <= knob*knob
+			mask[y][x] = inSquare || inTop || inRight
+		}
+	}
+	return mask
+}
+
+// cutPiece 从背景 (x, y) 处切出拼图块，背景上留下暗色缺口
+// 只有拼图块带描边；缺口不描边，并叠加与背景相同强度的噪点，避免通过边缘检测直接定位缺口
+func cutPiece(bg *image.RGBA, mask [][]bool, box, x, y int, rng *rand.Rand) *image.RGBA {
+	piece := image.NewRGBA(image.Rect(0, 0, box, box))
+	edge := func(px, py int) bool {
+		for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			nx, ny := px+d[0], py+d[1]
+			if nx < 0 || ny < 0 || nx >= box || ny >= box || !mask[ny][nx] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for py := 0; py < box; py++ {
+		for px := 0; px < box; px++ {
+			if !mask[py][px] {
+				continue
+			}
+			src := bg.RGBAAt(x+px, y+py)
+			if edge(px, py) {
+				piece.SetRGBA(px, py, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+			} else {
+				piece.SetRGBA(px, py, src)
+			}
+			n := rng.IntN(25) - 12
+			bg.SetRGBA(x+px, y+py, color.RGBA{
+				R: clamp(int(src.R)/2 + n),
+				G: clamp(int(src.G)/2 + n),
+				B: clamp(int(src.B)/2 + n),
+				A: 255,
+			})
+		}
+	}
+	return piece
+}
+
+func encodePNG(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("生成滑块图片失败: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func randomColor(rng *rand.Rand) color.RGBA {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], rng.Uint32())
+	return color.RGBA{R: 40 + b[0]%180, G: 40 + b[1]%180, B: 40 + b[2]%180, A: 255}
+}
+
+func lerp(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
+
+func clamp(v int) uint8 {
+	return uint8(max(0, min(255, v)))
+}
+
+func dist2(x1, y1, x2, y2 int) int {
+	return (x1-x2)*(x1-x2) + (y1-y2)*(y1-y2)
+}
+
+// randomID 生成随机挑战ID
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", fmt.Errorf("生成挑战ID失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func sliderKey(id string) string {
+	return "captcha:slider:" + id
+}
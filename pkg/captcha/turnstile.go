@@ -0,0 +1,27 @@
+package captcha
+
+import (
+	"context"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/logger"
+)
+
+const turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+// TurnstileService Cloudflare Turnstile 验证服务
+type TurnstileService struct {
+	siteVerifier
+}
+
+// NewTurnstileService 创建 Turnstile 验证服务
+func NewTurnstileService(cfg *config.CaptchaConfig, logger *logger.ZapLogger) *TurnstileService {
+	return &TurnstileService{
+		siteVerifier: newSiteVerifier("Turnstile", ProviderTurnstile, turnstileVerifyURL, cfg, logger),
+	}
+}
+
+// VerifyToken 验证 Turnstile 令牌
+func (s *TurnstileService) VerifyToken(ctx context.Context, token, clientIP string) error {
+	return s.verify(ctx, token, clientIP, nil, s.checkAction)
+}
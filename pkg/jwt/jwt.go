@@ -5,67 +5,87 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Claims 自定义JWT载荷，包含用户ID和用户名
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
+	UserID     uint   `json:"user_id"`
+	Username   string `json:"username"`
+	Generation int64  `json:"gen"`             // 用户令牌代数，用户注销全部设备后旧代数的令牌失效
+	Scope      string `json:"scope,omitempty"` // 授权范围，以空格分隔
+	SessionID  string `json:"sid,omitempty"`   // 登录会话ID，会话被吊销后令牌失效
 	jwt.RegisteredClaims
 }
 
+// NewClaims 创建自定义载荷
+func NewClaims(userID uint, username string, generation int64, sessionID string) *Claims {
+	return &Claims{
+		UserID:     userID,
+		Username:   username,
+		Generation: generation,
+		SessionID:  sessionID,
+	}
+}
+
 // GenerateToken 生成JWT令牌
 // 参数：
-//   - userID: 用户ID
-//   - username: 用户名
-//   - secret: 签名密钥
+//   - claims: 自定义载荷，签发时间、过期时间和令牌ID（jti）由本函数填充
+//   - keys: 密钥提供者，使用其当前签名密钥签名，并在头部写入 kid
 //   - expiration: 过期时间（如24*time.Hour）
 //
 // 返回：
 //   - 生成的令牌字符串
 //   - 错误信息
-func GenerateToken(userID uint, username string, secret string, expiration time.Duration) (string, error) {
-	// 设置过期时间
-	expiresAt := time.Now().Add(expiration)
+func GenerateToken(claims *Claims, keys KeyProvider, expiration time.Duration) (string, error) {
+	key, err := keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
 
-	// 创建自定义载荷
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),  // 过期时间
-			IssuedAt:  jwt.NewNumericDate(time.Now()), // 签发时间
-			NotBefore: jwt.NewNumericDate(time.Now()), // 生效时间（立即生效）
-			Issuer:    "auth-service",                 // 签发者
-		},
+	now := time.Now()
+
+	// 填充标准载荷
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.New().String(),                     // 令牌ID，用于吊销
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiration)), // 过期时间
+		IssuedAt:  jwt.NewNumericDate(now),                 // 签发时间
+		NotBefore: jwt.NewNumericDate(now),                 // 生效时间（立即生效）
+		Issuer:    "auth-service",                          // 签发者
 	}
 
-	// 创建令牌（使用HS256算法）
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// 创建令牌（算法由签名密钥决定）
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
 
 	// 使用密钥签名令牌
-	return token.SignedString([]byte(secret))
+	return token.SignedString(key.private)
 }
 
 // ParseToken 解析并验证JWT令牌
 // 参数：
 //   - tokenString: 待解析的令牌字符串
-//   - secret: 签名密钥（需与生成时一致）
+//   - keys: 密钥提供者，根据令牌头部的 kid 选择验证密钥
 //
 // 返回：
 //   - 解析后的自定义载荷
 //   - 错误信息
-func ParseToken(tokenString string, secret string) (*Claims, error) {
+func ParseToken(tokenString string, keys KeyProvider) (*Claims, error) {
 	// 解析令牌
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
-			// 验证签名算法
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			kid, _ := token.Header["kid"].(string)
+			key, err := keys.Lookup(kid, token.Method.Alg())
+			if err != nil {
+				return nil, err
+			}
+			// 验证签名算法与密钥一致，防止算法混淆攻击
+			if token.Method.Alg() != key.Algorithm {
 				return nil, errors.New("不支持的签名算法")
 			}
-			return []byte(secret), nil
+			return key.public, nil
 		},
 	)
 
@@ -82,7 +102,7 @@ func ParseToken(tokenString string, secret string) (*Claims, error) {
 }
 
 // ValidateToken 仅验证令牌是否有效（不提取载荷）
-func ValidateToken(tokenString string, secret string) bool {
-	_, err := ParseToken(tokenString, secret)
+func ValidateToken(tokenString string, keys KeyProvider) bool {
+	_, err := ParseToken(tokenString, keys)
 	return err == nil
 }
@@ -0,0 +1,326 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/logger"
+)
+
+// KeyRecord 持久化的签名密钥
+type KeyRecord struct {
+	ID            string
+	Algorithm     string
+	PrivateKeyPEM []byte    // PKCS#8 PEM 编码的私钥
+	NotBefore     time.Time // 开始用于签名的时间
+	NotAfter      time.Time // 停止用于验证的时间，零值表示长期有效
+	CreatedAt     time.Time
+}
+
+// KeyStore 签名密钥持久化存储，所有副本通过它对当前签名密钥达成一致
+type KeyStore interface {
+	ListKeys(ctx context.Context) ([]KeyRecord, error)   // 查询全部密钥
+	SaveKey(ctx context.Context, record KeyRecord) error // 新增或更新密钥
+}
+
+// RotationPolicy 密钥轮换策略
+type RotationPolicy struct {
+	Algorithm        string        // 新生成密钥的算法
+	Interval         time.Duration // 自动轮换周期，0 表示不自动轮换
+	PropagationDelay time.Duration // 新密钥预发布多久后开始签名
+	ReloadInterval   time.Duration // 重新加载周期
+	VerifyGrace      time.Duration // 旧密钥停止签名后继续用于验证的时间，不应小于访问令牌有效期
+}
+
+// KeyRing 密钥环：一个当前签名密钥和若干仅用于验证的密钥，支持持久化和轮换
+//
+// 轮换时新密钥先以未来的生效时间写入存储，各副本在生效前重新加载并通过 JWKS 预先发布；
+// 生效后旧密钥停止签名，但在 VerifyGrace 内继续用于验证，因此轮换不会使已签发的令牌失效。
+type KeyRing struct {
+	mu      sync.RWMutex
+	current *KeySet
+	static  []*Key // 来自配置文件的密钥，未设置截止时间的在首个轮换密钥生效后按宽限期停止验证
+	store   KeyStore
+	policy  RotationPolicy
+}
+
+// NewKeyRing 创建密钥环，store 为 nil 时只使用静态密钥
+func NewKeyRing(store KeyStore, policy RotationPolicy, static ...*Key) *KeyRing {
+	return &KeyRing{
+		current: NewKeySet(nil, static...),
+		static:  static,
+		store:   store,
+		policy:  policy,
+	}
+}
+
+// LoadKeyRing 根据配置创建密钥环并加载持久化的密钥
+// 未启用轮换时等同于 LoadKeySet 得到的静态密钥集
+func LoadKeyRing(ctx context.Context, cfg *config.JWTConfig, store KeyStore) (*KeyRing, error) {
+	var static []*Key
+	if cfg.PrivateKeyFile != "" || cfg.Secret != "" {
+		set, err := LoadKeySet(cfg)
+		if err != nil {
+			return nil, err
+		}
+		static = set.Keys()
+	}
+
+	if !cfg.Rotation.Enabled {
+		return NewKeyRing(nil, RotationPolicy{}, static...), nil
+	}
+	if store == nil {
+		return nil, errors.New("启用密钥轮换时必须提供密钥存储")
+	}
+	if err := validateRotation(&cfg.Rotation); err != nil {
+		return nil, err
+	}
+
+	ring := NewKeyRing(store, RotationPolicy{
+		Algorithm:        cfg.Rotation.Algorithm,
+		Interval:         cfg.Rotation.Interval,
+		PropagationDelay: cfg.Rotation.PropagationDelay,
+		ReloadInterval:   cfg.Rotation.ReloadInterval,
+		VerifyGrace:      cfg.AccessTokenTTL,
+	}, static...)
+
+	// 静态签名密钥（私钥文件或 Secret）在轮换密钥生效前继续签名，之后只在宽限期内用于验证；
+	// 未配置私钥文件时 Secret 的验证截止时间可由 legacy_secret_until 指定
+	if cfg.PrivateKeyFile == "" && len(static) == 1 {
+		until, err := legacySecretUntil(cfg)
+		if err != nil {
+			return nil, err
+		}
+		static[0].NotAfter = until
+	}
+
+	if err := ring.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	// 存储中还没有密钥时生成第一个
+	if len(ring.Keys()) == len(static) {
+		if _, err := ring.Rotate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+// SigningKey 返回当前签名密钥
+func (r *KeyRing) SigningKey() (*Key, error) {
+	return r.snapshot().SigningKey()
+}
+
+// Lookup 根据密钥ID查找验证密钥
+func (r *KeyRing) Lookup(kid, algorithm string) (*Key, error) {
+	return r.snapshot().Lookup(kid, algorithm)
+}
+
+// JWKS 导出公开的验证公钥（包括已预发布但尚未生效的密钥）
+func (r *KeyRing) JWKS() JWKSet {
+	return r.snapshot().JWKS()
+}
+
+// Keys 返回密钥环中的所有密钥
+func (r *KeyRing) Keys() []*Key {
+	return r.snapshot().Keys()
+}
+
+// Reload 从存储重新加载密钥
+func (r *KeyRing) Reload(ctx context.Context) error {
+	if r.store == nil {
+		return nil
+	}
+
+	records, err := r.store.ListKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("加载签名密钥失败: %w", err)
+	}
+
+	keys := make([]*Key, 0, len(r.static)+len(records))
+	for _, key := range r.static {
+		if key.NotAfter.IsZero() {
+			key = staticWithDeadline(key, records, r.policy.VerifyGrace)
+		}
+		keys = append(keys, key)
+	}
+	for _, record := range records {
+		key, err := keyFromRecord(record)
+		if err != nil {
+			return fmt.Errorf("解析签名密钥 %s 失败: %w", record.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	r.mu.Lock()
+	r.current = NewKeySet(nil, keys...)
+	r.mu.Unlock()
+	return nil
+}
+
+// Rotate 生成新的签名密钥并写入存储
+// 新密钥在 PropagationDelay 后开始签名；当前的签名密钥在新密钥生效 VerifyGrace 后停止验证。
+// 多个副本同时轮换时会生成多个密钥，生效时间最晚的一个最终成为签名密钥，不影响正确性。
+func (r *KeyRing) Rotate(ctx context.Context) (*Key, error) {
+	if r.store == nil {
+		return nil, errors.New("未启用密钥轮换")
+	}
+
+	if err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	key, err := GenerateSigningKey(r.policy.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	key.NotBefore = now
+	// 已有签名密钥时需要预发布，保证所有副本在新密钥生效前都能验证它
+	if _, err := r.SigningKey(); err == nil {
+		key.NotBefore = now.Add(r.policy.PropagationDelay)
+	}
+
+	// 为存储中尚未设置过期时间的旧密钥设置验证截止时间
+	records, err := r.store.ListKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("加载签名密钥失败: %w", err)
+	}
+	for _, record := range records {
+		if !record.NotAfter.IsZero() {
+			continue
+		}
+		record.NotAfter = key.NotBefore.Add(r.policy.VerifyGrace)
+		if err := r.store.SaveKey(ctx, record); err != nil {
+			return nil, fmt.Errorf("更新签名密钥 %s 失败: %w", record.ID, err)
+		}
+	}
+
+	privatePEM, err := key.MarshalPrivateKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.store.SaveKey(ctx, KeyRecord{
+		ID:            key.ID,
+		Algorithm:     key.Algorithm,
+		PrivateKeyPEM: privatePEM,
+		NotBefore:     key.NotBefore,
+		CreatedAt:     now,
+	}); err != nil {
+		return nil, fmt.Errorf("保存签名密钥失败: %w", err)
+	}
+
+	if err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Run 定期重新加载密钥，并在到期时自动轮换，直到 ctx 被取消
+func (r *KeyRing) Run(ctx context.Context, log *logger.ZapLogger) {
+	if r.store == nil || r.policy.ReloadInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.policy.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.Reload(ctx); err != nil {
+			log.Error("重新加载签名密钥失败", logger.Error(err))
+			continue
+		}
+
+		if !r.rotationDue() {
+			continue
+		}
+		key, err := r.Rotate(ctx)
+		if err != nil {
+			log.Error("自动轮换签名密钥失败", logger.Error(err))
+			continue
+		}
+		log.Info("签名密钥已自动轮换",
+			logger.String("kid", key.ID),
+			logger.String("not_before", key.NotBefore.Format(time.RFC3339)),
+		)
+	}
+}
+
+// rotationDue 最新密钥的生效时间已超过轮换周期时需要轮换
+func (r *KeyRing) rotationDue() bool {
+	if r.policy.Interval <= 0 {
+		return false
+	}
+
+	var latest time.Time
+	for _, key := range r.Keys() {
+		if key.CanSign() && key.NotBefore.After(latest) {
+			latest = key.NotBefore
+		}
+	}
+	return time.Since(latest) >= r.policy.Interval
+}
+
+// snapshot 返回当前密钥集
+func (r *KeyRing) snapshot() *KeySet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// staticWithDeadline 返回设置了验证截止时间的静态密钥副本：最早的轮换密钥生效后再过 grace 停止验证
+// 此时由它签发的访问令牌都已过期；存储中还没有密钥时原样返回
+func staticWithDeadline(static *Key, records []KeyRecord, grace time.Duration) *Key {
+	var earliest time.Time
+	for _, record := range records {
+		if earliest.IsZero() || record.NotBefore.Before(earliest) {
+			earliest = record.NotBefore
+		}
+	}
+	if earliest.IsZero() {
+		return static
+	}
+
+	key := *static
+	key.NotAfter = earliest.Add(grace)
+	return &key
+}
+
+// validateRotation 检查轮换周期配置
+// 新密钥必须在所有副本至少重新加载一次后才开始签名，否则其他副本会拒绝它签发的令牌
+func validateRotation(cfg *config.KeyRotationConfig) error {
+	if cfg.ReloadInterval <= 0 {
+		return errors.New("启用密钥轮换时 reload_interval 必须大于 0")
+	}
+	if cfg.PropagationDelay < cfg.ReloadInterval {
+		return fmt.Errorf("propagation_delay (%s) 不能小于 reload_interval (%s)", cfg.PropagationDelay, cfg.ReloadInterval)
+	}
+	return nil
+}
+
+// keyFromRecord 由持久化记录恢复密钥
+func keyFromRecord(record KeyRecord) (*Key, error) {
+	private, err := ParsePrivateKeyPEM(record.PrivateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewSigningKey(record.ID, record.Algorithm, private)
+	if err != nil {
+		return nil, err
+	}
+	key.NotBefore = record.NotBefore
+	key.NotAfter = record.NotAfter
+	return key, nil
+}
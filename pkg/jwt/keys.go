@@ -0,0 +1,347 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"auth-service/internal/config"
+)
+
+// Key 签名/验证密钥
+// 对称密钥（HS*）只在本服务内部使用，不会通过 JWKS 公开
+type Key struct {
+	ID        string            // 密钥ID，写入令牌头部的 kid
+	Algorithm string            // 签名算法，如 RS256、ES256、EdDSA
+	NotBefore time.Time         // 开始用于签名的时间，零值表示立即可用
+	NotAfter  time.Time         // 停止用于验证的时间，零值表示长期有效
+	method    jwt.SigningMethod // 对应的签名方法
+	private   interface{}       // 签名密钥，仅验证的密钥为 nil
+	public    interface{}       // 验证密钥
+}
+
+// defaultHMACKeyID 未配置密钥ID时 HMAC 密钥使用的 kid
+// kid 会写入每个令牌的头部，不能由密钥本身派生，否则相当于公开了密钥的无盐哈希；
+// 各副本使用同一个固定值，配置中同时只会有一个 HMAC 密钥
+const defaultHMACKeyID = "hmac"
+
+// NewHMACKey 创建 HMAC 对称密钥，id 为空时使用 defaultHMACKeyID
+func NewHMACKey(id, algorithm string, secret []byte) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("HMAC 密钥不能为空")
+	}
+	if algorithm == "" {
+		algorithm = jwt.SigningMethodHS256.Alg()
+	}
+	method, ok := jwt.GetSigningMethod(algorithm).(*jwt.SigningMethodHMAC)
+	if !ok {
+		return nil, fmt.Errorf("算法 %s 不是 HMAC 算法", algorithm)
+	}
+	if id == "" {
+		id = defaultHMACKeyID
+	}
+	return &Key{
+		ID:        id,
+		Algorithm: algorithm,
+		method:    method,
+		private:   secret,
+		public:    secret,
+	}, nil
+}
+
+// NewSigningKey 由私钥创建签名密钥，algorithm 为空时根据密钥类型推断
+func NewSigningKey(id, algorithm string, private crypto.PrivateKey) (*Key, error) {
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("私钥不支持签名")
+	}
+	key, err := NewVerifyKey(id, algorithm, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.private = private
+	return key, nil
+}
+
+// NewVerifyKey 由公钥创建仅用于验证的密钥，algorithm 为空时根据密钥类型推断
+func NewVerifyKey(id, algorithm string, public crypto.PublicKey) (*Key, error) {
+	if algorithm == "" {
+		inferred, err := inferAlgorithm(public)
+		if err != nil {
+			return nil, err
+		}
+		algorithm = inferred
+	}
+	if err := checkAlgorithm(algorithm, public); err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("编码公钥失败: %w", err)
+		}
+		id = deriveKeyID(der)
+	}
+
+	return &Key{
+		ID:        id,
+		Algorithm: algorithm,
+		method:    jwt.GetSigningMethod(algorithm),
+		public:    public,
+	}, nil
+}
+
+// CanSign 是否可用于签名
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+// canSignAt 指定时间是否可用于签名
+func (k *Key) canSignAt(now time.Time) bool {
+	return k.CanSign() && !now.Before(k.NotBefore) && k.canVerifyAt(now)
+}
+
+// canVerifyAt 指定时间是否可用于验证
+// 尚未生效的密钥同样可以验证，便于在新密钥启用前预先发布
+func (k *Key) canVerifyAt(now time.Time) bool {
+	return k.NotAfter.IsZero() || now.Before(k.NotAfter)
+}
+
+// MarshalPrivateKeyPEM 将私钥编码为 PKCS#8 PEM
+func (k *Key) MarshalPrivateKeyPEM() ([]byte, error) {
+	if !k.CanSign() || k.IsSymmetric() {
+		return nil, errors.New("只能导出非对称签名私钥")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, fmt.Errorf("编码私钥失败: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// GenerateSigningKey 按算法生成新的非对称签名密钥
+func GenerateSigningKey(algorithm string) (*Key, error) {
+	var (
+		private crypto.PrivateKey
+		err     error
+	)
+
+	switch method := jwt.GetSigningMethod(algorithm).(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case *jwt.SigningMethodECDSA:
+		var curve elliptic.Curve
+		switch method.CurveBits {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			curve = elliptic.P521()
+		}
+		private, err = ecdsa.GenerateKey(curve, rand.Reader)
+	case *jwt.SigningMethodEd25519:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("不支持自动生成 %s 密钥", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %w", err)
+	}
+
+	return NewSigningKey("", algorithm, private)
+}
+
+// IsSymmetric 是否为对称密钥
+func (k *Key) IsSymmetric() bool {
+	_, ok := k.method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// PublicKey 返回验证用公钥，对称密钥返回 nil
+func (k *Key) PublicKey() crypto.PublicKey {
+	if k.IsSymmetric() {
+		return nil
+	}
+	return k.public
+}
+
+// LoadSigningKeyFile 从 PEM 文件加载私钥
+func LoadSigningKeyFile(id, algorithm, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥文件失败: %w", err)
+	}
+	private, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(id, algorithm, private)
+}
+
+// LoadVerifyKeyFile 从 PEM 文件加载公钥
+func LoadVerifyKeyFile(id, algorithm, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取公钥文件失败: %w", err)
+	}
+	public, err := ParsePublicKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewVerifyKey(id, algorithm, public)
+}
+
+// ParsePrivateKeyPEM 解析 PEM 格式私钥，支持 PKCS#8、PKCS#1（RSA）和 SEC 1（EC）
+func ParsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("无效的 PEM 数据")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("不支持的私钥格式: %s", block.Type)
+}
+
+// ParsePublicKeyPEM 解析 PEM 格式公钥，支持 PKIX、PKCS#1（RSA）和证书
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("无效的 PEM 数据")
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("不支持的公钥格式: %s", block.Type)
+}
+
+// LoadKeySet 根据配置加载密钥集
+// 配置了私钥文件时使用非对称签名；同时配置了 Secret 时保留 HMAC 密钥仅用于验证，到 LegacySecretUntil 为止，便于平滑迁移
+func LoadKeySet(cfg *config.JWTConfig) (*KeySet, error) {
+	if cfg.PrivateKeyFile == "" {
+		key, err := NewHMACKey(cfg.KeyID, cfg.Algorithm, []byte(cfg.Secret))
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySet(key), nil
+	}
+
+	signing, err := LoadSigningKeyFile(cfg.KeyID, cfg.Algorithm, cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var verifyOnly []*Key
+	if cfg.Secret != "" {
+		until, err := legacySecretUntil(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if until.IsZero() {
+			return nil, errors.New("同时配置私钥文件和 Secret 时必须设置 legacy_secret_until，否则旧 HMAC 令牌会一直有效")
+		}
+
+		legacy, err := NewHMACKey("", "", []byte(cfg.Secret))
+		if err != nil {
+			return nil, err
+		}
+		legacy.private = nil
+		legacy.NotAfter = until
+		verifyOnly = append(verifyOnly, legacy)
+	}
+
+	return NewKeySet(signing, verifyOnly...), nil
+}
+
+// legacySecretUntil 解析旧 HMAC Secret 的验证截止时间，未配置时返回零值
+func legacySecretUntil(cfg *config.JWTConfig) (time.Time, error) {
+	if cfg.LegacySecretUntil == "" {
+		return time.Time{}, nil
+	}
+	until, err := time.Parse(time.RFC3339, cfg.LegacySecretUntil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("legacy_secret_until 格式无效: %w", err)
+	}
+	return until, nil
+}
+
+// inferAlgorithm 根据公钥类型推断签名算法
+func inferAlgorithm(public crypto.PublicKey) (string, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256.Alg(), nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256.Alg(), nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384.Alg(), nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512.Alg(), nil
+		}
+		return "", fmt.Errorf("不支持的椭圆曲线: %s", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA.Alg(), nil
+	}
+	return "", fmt.Errorf("不支持的公钥类型: %T", public)
+}
+
+// checkAlgorithm 检查签名算法与密钥类型是否匹配
+func checkAlgorithm(algorithm string, public crypto.PublicKey) error {
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil {
+		return fmt.Errorf("不支持的签名算法: %s", algorithm)
+	}
+
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := public.(*rsa.PublicKey); ok {
+			return nil
+		}
+	case *jwt.SigningMethodECDSA:
+		// ES256/ES384/ES512 必须使用对应的曲线
+		if inferred, err := inferAlgorithm(public); err == nil && inferred == algorithm {
+			return nil
+		}
+	case *jwt.SigningMethodEd25519:
+		if _, ok := public.(ed25519.PublicKey); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("签名算法 %s 与密钥类型 %T 不匹配", algorithm, public)
+}
+
+// deriveKeyID 由公钥派生密钥ID，不能用于对称密钥
+func deriveKeyID(material []byte) string {
+	sum := sha256.Sum256(material)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
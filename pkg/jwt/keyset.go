@@ -0,0 +1,92 @@
+package jwt
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// KeyProvider 密钥提供者：签发与验证令牌时使用
+type KeyProvider interface {
+	SigningKey() (*Key, error)                  // 当前签名密钥
+	Lookup(kid, algorithm string) (*Key, error) // 根据密钥ID查找验证密钥
+	JWKS() JWKSet                               // 公开的验证公钥
+}
+
+// KeySet 静态密钥集：一个签名密钥和若干仅用于验证的密钥
+type KeySet struct {
+	keys map[string]*Key
+}
+
+// NewKeySet 创建密钥集
+func NewKeySet(signing *Key, verifyOnly ...*Key) *KeySet {
+	s := &KeySet{
+		keys: make(map[string]*Key, len(verifyOnly)+1),
+	}
+	if signing != nil {
+		s.keys[signing.ID] = signing
+	}
+	for _, key := range verifyOnly {
+		s.keys[key.ID] = key
+	}
+	return s
+}
+
+// SigningKey 返回当前签名密钥
+// 存在多个可签名密钥时，选择已生效且生效时间最晚的一个
+func (s *KeySet) SigningKey() (*Key, error) {
+	now := time.Now()
+
+	var signing *Key
+	for _, key := range s.Keys() {
+		if !key.canSignAt(now) {
+			continue
+		}
+		if signing == nil || key.NotBefore.After(signing.NotBefore) {
+			signing = key
+		}
+	}
+	if signing == nil {
+		return nil, errors.New("未配置签名密钥")
+	}
+	return signing, nil
+}
+
+// Lookup 根据密钥ID查找验证密钥
+// 令牌未携带 kid 时（旧版本签发），按算法匹配唯一的密钥
+func (s *KeySet) Lookup(kid, algorithm string) (*Key, error) {
+	now := time.Now()
+
+	if kid != "" {
+		key, ok := s.keys[kid]
+		if !ok || !key.canVerifyAt(now) {
+			return nil, errors.New("未知的密钥ID")
+		}
+		return key, nil
+	}
+
+	var found *Key
+	for _, key := range s.keys {
+		if key.Algorithm != algorithm || !key.canVerifyAt(now) {
+			continue
+		}
+		if found != nil {
+			return nil, errors.New("令牌缺少密钥ID")
+		}
+		found = key
+	}
+	if found == nil {
+		return nil, errors.New("令牌缺少密钥ID")
+	}
+	return found, nil
+}
+
+// Keys 返回密钥集中的所有密钥（按密钥ID排序）
+func (s *KeySet) Keys() []*Key {
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
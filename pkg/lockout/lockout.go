@@ -0,0 +1,194 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/redis"
+)
+
+// recordScript 原子地记录一次登录失败，失败次数达到阈值时锁定，锁定时长随累计锁定次数指数增长
+// KEYS[1] 失败次数，KEYS[2] 临时锁定，KEYS[3] 累计锁定次数，KEYS[4] 永久锁定
+// ARGV[1] 阈值，ARGV[2] 失败统计窗口（毫秒），ARGV[3] 首次锁定时长（毫秒），ARGV[4] 最长锁定时长（毫秒），
+// ARGV[5] 累计锁定多少次后永久锁定（0 表示不永久锁定），ARGV[6] 累计锁定次数的保留时长（毫秒）
+// 返回 {0, 剩余尝试次数} 未锁定，{1, 锁定时长（毫秒）} 临时锁定，{2, 0} 永久锁定
+const recordScript = `
+local fails = redis.call('INCR', KEYS[1])
+if fails == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if fails < tonumber(ARGV[1]) then
+	return {0, tonumber(ARGV[1]) - fails}
+end
+redis.call('DEL', KEYS[1])
+local locks = redis.call('INCR', KEYS[3])
+redis.call('PEXPIRE', KEYS[3], ARGV[6])
+local permanent_after = tonumber(ARGV[5])
+if permanent_after > 0 and locks >= permanent_after then
+	redis.call('SET', KEYS[4], 1)
+	return {2, 0}
+end
+local duration = math.min(tonumber(ARGV[3]) * 2 ^ (locks - 1), tonumber(ARGV[4]))
+redis.call('SET', KEYS[2], 1, 'PX', duration)
+return {1, duration}
+`
+
+// Status 锁定状态
+type Status struct {
+	Locked            bool
+	Permanent         bool          // 永久锁定，需管理员解锁或重置密码
+	RetryAfter        time.Duration // 临时锁定的剩余时长
+	RemainingAttempts int           // 记录失败后未锁定时，距离锁定还剩的尝试次数
+}
+
+// Manager 登录失败锁定管理器，按账号和 IP 分别统计，状态保存在 Redis 中，各实例共享
+//
+// 同一账号（或 IP）在统计窗口内连续失败达到阈值后临时锁定，每次锁定的时长是上一次的两倍；
+// 账号累计锁定达到指定次数后永久锁定。IP 只会临时锁定。
+type Manager struct {
+	cfg         config.LockoutConfig
+	redisClient *redis.Client
+}
+
+// NewManager 创建锁定管理器
+func NewManager(cfg *config.LockoutConfig, redisClient *redis.Client) (*Manager, error) {
+	if cfg.Enabled {
+		if cfg.AccountThreshold < 1 || cfg.IPThreshold < 1 {
+			return nil, errors.New("登录失败锁定阈值必须大于 0")
+		}
+		if cfg.FailureWindow <= 0 || cfg.BaseDuration <= 0 || cfg.MaxDuration < cfg.BaseDuration {
+			return nil, errors.New("登录失败锁定时长配置无效")
+		}
+		// 累计锁定次数须保留到最长锁定结束之后，否则锁定时长无法逐次增长
+		if cfg.History < cfg.MaxDuration {
+			return nil, errors.New("累计锁定次数的保留时长不能短于最长锁定时长")
+		}
+	}
+	return &Manager{cfg: *cfg, redisClient: redisClient}, nil
+}
+
+// CheckAccount 查询账号是否被锁定
+func (m *Manager) CheckAccount(ctx context.Context, userID uint) (*Status, error) {
+	if !m.cfg.Enabled {
+		return &Status{}, nil
+	}
+	permanent, err := m.redisClient.Exists(ctx, permanentKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("查询账号锁定状态失败: %w", err)
+	}
+	if permanent {
+		return &Status{Locked: true, Permanent: true}, nil
+	}
+	return m.check(ctx, lockKey(accountScope(userID)))
+}
+
+// CheckIP 查询 IP 是否被锁定
+func (m *Manager) CheckIP(ctx context.Context, ip string) (*Status, error) {
+	if !m.cfg.Enabled {
+		return &Status{}, nil
+	}
+	return m.check(ctx, lockKey(ipScope(ip)))
+}
+
+// RecordAccountFailure 记录账号的一次登录失败，返回记录后的状态；Locked 为 true 表示本次失败触发了锁定
+func (m *Manager) RecordAccountFailure(ctx context.Context, userID uint) (*Status, error) {
+	if !m.cfg.Enabled {
+		return &Status{}, nil
+	}
+	return m.record(ctx, accountScope(userID), permanentKey(userID), m.cfg.AccountThreshold, m.cfg.PermanentAfter)
+}
+
+// RecordIPFailure 记录 IP 的一次登录失败（包括用户名不存在的情况）
+func (m *Manager) RecordIPFailure(ctx context.Context, ip string) (*Status, error) {
+	if !m.cfg.Enabled {
+		return &Status{}, nil
+	}
+	// IP 不会永久锁定，永久锁定键仅占位
+	return m.record(ctx, ipScope(ip), "lockout:permanent:ip", m.cfg.IPThreshold, 0)
+}
+
+// ResetFailures 登录成功后清除账号的失败次数（累计锁定次数保留，使针对该账号的持续攻击仍会逐步升级）
+func (m *Manager) ResetFailures(ctx context.Context, userID uint) error {
+	if !m.cfg.Enabled {
+		return nil
+	}
+	if err := m.redisClient.Del(ctx, failuresKey(accountScope(userID))); err != nil {
+		return fmt.Errorf("清除登录失败次数失败: %w", err)
+	}
+	return nil
+}
+
+// Unlock 解除账号的临时和永久锁定，并清除失败次数和累计锁定次数（管理员解锁或用户重置密码后调用）
+func (m *Manager) Unlock(ctx context.Context, userID uint) error {
+	scope := accountScope(userID)
+	if err := m.redisClient.Del(ctx, failuresKey(scope), lockKey(scope), countKey(scope), permanentKey(userID)); err != nil {
+		return fmt.Errorf("解除账号锁定失败: %w", err)
+	}
+	return nil
+}
+
+// check 查询临时锁定的剩余时长
+func (m *Manager) check(ctx context.Context, key string) (*Status, error) {
+	ttl, err := m.redisClient.TTL(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("查询锁定状态失败: %w", err)
+	}
+	if ttl <= 0 {
+		return &Status{}, nil
+	}
+	return &Status{Locked: true, RetryAfter: ttl}, nil
+}
+
+// record 执行记录失败的脚本
+func (m *Manager) record(ctx context.Context, scope, permanent string, threshold, permanentAfter int) (*Status, error) {
+	reply, err := m.redisClient.Eval(ctx, recordScript,
+		[]string{failuresKey(scope), lockKey(scope), countKey(scope), permanent},
+		threshold, m.cfg.FailureWindow.Milliseconds(), m.cfg.BaseDuration.Milliseconds(),
+		m.cfg.MaxDuration.Milliseconds(), permanentAfter, m.cfg.History.Milliseconds(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("记录登录失败次数失败: %w", err)
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, errors.New("记录登录失败次数的脚本返回值格式错误")
+	}
+	state, _ := values[0].(int64)
+	value, _ := values[1].(int64)
+	switch state {
+	case 0:
+		return &Status{RemainingAttempts: int(value)}, nil
+	case 1:
+		return &Status{Locked: true, RetryAfter: time.Duration(value) * time.Millisecond}, nil
+	default:
+		return &Status{Locked: true, Permanent: true}, nil
+	}
+}
+
+func accountScope(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+func ipScope(ip string) string {
+	return "ip:" + ip
+}
+
+func failuresKey(scope string) string {
+	return "lockout:failures:" + scope
+}
+
+func lockKey(scope string) string {
+	return "lockout:locked:" + scope
+}
+
+func countKey(scope string) string {
+	return "lockout:count:" + scope
+}
+
+func permanentKey(userID uint) string {
+	return fmt.Sprintf("lockout:permanent:user:%d", userID)
+}
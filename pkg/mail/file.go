@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSender 将邮件写入目录（每封邮件一个 .eml 文件），用于本地开发
+type FileSender struct {
+	from string
+	dir  string
+}
+
+// NewFileSender 创建文件邮件发送器
+func NewFileSender(from, dir string) (*FileSender, error) {
+	if dir == "" {
+		return nil, errors.New("未配置邮件保存目录")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建邮件保存目录失败: %w", err)
+	}
+	return &FileSender{from: from, dir: dir}, nil
+}
+
+// Send 将邮件写入文件
+func (s *FileSender) Send(_ context.Context, msg *Message) error {
+	data, err := buildMessage(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), recipient)
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("写入邮件文件失败: %w", err)
+	}
+	return nil
+}
+
+// MemorySender 将邮件保存在内存中，用于测试
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemorySender 创建内存邮件发送器
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+// Send 保存邮件
+func (s *MemorySender) Send(_ context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, *msg)
+	return nil
+}
+
+// Messages 返回已发送的全部邮件
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Last 返回发送给指定收件人的最后一封邮件
+func (s *MemorySender) Last(to string) (*Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].To == to {
+			msg := s.messages[i]
+			return &msg, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,157 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"auth-service/internal/config"
+)
+
+// SMTP 连接加密方式
+const (
+	SMTPSecurityStartTLS = "starttls" // 明文连接后升级为 TLS（通常为 587 端口）
+	SMTPSecurityTLS      = "tls"      // 直接建立 TLS 连接（通常为 465 端口）
+	SMTPSecurityNone     = "none"     // 不加密，仅用于本地调试
+)
+
+// SMTPSender 通过 SMTP 服务器发送邮件
+type SMTPSender struct {
+	from        string
+	host        string
+	addr        string
+	username    string
+	password    string
+	security    string
+	dialTimeout time.Duration
+	timeout     time.Duration
+}
+
+// NewSMTPSender 创建 SMTP 邮件发送器
+func NewSMTPSender(cfg *config.MailConfig) (*SMTPSender, error) {
+	if cfg.SMTP.Host == "" {
+		return nil, errors.New("未配置 SMTP 服务器地址")
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("发件人地址无效: %w", err)
+	}
+
+	security := cfg.SMTP.Security
+	if security == "" {
+		security = SMTPSecurityStartTLS
+	}
+	switch security {
+	case SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		return nil, fmt.Errorf("不支持的 SMTP 加密方式: %s", security)
+	}
+
+	dialTimeout := cfg.SMTP.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = 10 * time.Second
+	}
+	timeout := cfg.SMTP.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &SMTPSender{
+		from:        cfg.From,
+		host:        cfg.SMTP.Host,
+		addr:        net.JoinHostPort(cfg.SMTP.Host, cfg.SMTP.Port),
+		username:    cfg.SMTP.Username,
+		password:    cfg.SMTP.Password,
+		security:    security,
+		dialTimeout: dialTimeout,
+		timeout:     timeout,
+	}, nil
+}
+
+// Send 发送邮件
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	data, err := buildMessage(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+
+	sender, _ := mail.ParseAddress(s.from)
+	if err := client.Mail(sender.Address); err != nil {
+		return fmt.Errorf("SMTP 设置发件人失败: %w", err)
+	}
+	recipient, _ := mail.ParseAddress(msg.To)
+	if err := client.Rcpt(recipient.Address); err != nil {
+		return fmt.Errorf("SMTP 设置收件人失败: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP 发送数据失败: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("SMTP 发送数据失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP 发送数据失败: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// dial 连接 SMTP 服务器并按配置建立加密连接
+// 连接的读写截止时间取总超时和 ctx 截止时间中较早的一个，之后的整个 SMTP 会话都受其限制
+func (s *SMTPSender) dial(ctx context.Context) (*smtp.Client, error) {
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	dialer := net.Dialer{Timeout: s.dialTimeout, Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+
+	tlsConfig := &tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}
+	if s.security == SMTPSecurityTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+
+	if s.security == SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("SMTP 服务器不支持 STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("SMTP 建立 TLS 连接失败: %w", err)
+		}
+	}
+	return client, nil
+}
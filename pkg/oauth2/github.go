@@ -14,11 +14,12 @@ import (
 
 // GitHubUser GitHub 用户信息
 type GitHubUser struct {
-	ID        int64  `json:"id"`
-	Login     string `json:"login"`
-	Email     string `json:"email"`
-	Name      string `json:"name"`
-	AvatarURL string `json:"avatar_url"`
+	ID            int64  `json:"id"`
+	Login         string `json:"login"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"-"` // 邮箱是否已在 GitHub 验证，来自 /user/emails
+	Name          string `json:"name"`
+	AvatarURL     string `json:"avatar_url"`
 }
 
 // GitHubOAuth2Service GitHub OAuth2 服务
@@ -41,14 +42,17 @@ func NewGitHubOAuth2Service(cfg *config.GitHubOAuth2Config) *GitHubOAuth2Service
 	}
 }
 
-// GetAuthURL 获取授权URL
-func (s *GitHubOAuth2Service) GetAuthURL(state string) string {
-	return s.config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+// GetAuthURL 获取授权URL，携带 PKCE 验证码的 S256 摘要
+func (s *GitHubOAuth2Service) GetAuthURL(state, codeVerifier string) string {
+	return s.config.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier))
 }
 
-// ExchangeCode 交换授权码获取用户信息
-func (s *GitHubOAuth2Service) ExchangeCode(ctx context.Context, code string) (*GitHubUser, error) {
-	token, err := s.config.Exchange(ctx, code)
+// ExchangeCode 交换授权码获取用户信息，codeVerifier 为发起授权时生成的 PKCE 验证码
+func (s *GitHubOAuth2Service) ExchangeCode(ctx context.Context, code, codeVerifier string) (*GitHubUser, error) {
+	if codeVerifier == "" {
+		return nil, errMissingVerifier
+	}
+	token, err := s.config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
 	if err != nil {
 		return nil, fmt.Errorf("交换令牌失败: %w", err)
 	}
@@ -70,17 +74,15 @@ func (s *GitHubOAuth2Service) ExchangeCode(ctx context.Context, code string) (*G
 		return nil, fmt.Errorf("解析用户信息失败: %w", err)
 	}
 
-	// 如果用户信息中没有邮箱，单独获取
-	if user.Email == "" {
-		if err := s.fetchUserEmail(ctx, client, &user); err != nil {
-			return nil, err
-		}
+	// 获取邮箱验证状态；如果用户信息中没有公开邮箱，使用主邮箱
+	if err := s.fetchUserEmail(ctx, client, &user); err != nil {
+		return nil, err
 	}
 
 	return &user, nil
 }
 
-// fetchUserEmail 获取用户邮箱
+// fetchUserEmail 获取用户邮箱及其验证状态
 func (s *GitHubOAuth2Service) fetchUserEmail(ctx context.Context, client *http.Client, user *GitHubUser) error {
 	resp, err := client.Get("https://api.github.com/user/emails")
 	if err != nil {
@@ -88,19 +90,32 @@ func (s *GitHubOAuth2Service) fetchUserEmail(ctx context.Context, client *http.C
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API 返回错误状态码: %d", resp.StatusCode)
+	}
+
 	var emails []struct {
-		Email   string `json:"email"`
-		Primary bool   `json:"primary"`
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&emails); err != nil {
 		return fmt.Errorf("解析邮箱信息失败: %w", err)
 	}
 
-	// 找到主邮箱
+	// 没有公开邮箱时使用主邮箱
+	if user.Email == "" {
+		for _, email := range emails {
+			if email.Primary {
+				user.Email = email.Email
+				break
+			}
+		}
+	}
 	for _, email := range emails {
-		if email.Primary {
-			user.Email = email.Email
+		if email.Email == user.Email {
+			user.EmailVerified = email.Verified
 			break
 		}
 	}
@@ -0,0 +1,391 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/jwt"
+)
+
+const (
+	discoveryTTL    = 24 * time.Hour   // 发现文档缓存时间
+	jwksTTL         = time.Hour        // JWKS 缓存时间
+	jwksMinRefresh  = time.Minute      // 遇到未知 kid 时重新获取 JWKS 的最小间隔，防止被用于放大请求
+	idTokenLeeway   = time.Minute      // 校验 exp、iat 时允许的时钟偏差
+	maxResponseSize = 1 << 20          // 发现文档、JWKS、userinfo 响应大小上限
+	httpTimeout     = 10 * time.Second // 请求提供方接口的超时时间
+)
+
+// providerNamePattern provider 名称出现在路由中，只允许小写字母、数字和连字符
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,19}$`)
+
+// idTokenAlgorithms 接受的 ID Token 签名算法；不接受 none 和 HS*（对称密钥即 client_secret，不适合验证身份）
+var idTokenAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// OIDCUser 从 ID Token（及 userinfo）中得到的用户信息
+type OIDCUser struct {
+	Provider      string                 // provider 名称
+	Subject       string                 // 用户在提供方的唯一标识（sub）
+	Username      string                 // 建议的用户名
+	Email         string                 // 邮箱
+	EmailVerified bool                   // 提供方是否已验证该邮箱
+	Name          string                 // 显示名称
+	Picture       string                 // 头像URL
+	Claims        map[string]interface{} // 全部声明
+}
+
+// OIDCMetadata OpenID Connect 发现文档中用到的字段
+type OIDCMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider 通用 OpenID Connect 登录：按签发者地址自动发现接口，使用提供方的 JWKS 验证 ID Token
+// 发现文档和 JWKS 在首次使用时获取并缓存，提供方不可用不影响服务启动
+type OIDCProvider struct {
+	name       string
+	cfg        config.OIDCProviderConfig
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	metadata      *OIDCMetadata
+	discoveredAt  time.Time
+	keys          *jwt.KeySet
+	keysFetchedAt time.Time
+}
+
+// NewOIDCProviders 根据配置创建全部 OpenID Connect 提供方
+func NewOIDCProviders(cfgs map[string]config.OIDCProviderConfig) (map[string]*OIDCProvider, error) {
+	providers := make(map[string]*OIDCProvider, len(cfgs))
+	for name, cfg := range cfgs {
+		provider, err := NewOIDCProvider(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// NewOIDCProvider 创建 OpenID Connect 提供方
+func NewOIDCProvider(name string, cfg config.OIDCProviderConfig) (*OIDCProvider, error) {
+	if !providerNamePattern.MatchString(name) || name == "github" {
+		return nil, fmt.Errorf("OIDC 提供方名称无效: %q", name)
+	}
+	issuer, err := url.Parse(cfg.Issuer)
+	if err != nil || issuer.Host == "" || (issuer.Scheme != "https" && issuer.Hostname() != "localhost") {
+		return nil, fmt.Errorf("OIDC 提供方 %s 的 issuer 必须是 https 地址: %q", name, cfg.Issuer)
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC 提供方 %s 缺少 client_id 或 redirect_url", name)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.DisplayName == "" {
+		cfg.DisplayName = name
+	}
+
+	return &OIDCProvider{
+		name:       name,
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: httpTimeout},
+	}, nil
+}
+
+// Name provider 名称
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// DisplayName 显示名称
+func (p *OIDCProvider) DisplayName() string {
+	return p.cfg.DisplayName
+}
+
+// GetAuthURL 获取授权URL，携带 PKCE 验证码的 S256 摘要；nonce 会写入 ID Token，回调时校验
+func (p *OIDCProvider) GetAuthURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	conf, _, err := p.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.S256ChallengeOption(codeVerifier),
+	), nil
+}
+
+// ExchangeCode 交换授权码，验证 ID Token 并返回用户信息
+// nonce、codeVerifier 为发起授权时生成并保存在 OAuth2 会话中的值
+func (p *OIDCProvider) ExchangeCode(ctx context.Context, code, nonce, codeVerifier string) (*OIDCUser, error) {
+	if codeVerifier == "" {
+		return nil, errMissingVerifier
+	}
+	conf, metadata, err := p.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("交换令牌失败: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("令牌响应中缺少 id_token")
+	}
+
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.cfg.UserInfo && metadata.UserinfoEndpoint != "" {
+		if err := p.mergeUserInfo(ctx, conf.Client(ctx, token), metadata.UserinfoEndpoint, claims); err != nil {
+			return nil, err
+		}
+	}
+	return p.mapClaims(claims), nil
+}
+
+// VerifyIDToken 验证 ID Token：签名（提供方 JWKS）、iss、aud、exp、iat 和 nonce
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (jwtlib.MapClaims, error) {
+	parser := jwtlib.NewParser(
+		jwtlib.WithValidMethods(idTokenAlgorithms),
+		jwtlib.WithIssuer(p.cfg.Issuer),
+		jwtlib.WithAudience(p.cfg.ClientID),
+		jwtlib.WithExpirationRequired(),
+		jwtlib.WithIssuedAt(),
+		jwtlib.WithLeeway(idTokenLeeway),
+	)
+
+	claims := jwtlib.MapClaims{}
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwtlib.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.lookupKey(ctx, kid, token.Method.Alg())
+		if err != nil {
+			return nil, err
+		}
+		// 验证签名算法与密钥一致，防止算法混淆攻击
+		if token.Method.Alg() != key.Algorithm {
+			return nil, errors.New("不支持的签名算法")
+		}
+		return key.PublicKey(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ID Token 验证失败: %w", err)
+	}
+
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("ID Token 缺少 sub")
+	}
+	// 存在多个受众时，azp 必须是本客户端（OpenID Connect Core 3.1.3.7）
+	if aud, ok := claims["aud"].([]interface{}); ok && len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.cfg.ClientID {
+			return nil, errors.New("ID Token 的 azp 与客户端不匹配")
+		}
+	}
+	tokenNonce, _ := claims["nonce"].(string)
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, errors.New("ID Token 的 nonce 不匹配")
+	}
+	return claims, nil
+}
+
+// mergeUserInfo 从 userinfo 接口补充 ID Token 中没有的声明，sub 必须一致
+func (p *OIDCProvider) mergeUserInfo(ctx context.Context, client *http.Client, endpoint string, claims jwtlib.MapClaims) error {
+	var info map[string]interface{}
+	if err := p.getJSON(ctx, client, endpoint, &info); err != nil {
+		return fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	if sub, _ := info["sub"].(string); sub != claims["sub"] {
+		return errors.New("userinfo 的 sub 与 ID Token 不一致")
+	}
+	for k, v := range info {
+		if _, ok := claims[k]; !ok {
+			claims[k] = v
+		}
+	}
+	return nil
+}
+
+// mapClaims 按配置的声明映射得到用户信息
+func (p *OIDCProvider) mapClaims(claims jwtlib.MapClaims) *OIDCUser {
+	mapping := p.cfg.Claims
+	u := &OIDCUser{
+		Provider:      p.name,
+		Subject:       claims["sub"].(string),
+		Username:      claimString(claims, orDefault(mapping.Username, "preferred_username")),
+		Email:         claimString(claims, orDefault(mapping.Email, "email")),
+		EmailVerified: claimBool(claims, orDefault(mapping.EmailVerified, "email_verified")),
+		Name:          claimString(claims, orDefault(mapping.Name, "name")),
+		Picture:       claimString(claims, orDefault(mapping.Picture, "picture")),
+		Claims:        claims,
+	}
+	if u.Username == "" && u.Email != "" {
+		u.Username, _, _ = strings.Cut(u.Email, "@")
+	}
+	return u
+}
+
+// oauth2Config 根据发现文档生成 OAuth2 客户端配置
+func (p *OIDCProvider) oauth2Config(ctx context.Context) (*oauth2.Config, *OIDCMetadata, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}, metadata, nil
+}
+
+// discover 获取并缓存发现文档
+func (p *OIDCProvider) discover(ctx context.Context) (*OIDCMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil && time.Since(p.discoveredAt) < discoveryTTL {
+		return p.metadata, nil
+	}
+
+	var metadata OIDCMetadata
+	endpoint := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, p.httpClient, endpoint, &metadata); err != nil {
+		if p.metadata != nil {
+			// 刷新失败时继续使用旧的发现文档
+			return p.metadata, nil
+		}
+		return nil, fmt.Errorf("获取 %s 发现文档失败: %w", p.name, err)
+	}
+	if metadata.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%s 发现文档的 issuer 不匹配: %q", p.name, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%s 发现文档缺少必要的接口地址", p.name)
+	}
+
+	p.metadata = &metadata
+	p.discoveredAt = time.Now()
+	return p.metadata, nil
+}
+
+// lookupKey 查找验证密钥；密钥集过期或遇到未知 kid（提供方轮换了密钥）时重新获取 JWKS
+func (p *OIDCProvider) lookupKey(ctx context.Context, kid, algorithm string) (*jwt.Key, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil && time.Since(p.keysFetchedAt) < jwksTTL {
+		key, err := p.keys.Lookup(kid, algorithm)
+		if err == nil || time.Since(p.keysFetchedAt) < jwksMinRefresh {
+			return key, err
+		}
+	}
+
+	data, err := p.get(ctx, p.httpClient, metadata.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s JWKS 失败: %w", p.name, err)
+	}
+	keys, err := jwt.ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	return p.keys.Lookup(kid, algorithm)
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, client *http.Client, endpoint string, v interface{}) error {
+	data, err := p.get(ctx, client, endpoint)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
+
+func (p *OIDCProvider) get(ctx context.Context, client *http.Client, endpoint string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("返回错误状态码: %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
+
+// claimString 读取字符串声明，path 中的 . 表示嵌套对象
+func claimString(claims map[string]interface{}, path string) string {
+	s, _ := claimValue(claims, path).(string)
+	return s
+}
+
+// claimBool 读取布尔声明，兼容部分提供方返回的字符串 "true"
+func claimBool(claims map[string]interface{}, path string) bool {
+	switch v := claimValue(claims, path).(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func claimValue(claims map[string]interface{}, path string) interface{} {
+	var current interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,16 @@
+package oauth2
+
+import (
+	"errors"
+
+	"golang.org/x/oauth2"
+)
+
+// errMissingVerifier 没有 PKCE 验证码时拒绝交换授权码，确保每次授权都使用 PKCE
+var errMissingVerifier = errors.New("缺少 PKCE 验证码")
+
+// NewCodeVerifier 生成 PKCE 验证码（RFC 7636），与状态码一起保存在 OAuth2 会话中，交换授权码时提交
+// 授权请求只携带其 S256 摘要，截获授权码的一方没有验证码无法换取令牌
+func NewCodeVerifier() string {
+	return oauth2.GenerateVerifier()
+}
@@ -0,0 +1,274 @@
+package passkey
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/redis"
+)
+
+// ErrCeremonyNotFound 仪式不存在或已过期
+var ErrCeremonyNotFound = errors.New("认证已过期，请重试")
+
+// extensionCredProps 凭证属性扩展，客户端通过其 rk 字段报告是否创建了可发现凭证
+const extensionCredProps = "credProps"
+
+// Account WebAuthn 用户账户，实现 webauthn.User 接口
+type Account struct {
+	ID          uint
+	Name        string // 用户名
+	DisplayName string // 显示名称
+	Credentials []webauthn.Credential
+}
+
+// WebAuthnID 用户句柄（user handle），由用户ID编码，不包含个人信息
+func (a *Account) WebAuthnID() []byte {
+	return UserHandle(a.ID)
+}
+
+// WebAuthnName 用户名
+func (a *Account) WebAuthnName() string {
+	return a.Name
+}
+
+// WebAuthnDisplayName 显示名称
+func (a *Account) WebAuthnDisplayName() string {
+	if a.DisplayName == "" {
+		return a.Name
+	}
+	return a.DisplayName
+}
+
+// WebAuthnCredentials 已注册的凭证
+func (a *Account) WebAuthnCredentials() []webauthn.Credential {
+	return a.Credentials
+}
+
+// AccountLoader 根据用户ID加载账户及其凭证
+type AccountLoader func(userID uint) (*Account, error)
+
+// Manager WebAuthn 仪式管理器：生成注册/认证选项，并在 Redis 中保存一次性的仪式状态
+type Manager struct {
+	webAuthn    *webauthn.WebAuthn
+	redisClient *redis.Client
+	timeout     time.Duration
+}
+
+// NewManager 创建 WebAuthn 仪式管理器
+func NewManager(cfg *config.WebAuthnConfig, redisClient *redis.Client) (*Manager, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.Timeout, TimeoutUVD: cfg.Timeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.Timeout, TimeoutUVD: cfg.Timeout},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化 WebAuthn 失败: %w", err)
+	}
+
+	return &Manager{
+		webAuthn:    w,
+		redisClient: redisClient,
+		timeout:     cfg.Timeout,
+	}, nil
+}
+
+// BeginRegistration 开始注册仪式，返回创建选项和仪式令牌
+// 不限制认证器类型，平台认证器（指纹、面容）和漫游安全密钥均可注册；优先创建可发现凭证（通行密钥），
+// 并请求 credProps 扩展，由客户端报告是否实际创建了可发现凭证
+func (m *Manager) BeginRegistration(ctx context.Context, account *Account) (*protocol.CredentialCreation, string, error) {
+	creation, sessionData, err := m.webAuthn.BeginRegistration(account,
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		}),
+		webauthn.WithExclusions(webauthn.Credentials(account.Credentials).CredentialDescriptors()),
+		webauthn.WithExtensions(protocol.AuthenticationExtensions{extensionCredProps: true}),
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("生成注册选项失败: %w", err)
+	}
+
+	ceremonyToken, err := m.saveSessionData(ctx, sessionData)
+	if err != nil {
+		return nil, "", err
+	}
+	return creation, ceremonyToken, nil
+}
+
+// FinishRegistration 完成注册仪式，校验认证器的响应并返回新凭证，以及该凭证是否为可发现凭证
+// 客户端未返回 credProps 扩展结果时无法确认，按不可发现处理
+func (m *Manager) FinishRegistration(ctx context.Context, account *Account, ceremonyToken string, response []byte) (*webauthn.Credential, bool, error) {
+	sessionData, err := m.takeSessionData(ctx, ceremonyToken)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, false, fmt.Errorf("解析注册响应失败: %w", err)
+	}
+
+	credential, err := m.webAuthn.CreateCredential(account, *sessionData, parsed)
+	if err != nil {
+		return nil, false, fmt.Errorf("校验注册响应失败: %w", err)
+	}
+	return credential, residentKey(parsed.ClientExtensionResults), nil
+}
+
+// residentKey 读取 credProps 扩展结果中的 rk 字段
+func residentKey(results protocol.AuthenticationExtensionsClientOutputs) bool {
+	props, ok := results[extensionCredProps].(map[string]any)
+	if !ok {
+		return false
+	}
+	rk, _ := props["rk"].(bool)
+	return rk
+}
+
+// BeginLogin 开始认证仪式，返回请求选项和仪式令牌
+// account 为 nil 时发起可发现凭证认证（无需用户名），此时要求用户验证，使通行密钥本身满足多因子；
+// 否则只允许该账户已注册的凭证，用于密码之后的第二因子。
+func (m *Manager) BeginLogin(ctx context.Context, account *Account) (*protocol.CredentialAssertion, string, error) {
+	var (
+		assertion   *protocol.CredentialAssertion
+		sessionData *webauthn.SessionData
+		err         error
+	)
+	if account == nil {
+		assertion, sessionData, err = m.webAuthn.BeginDiscoverableLogin(
+			webauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+	} else {
+		assertion, sessionData, err = m.webAuthn.BeginLogin(account,
+			webauthn.WithUserVerification(protocol.VerificationDiscouraged),
+		)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("生成认证选项失败: %w", err)
+	}
+
+	ceremonyToken, err := m.saveSessionData(ctx, sessionData)
+	if err != nil {
+		return nil, "", err
+	}
+	return assertion, ceremonyToken, nil
+}
+
+// FinishLogin 完成认证仪式，返回凭证所属账户和更新了签名计数的凭证
+// 调用方需检查 credential.Authenticator.CloneWarning：签名计数未递增说明认证器可能已被克隆
+func (m *Manager) FinishLogin(ctx context.Context, ceremonyToken string, response []byte, load AccountLoader) (*Account, *webauthn.Credential, error) {
+	sessionData, err := m.takeSessionData(ctx, ceremonyToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析认证响应失败: %w", err)
+	}
+
+	// 可发现凭证认证：根据认证器返回的用户句柄查找账户
+	if len(sessionData.UserID) == 0 {
+		var account *Account
+		_, credential, err := m.webAuthn.ValidatePasskeyLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			userID, err := UserIDFromHandle(userHandle)
+			if err != nil {
+				return nil, err
+			}
+			account, err = load(userID)
+			return account, err
+		}, *sessionData, parsed)
+		if err != nil {
+			return nil, nil, fmt.Errorf("校验认证响应失败: %w", err)
+		}
+		return account, credential, nil
+	}
+
+	userID, err := UserIDFromHandle(sessionData.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := load(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	credential, err := m.webAuthn.ValidateLogin(account, *sessionData, parsed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("校验认证响应失败: %w", err)
+	}
+	return account, credential, nil
+}
+
+// saveSessionData 保存仪式状态，返回仪式令牌
+func (m *Manager) saveSessionData(ctx context.Context, sessionData *webauthn.SessionData) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成仪式令牌失败: %w", err)
+	}
+	ceremonyToken := base64.RawURLEncoding.EncodeToString(b)
+
+	dataJSON, err := json.Marshal(sessionData)
+	if err != nil {
+		return "", fmt.Errorf("序列化仪式状态失败: %w", err)
+	}
+	if err := m.redisClient.Set(ctx, ceremonyKey(ceremonyToken), string(dataJSON), m.timeout); err != nil {
+		return "", fmt.Errorf("存储仪式状态到 Redis 失败: %w", err)
+	}
+	return ceremonyToken, nil
+}
+
+// takeSessionData 取出并删除仪式状态（挑战只能使用一次）
+func (m *Manager) takeSessionData(ctx context.Context, ceremonyToken string) (*webauthn.SessionData, error) {
+	key := ceremonyKey(ceremonyToken)
+	dataJSON, err := m.redisClient.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrCeremonyNotFound
+		}
+		return nil, fmt.Errorf("获取仪式状态失败: %w", err)
+	}
+	if err := m.redisClient.Del(ctx, key); err != nil {
+		return nil, fmt.Errorf("删除仪式状态失败: %w", err)
+	}
+
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal([]byte(dataJSON), &sessionData); err != nil {
+		return nil, fmt.Errorf("解析仪式状态失败: %w", err)
+	}
+	return &sessionData, nil
+}
+
+// UserHandle 由用户ID生成用户句柄（8字节大端序）
+func UserHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+// UserIDFromHandle 由用户句柄解析用户ID
+func UserIDFromHandle(handle []byte) (uint, error) {
+	if len(handle) != 8 || bytes.Equal(handle, make([]byte, 8)) {
+		return 0, errors.New("无效的用户句柄")
+	}
+	return uint(binary.BigEndian.Uint64(handle)), nil
+}
+
+func ceremonyKey(ceremonyToken string) string {
+	return fmt.Sprintf("passkey:ceremony:%s", ceremonyToken)
+}
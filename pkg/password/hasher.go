@@ -0,0 +1,274 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+
+	"auth-service/internal/config"
+)
+
+// 支持的密码哈希算法
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmScrypt   = "scrypt"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// 密码哈希相关错误
+var (
+	ErrUnsupportedHash = errors.New("不支持的密码哈希格式")
+	ErrMalformedHash   = errors.New("密码哈希格式错误")
+)
+
+// b64 PHC 字符串使用不带填充的标准 Base64 编码盐和哈希
+var b64 = base64.RawStdEncoding
+
+// Hasher 密码哈希器：按配置的算法和参数生成 PHC 格式的哈希
+//
+//	argon2id: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//	scrypt:   $scrypt$ln=15,r=8,p=1$<salt>$<hash>
+//	bcrypt:   $2a$12$<salt+hash>（bcrypt 自身的模块化格式）
+type Hasher struct {
+	algorithm string
+	argon2    config.Argon2Config
+	scrypt    config.ScryptConfig
+	bcrypt    config.BcryptConfig
+}
+
+// NewHasher 创建密码哈希器，算法或参数无效时返回错误
+func NewHasher(cfg *config.PasswordHashConfig) (*Hasher, error) {
+	h := &Hasher{
+		algorithm: cfg.Algorithm,
+		argon2:    cfg.Argon2,
+		scrypt:    cfg.Scrypt,
+		bcrypt:    cfg.Bcrypt,
+	}
+
+	switch cfg.Algorithm {
+	case AlgorithmArgon2id:
+		a := cfg.Argon2
+		if a.Memory < 8*uint32(a.Parallelism) || a.Iterations < 1 || a.Parallelism < 1 || a.SaltLength < 8 || a.KeyLength < 16 {
+			return nil, fmt.Errorf("argon2id 参数无效: %+v", a)
+		}
+	case AlgorithmScrypt:
+		s := cfg.Scrypt
+		if s.LogN < 1 || s.LogN > 31 || s.R < 1 || s.P < 1 || s.SaltLength < 8 || s.KeyLength < 16 {
+			return nil, fmt.Errorf("scrypt 参数无效: %+v", s)
+		}
+	case AlgorithmBcrypt:
+		if cfg.Bcrypt.Cost < bcrypt.MinCost || cfg.Bcrypt.Cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt 开销因子无效: %d", cfg.Bcrypt.Cost)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的密码哈希算法: %q", cfg.Algorithm)
+	}
+	return h, nil
+}
+
+// Algorithm 返回新密码使用的算法
+func (h *Hasher) Algorithm() string {
+	return h.algorithm
+}
+
+// Hash 使用配置的算法和参数计算密码哈希
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		salt, err := randomSalt(h.argon2.SaltLength)
+		if err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.argon2.Iterations, h.argon2.Memory, h.argon2.Parallelism, h.argon2.KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, h.argon2.Memory, h.argon2.Iterations, h.argon2.Parallelism,
+			b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+	case AlgorithmScrypt:
+		salt, err := randomSalt(h.scrypt.SaltLength)
+		if err != nil {
+			return "", err
+		}
+		key, err := scrypt.Key([]byte(password), salt, 1<<h.scrypt.LogN, h.scrypt.R, h.scrypt.P, h.scrypt.KeyLength)
+		if err != nil {
+			return "", fmt.Errorf("计算 scrypt 哈希失败: %w", err)
+		}
+		return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s",
+			h.scrypt.LogN, h.scrypt.R, h.scrypt.P,
+			b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+	default:
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcrypt.Cost)
+		if err != nil {
+			return "", fmt.Errorf("计算 bcrypt 哈希失败: %w", err)
+		}
+		return string(hashed), nil
+	}
+}
+
+// NeedsRehash 判断哈希是否使用了过时的算法或参数，需要在下次验证成功后重新计算
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	if isBcrypt(encoded) {
+		if h.algorithm != AlgorithmBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.bcrypt.Cost
+	}
+
+	p, err := parsePHC(encoded)
+	if err != nil || p.id != h.algorithm {
+		return true
+	}
+
+	switch p.id {
+	case AlgorithmArgon2id:
+		m, t, par, err := p.argon2Params()
+		return err != nil || p.version != argon2.Version ||
+			m != h.argon2.Memory || t != h.argon2.Iterations || par != h.argon2.Parallelism ||
+			len(p.salt) != h.argon2.SaltLength || len(p.hash) != int(h.argon2.KeyLength)
+	case AlgorithmScrypt:
+		ln, r, par, err := p.scryptParams()
+		return err != nil ||
+			ln != h.scrypt.LogN || r != h.scrypt.R || par != h.scrypt.P ||
+			len(p.salt) != h.scrypt.SaltLength || len(p.hash) != h.scrypt.KeyLength
+	}
+	return true
+}
+
+// Verify 校验密码是否与哈希匹配，支持 argon2id、scrypt 和 bcrypt 格式，哈希中已包含全部参数
+func Verify(password, encoded string) (bool, error) {
+	if isBcrypt(encoded) {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+		return true, nil
+	}
+
+	p, err := parsePHC(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	var key []byte
+	switch p.id {
+	case AlgorithmArgon2id:
+		m, t, par, err := p.argon2Params()
+		if err != nil {
+			return false, err
+		}
+		if p.version != argon2.Version {
+			return false, fmt.Errorf("%w: 不支持的 argon2 版本 %d", ErrUnsupportedHash, p.version)
+		}
+		key = argon2.IDKey([]byte(password), p.salt, t, m, par, uint32(len(p.hash)))
+	case AlgorithmScrypt:
+		ln, r, par, err := p.scryptParams()
+		if err != nil {
+			return false, err
+		}
+		key, err = scrypt.Key([]byte(password), p.salt, 1<<ln, r, par, len(p.hash))
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+	default:
+		return false, fmt.Errorf("%w: %s", ErrUnsupportedHash, p.id)
+	}
+
+	return subtle.ConstantTimeCompare(key, p.hash) == 1, nil
+}
+
+// phc 解析后的 PHC 字符串：$<id>[$v=<version>]$<params>$<salt>$<hash>
+type phc struct {
+	id      string
+	version int
+	params  map[string]string
+	salt    []byte
+	hash    []byte
+}
+
+// parsePHC 解析 PHC 格式的哈希字符串
+func parsePHC(encoded string) (*phc, error) {
+	fields := strings.Split(encoded, "$")
+	if len(fields) < 5 || fields[0] != "" {
+		return nil, ErrUnsupportedHash
+	}
+
+	p := &phc{id: fields[1], params: make(map[string]string)}
+	fields = fields[2:]
+	if strings.HasPrefix(fields[0], "v=") {
+		v, err := strconv.Atoi(strings.TrimPrefix(fields[0], "v="))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 版本号无效", ErrMalformedHash)
+		}
+		p.version = v
+		fields = fields[1:]
+	}
+	if len(fields) != 3 {
+		return nil, ErrMalformedHash
+	}
+
+	for _, kv := range strings.Split(fields[0], ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: 参数格式无效", ErrMalformedHash)
+		}
+		p.params[k] = v
+	}
+
+	var err error
+	if p.salt, err = b64.DecodeString(fields[1]); err != nil {
+		return nil, fmt.Errorf("%w: 盐编码无效", ErrMalformedHash)
+	}
+	if p.hash, err = b64.DecodeString(fields[2]); err != nil {
+		return nil, fmt.Errorf("%w: 哈希编码无效", ErrMalformedHash)
+	}
+	if len(p.hash) == 0 {
+		return nil, ErrMalformedHash
+	}
+	return p, nil
+}
+
+// argon2Params 读取 argon2id 参数 m（KiB）、t、p
+func (p *phc) argon2Params() (memory, iterations uint32, parallelism uint8, err error) {
+	m, err1 := strconv.ParseUint(p.params["m"], 10, 32)
+	t, err2 := strconv.ParseUint(p.params["t"], 10, 32)
+	par, err3 := strconv.ParseUint(p.params["p"], 10, 8)
+	if err := errors.Join(err1, err2, err3); err != nil || t < 1 || par < 1 {
+		return 0, 0, 0, fmt.Errorf("%w: argon2id 参数无效", ErrMalformedHash)
+	}
+	return uint32(m), uint32(t), uint8(par), nil
+}
+
+// scryptParams 读取 scrypt 参数 ln、r、p
+func (p *phc) scryptParams() (logN uint8, r, parallelism int, err error) {
+	ln, err1 := strconv.ParseUint(p.params["ln"], 10, 8)
+	rr, err2 := strconv.Atoi(p.params["r"])
+	par, err3 := strconv.Atoi(p.params["p"])
+	if err := errors.Join(err1, err2, err3); err != nil || ln < 1 || ln > 31 || rr < 1 || par < 1 {
+		return 0, 0, 0, fmt.Errorf("%w: scrypt 参数无效", ErrMalformedHash)
+	}
+	return uint8(ln), rr, par, nil
+}
+
+// isBcrypt 判断是否为 bcrypt 模块化格式（$2a$、$2b$、$2y$）
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func randomSalt(n int) ([]byte, error) {
+	salt := make([]byte, n)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成盐失败: %w", err)
+	}
+	return salt, nil
+}
@@ -0,0 +1,259 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 从外部系统导入的旧密码哈希算法，只用于验证，首次登录成功后升级为当前算法
+const (
+	// LegacySaltedSHA1 加盐 SHA-1，支持两种格式：
+	//   sha1$<salt>$<hex(sha1(salt+password))>（Django）
+	//   {SSHA}<base64(sha1(password+salt)+salt)>（LDAP）
+	LegacySaltedSHA1 = "sha1_salted"
+	// LegacyMD5Crypt MD5-crypt：$1$<salt>$<hash>
+	LegacyMD5Crypt = "md5_crypt"
+	// LegacyPBKDF2SHA256 PBKDF2-SHA256，支持两种格式：
+	//   pbkdf2_sha256$<iterations>$<salt>$<base64(hash)>（Django）
+	//   $pbkdf2-sha256$<iterations>$<ab64(salt)>$<ab64(hash)>（passlib）
+	LegacyPBKDF2SHA256 = "pbkdf2_sha256"
+)
+
+// bcryptHashLength bcrypt 模块化格式哈希的固定长度
+const bcryptHashLength = 60
+
+// maxPBKDF2Iterations 导入哈希允许的最大迭代次数，避免异常数据拖慢登录
+const maxPBKDF2Iterations = 10_000_000
+
+// IsLegacy 判断是否为旧密码哈希算法
+func IsLegacy(algorithm string) bool {
+	switch algorithm {
+	case LegacySaltedSHA1, LegacyMD5Crypt, LegacyPBKDF2SHA256:
+		return true
+	}
+	return false
+}
+
+// CheckFormat 检查哈希是否为指定算法的有效格式，用于导入前校验
+func CheckFormat(algorithm, encoded string) error {
+	switch algorithm {
+	case AlgorithmArgon2id, AlgorithmScrypt:
+		p, err := parsePHC(encoded)
+		if err != nil {
+			return err
+		}
+		if p.id != algorithm {
+			return fmt.Errorf("%w: 哈希算法为 %s", ErrMalformedHash, p.id)
+		}
+		if algorithm == AlgorithmArgon2id {
+			_, _, _, err = p.argon2Params()
+		} else {
+			_, _, _, err = p.scryptParams()
+		}
+		return err
+	case AlgorithmBcrypt:
+		if !isBcrypt(encoded) {
+			return ErrMalformedHash
+		}
+		// 解析开销因子和盐，截断或损坏的哈希导入后将永远无法登录
+		if _, err := bcrypt.Cost([]byte(encoded)); err != nil || len(encoded) != bcryptHashLength {
+			return fmt.Errorf("%w: bcrypt 哈希无效", ErrMalformedHash)
+		}
+		return nil
+	case LegacyPBKDF2SHA256:
+		_, _, _, err := parsePBKDF2(encoded)
+		return err
+	case LegacySaltedSHA1, LegacyMD5Crypt:
+		// 计算开销很小，直接以空密码校验一次即可检查格式
+		_, err := VerifyLegacy(algorithm, "", encoded)
+		return err
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedHash, algorithm)
+}
+
+// VerifyLegacy 使用旧算法校验密码
+func VerifyLegacy(algorithm, password, encoded string) (bool, error) {
+	var expected, actual []byte
+	switch algorithm {
+	case LegacySaltedSHA1:
+		switch {
+		case strings.HasPrefix(encoded, "{SSHA}"):
+			raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encoded, "{SSHA}"))
+			if err != nil || len(raw) <= sha1.Size {
+				return false, fmt.Errorf("%w: SSHA 编码无效", ErrMalformedHash)
+			}
+			expected = raw[:sha1.Size]
+			sum := sha1.Sum(append([]byte(password), raw[sha1.Size:]...))
+			actual = sum[:]
+		case strings.HasPrefix(encoded, "sha1$"):
+			fields := strings.Split(encoded, "$")
+			if len(fields) != 3 {
+				return false, ErrMalformedHash
+			}
+			var err error
+			if expected, err = hex.DecodeString(fields[2]); err != nil || len(expected) != sha1.Size {
+				return false, fmt.Errorf("%w: SHA-1 摘要无效", ErrMalformedHash)
+			}
+			sum := sha1.Sum([]byte(fields[1] + password))
+			actual = sum[:]
+		default:
+			return false, ErrMalformedHash
+		}
+	case LegacyMD5Crypt:
+		salt, ok := md5CryptSalt(encoded)
+		if !ok {
+			return false, ErrMalformedHash
+		}
+		expected = []byte(encoded)
+		actual = []byte(md5Crypt([]byte(password), []byte(salt)))
+	case LegacyPBKDF2SHA256:
+		iterations, salt, hash, err := parsePBKDF2(encoded)
+		if err != nil {
+			return false, err
+		}
+		expected = hash
+		if actual, err = pbkdf2.Key(sha256.New, password, salt, iterations, len(hash)); err != nil {
+			return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+	default:
+		return false, fmt.Errorf("%w: %q", ErrUnsupportedHash, algorithm)
+	}
+
+	return subtle.ConstantTimeCompare(expected, actual) == 1, nil
+}
+
+// parsePBKDF2 解析 Django 或 passlib 格式的 PBKDF2-SHA256 哈希
+func parsePBKDF2(encoded string) (iterations int, salt, hash []byte, err error) {
+	var fields []string
+	var decode func(string) ([]byte, error)
+	switch {
+	case strings.HasPrefix(encoded, "pbkdf2_sha256$"):
+		fields = strings.Split(encoded, "$")
+		if len(fields) != 4 {
+			return 0, nil, nil, ErrMalformedHash
+		}
+		fields = fields[1:]
+		// Django 直接使用盐字符串，哈希为标准 Base64
+		decode = func(s string) ([]byte, error) { return []byte(s), nil }
+		if hash, err = base64.StdEncoding.DecodeString(fields[2]); err != nil {
+			return 0, nil, nil, fmt.Errorf("%w: 哈希编码无效", ErrMalformedHash)
+		}
+	case strings.HasPrefix(encoded, "$pbkdf2-sha256$"):
+		fields = strings.Split(encoded, "$")
+		if len(fields) != 5 {
+			return 0, nil, nil, ErrMalformedHash
+		}
+		fields = fields[2:]
+		// passlib 的 ab64 编码：以 "." 代替 "+" 且不带填充
+		decode = func(s string) ([]byte, error) {
+			return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(s, ".", "+"))
+		}
+		if hash, err = decode(fields[2]); err != nil {
+			return 0, nil, nil, fmt.Errorf("%w: 哈希编码无效", ErrMalformedHash)
+		}
+	default:
+		return 0, nil, nil, ErrMalformedHash
+	}
+
+	iterations, err = strconv.Atoi(fields[0])
+	if err != nil || iterations < 1 || iterations > maxPBKDF2Iterations {
+		return 0, nil, nil, fmt.Errorf("%w: 迭代次数无效", ErrMalformedHash)
+	}
+	if salt, err = decode(fields[1]); err != nil || len(salt) == 0 {
+		return 0, nil, nil, fmt.Errorf("%w: 盐编码无效", ErrMalformedHash)
+	}
+	if len(hash) == 0 {
+		return 0, nil, nil, ErrMalformedHash
+	}
+	return iterations, salt, hash, nil
+}
+
+// md5CryptSalt 从 $1$<salt>$<hash> 中取出盐（最多8个字符）
+func md5CryptSalt(encoded string) (string, bool) {
+	if !strings.HasPrefix(encoded, "$1$") {
+		return "", false
+	}
+	salt, hash, ok := strings.Cut(encoded[3:], "$")
+	if !ok || len(salt) > 8 || len(hash) != 22 {
+		return "", false
+	}
+	return salt, true
+}
+
+// md5Crypt 计算 MD5-crypt 哈希（兼容 FreeBSD / glibc 的 $1$ 格式）
+func md5Crypt(password, salt []byte) string {
+	const magic = "$1$"
+
+	alt := md5.New()
+	alt.Write(password)
+	alt.Write(salt)
+	alt.Write(password)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(password)
+	ctx.Write([]byte(magic))
+	ctx.Write(salt)
+	for n := len(password); n > 0; n -= md5.Size {
+		ctx.Write(altSum[:min(n, md5.Size)])
+	}
+	for i := len(password); i != 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(password[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+
+	// 1000 轮迭代
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 != 0 {
+			round.Write(password)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write(salt)
+		}
+		if i%7 != 0 {
+			round.Write(password)
+		}
+		if i&1 != 0 {
+			round.Write(final)
+		} else {
+			round.Write(password)
+		}
+		final = round.Sum(nil)
+	}
+
+	var b strings.Builder
+	b.WriteString(magic)
+	b.Write(salt)
+	b.WriteByte('$')
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		cryptBase64(&b, uint(final[g[0]])<<16|uint(final[g[1]])<<8|uint(final[g[2]]), 4)
+	}
+	cryptBase64(&b, uint(final[11]), 2)
+	return b.String()
+}
+
+// cryptBase64 crypt(3) 使用的 Base64 变体，低位在前
+func cryptBase64(b *strings.Builder, v uint, n int) {
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	for ; n > 0; n-- {
+		b.WriteByte(itoa64[v&0x3f])
+		v >>= 6
+	}
+}
@@ -0,0 +1,208 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/pwned"
+)
+
+// 密码策略规则标识，返回给前端用于在对应输入框旁展示提示
+const (
+	RuleMinLength      = "min_length"       // 长度不足
+	RuleMaxBytes       = "max_bytes"        // 超过最大字节数
+	RuleRequireLower   = "require_lower"    // 缺少小写字母
+	RuleRequireUpper   = "require_upper"    // 缺少大写字母
+	RuleRequireDigit   = "require_digit"    // 缺少数字
+	RuleRequireSymbol  = "require_symbol"   // 缺少特殊字符
+	RuleMinCharClasses = "min_char_classes" // 字符种类不足
+	RuleUserInfo       = "user_info"        // 包含用户名或邮箱
+	RuleCommon         = "common"           // 常见弱密码
+	RuleReused         = "reused"           // 与最近使用过的密码相同
+	RuleBreached       = "breached"         // 出现在已泄露的密码库中
+)
+
+//go:embed common_passwords.txt
+var builtinCommonPasswords string
+
+// Violation 违反的密码规则
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError 密码不符合策略，包含全部违反的规则
+type PolicyError struct {
+	Violations []Violation
+}
+
+// Error 实现 error 接口
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return "密码不符合安全要求: " + strings.Join(messages, "；")
+}
+
+// UserInfo 检查密码时使用的用户信息，密码中不能包含这些内容
+type UserInfo struct {
+	Username string
+	Email    string
+}
+
+// Policy 密码策略
+type Policy struct {
+	cfg      config.PasswordPolicyConfig
+	common   map[string]struct{}
+	breached pwned.Checker // 离线泄露密码库，未配置时为 nil
+}
+
+// NewPolicy 创建密码策略，未配置常见密码文件时使用内置列表
+func NewPolicy(cfg *config.PasswordPolicyConfig) (*Policy, error) {
+	if cfg.MinLength < 1 {
+		return nil, fmt.Errorf("密码最小长度无效: %d", cfg.MinLength)
+	}
+	if cfg.MaxBytes > 0 && cfg.MaxBytes < cfg.MinLength {
+		return nil, fmt.Errorf("密码最大字节数 %d 小于最小长度 %d", cfg.MaxBytes, cfg.MinLength)
+	}
+
+	var r io.Reader = strings.NewReader(builtinCommonPasswords)
+	if cfg.CommonPasswordsFile != "" {
+		f, err := os.Open(cfg.CommonPasswordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("打开常见密码列表失败: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	common, err := loadCommonPasswords(r)
+	if err != nil {
+		return nil, err
+	}
+
+	breached, err := pwned.Open(&cfg.Breach)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Policy{cfg: *cfg, common: common, breached: breached}, nil
+}
+
+// HistorySize 禁止重复使用的最近密码个数，0 表示不检查
+func (p *Policy) HistorySize() int {
+	return p.cfg.HistorySize
+}
+
+// Check 检查密码是否符合策略（不含历史密码检查），返回全部违反的规则
+func (p *Policy) Check(password string, info UserInfo) ([]Violation, error) {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		add(RuleMinLength, "密码长度不能少于 %d 个字符", p.cfg.MinLength)
+	}
+	if p.cfg.MaxBytes > 0 && len(password) > p.cfg.MaxBytes {
+		add(RuleMaxBytes, "密码不能超过 %d 字节", p.cfg.MaxBytes)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.cfg.RequireLower && !lower {
+		add(RuleRequireLower, "密码必须包含小写字母")
+	}
+	if p.cfg.RequireUpper && !upper {
+		add(RuleRequireUpper, "密码必须包含大写字母")
+	}
+	if p.cfg.RequireDigit && !digit {
+		add(RuleRequireDigit, "密码必须包含数字")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		add(RuleRequireSymbol, "密码必须包含特殊字符")
+	}
+	if classes := countTrue(lower, upper, digit, symbol); classes < p.cfg.MinCharClasses {
+		add(RuleMinCharClasses, "密码至少需要包含大写字母、小写字母、数字、特殊字符中的 %d 种", p.cfg.MinCharClasses)
+	}
+
+	if p.cfg.ForbidUserInfo && containsUserInfo(password, info) {
+		add(RuleUserInfo, "密码不能包含用户名或邮箱")
+	}
+	if _, ok := p.common[strings.ToLower(password)]; ok {
+		add(RuleCommon, "密码过于常见，容易被猜到")
+	} else if p.breached != nil {
+		breached, err := p.breached.Breached(password)
+		if err != nil {
+			return nil, fmt.Errorf("检查泄露密码库失败: %w", err)
+		}
+		if breached {
+			add(RuleBreached, "该密码已出现在公开泄露的密码库中，请更换")
+		}
+	}
+
+	return violations, nil
+}
+
+// containsUserInfo 判断密码是否包含用户名或邮箱前缀（不区分大小写，过短的不检查）
+func containsUserInfo(password string, info UserInfo) bool {
+	const minLen = 3
+
+	lowered := strings.ToLower(password)
+	candidates := []string{info.Username}
+	if local, _, ok := strings.Cut(info.Email, "@"); ok {
+		candidates = append(candidates, local)
+	}
+	for _, c := range candidates {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if utf8.RuneCountInString(c) >= minLen && strings.Contains(lowered, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCommonPasswords 读取常见密码列表：每行一个，忽略空行和 # 开头的注释
+func loadCommonPasswords(r io.Reader) (map[string]struct{}, error) {
+	common := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		common[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取常见密码列表失败: %w", err)
+	}
+	return common, nil
+}
+
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
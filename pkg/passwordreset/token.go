@@ -0,0 +1,161 @@
+package passwordreset
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/redis"
+)
+
+// ErrTokenInvalid 重置令牌无效、已过期或已使用
+var ErrTokenInvalid = errors.New("重置链接无效或已过期")
+
+// payloadSize 令牌载荷长度：用户ID(8) + 过期时间(8) + 随机数(16)
+const payloadSize = 8 + 8 + 16
+
+// PasswordHashLookup 根据用户ID查询当前的密码哈希
+type PasswordHashLookup func(userID uint) (string, error)
+
+// Manager 密码重置令牌管理器
+//
+// 令牌格式为 base64url(载荷).base64url(签名)，签名覆盖载荷和签发时的密码哈希，
+// 因此密码一旦修改，此前签发的令牌全部失效；令牌使用后在 Redis 中记录，不能重复使用。
+type Manager struct {
+	secret      []byte
+	ttl         time.Duration
+	cooldown    time.Duration
+	redisClient *redis.Client
+}
+
+// NewManager 创建密码重置令牌管理器
+func NewManager(cfg *config.PasswordResetConfig, redisClient *redis.Client) (*Manager, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("未配置密码重置令牌签名密钥")
+	}
+	return &Manager{
+		secret:      []byte(cfg.Secret),
+		ttl:         cfg.TokenTTL,
+		cooldown:    cfg.Cooldown,
+		redisClient: redisClient,
+	}, nil
+}
+
+// TTL 返回令牌有效期
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+// AllowSend 限制同一邮箱发送重置邮件的频率，返回本次是否允许发送
+func (m *Manager) AllowSend(ctx context.Context, email string) (bool, error) {
+	if m.cooldown <= 0 {
+		return true, nil
+	}
+	key := fmt.Sprintf("password_reset:cooldown:%s", strings.ToLower(strings.TrimSpace(email)))
+	ok, err := m.redisClient.SetNX(ctx, key, 1, m.cooldown)
+	if err != nil {
+		return false, fmt.Errorf("检查发送频率失败: %w", err)
+	}
+	return ok, nil
+}
+
+// Issue 为用户签发重置令牌，passwordHash 为用户当前的密码哈希
+func (m *Manager) Issue(userID uint, passwordHash string) (string, error) {
+	payload := make([]byte, payloadSize)
+	binary.BigEndian.PutUint64(payload[0:8], uint64(userID))
+	binary.BigEndian.PutUint64(payload[8:16], uint64(time.Now().Add(m.ttl).Unix()))
+	if _, err := rand.Read(payload[16:]); err != nil {
+		return "", fmt.Errorf("生成重置令牌失败: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(m.sign(payload, passwordHash)), nil
+}
+
+// Verify 校验重置令牌但不标记为已使用，返回令牌所属的用户ID
+// 用于在消费令牌前先校验新密码，避免新密码不合规时令牌被浪费
+func (m *Manager) Verify(ctx context.Context, token string, lookup PasswordHashLookup) (uint, error) {
+	userID, payload, _, err := m.verify(token, lookup)
+	if err != nil {
+		return 0, err
+	}
+
+	used, err := m.redisClient.Exists(ctx, usedKey(payload))
+	if err != nil {
+		return 0, fmt.Errorf("查询重置令牌状态失败: %w", err)
+	}
+	if used {
+		return 0, ErrTokenInvalid
+	}
+	return userID, nil
+}
+
+// Consume 校验重置令牌并将其标记为已使用，返回令牌所属的用户ID
+func (m *Manager) Consume(ctx context.Context, token string, lookup PasswordHashLookup) (uint, error) {
+	userID, payload, expiresAt, err := m.verify(token, lookup)
+	if err != nil {
+		return 0, err
+	}
+
+	// 标记为已使用，并发提交同一令牌时只有一个请求成功
+	first, err := m.redisClient.SetNX(ctx, usedKey(payload), 1, time.Until(expiresAt)+time.Minute)
+	if err != nil {
+		return 0, fmt.Errorf("记录重置令牌状态失败: %w", err)
+	}
+	if !first {
+		return 0, ErrTokenInvalid
+	}
+	return userID, nil
+}
+
+// verify 校验令牌格式、有效期和签名
+func (m *Manager) verify(token string, lookup PasswordHashLookup) (uint, []byte, time.Time, error) {
+	payloadPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, nil, time.Time{}, ErrTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil || len(payload) != payloadSize {
+		return 0, nil, time.Time{}, ErrTokenInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return 0, nil, time.Time{}, ErrTokenInvalid
+	}
+
+	userID := uint(binary.BigEndian.Uint64(payload[0:8]))
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[8:16])), 0)
+	if time.Now().After(expiresAt) {
+		return 0, nil, time.Time{}, ErrTokenInvalid
+	}
+
+	passwordHash, err := lookup(userID)
+	if err != nil {
+		return 0, nil, time.Time{}, ErrTokenInvalid
+	}
+	if !hmac.Equal(sig, m.sign(payload, passwordHash)) {
+		return 0, nil, time.Time{}, ErrTokenInvalid
+	}
+	return userID, payload, expiresAt, nil
+}
+
+// sign 计算签名：HMAC-SHA256(密钥, 载荷 || 密码哈希)
+func (m *Manager) sign(payload []byte, passwordHash string) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(payload)
+	mac.Write([]byte(passwordHash))
+	return mac.Sum(nil)
+}
+
+func usedKey(payload []byte) string {
+	return fmt.Sprintf("password_reset:used:%s", hex.EncodeToString(payload[16:]))
+}
@@ -0,0 +1,196 @@
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// 布隆过滤器文件格式（大端序）：
+//
+//	magic   [8]byte  "PWNDBLM1"
+//	k       uint32   哈希函数个数
+//	_       uint32   保留
+//	m       uint64   位数组长度（位）
+//	n       uint64   写入的密码个数
+//	bits    [(m+7)/8]byte
+//
+// 输入本身是均匀分布的 SHA-1，第 i 个位置取 (h1 + i*h2) mod m，h1、h2 分别为 SHA-1 的前后8字节
+const (
+	bloomMagic      = "PWNDBLM1"
+	bloomHeaderSize = 32
+	maxBloomHashes  = 30
+)
+
+// BloomChecker 基于布隆过滤器的检查器：不会漏判，存在少量误判（把未泄露的密码判为已泄露）
+// 位数组按需从文件读取，不占用内存
+type BloomChecker struct {
+	file *os.File
+	k    uint32
+	m    uint64
+	n    uint64
+}
+
+// OpenBloom 打开布隆过滤器文件
+func OpenBloom(path string) (*BloomChecker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开泄露密码库失败: %w", err)
+	}
+
+	header := make([]byte, bloomHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("读取布隆过滤器文件头失败: %w", err)
+	}
+	if string(header[:8]) != bloomMagic {
+		f.Close()
+		return nil, errors.New("不是有效的布隆过滤器文件")
+	}
+
+	c := &BloomChecker{
+		file: f,
+		k:    binary.BigEndian.Uint32(header[8:12]),
+		m:    binary.BigEndian.Uint64(header[16:24]),
+		n:    binary.BigEndian.Uint64(header[24:32]),
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("读取布隆过滤器文件失败: %w", err)
+	}
+	if c.k < 1 || c.k > maxBloomHashes || c.m == 0 || info.Size() != bloomHeaderSize+int64((c.m+7)/8) {
+		f.Close()
+		return nil, errors.New("布隆过滤器文件已损坏")
+	}
+	return c, nil
+}
+
+// Count 返回写入的密码个数
+func (c *BloomChecker) Count() uint64 {
+	return c.n
+}
+
+// Breached 判断密码是否可能出现在泄露密码库中
+func (c *BloomChecker) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	var b [1]byte
+	for _, idx := range bloomIndexes(sum, c.k, c.m) {
+		if _, err := c.file.ReadAt(b[:], bloomHeaderSize+int64(idx/8)); err != nil {
+			return false, fmt.Errorf("读取布隆过滤器失败: %w", err)
+		}
+		if b[0]&(1<<(idx%8)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Close 关闭文件
+func (c *BloomChecker) Close() error {
+	return c.file.Close()
+}
+
+// BuildStats 布隆过滤器构建结果
+type BuildStats struct {
+	Lines   uint64  // 读取的行数
+	Added   uint64  // 写入的密码个数
+	Bits    uint64  // 位数组长度
+	Hashes  uint32  // 哈希函数个数
+	FPR     float64 // 预期误判率
+	Skipped uint64  // 出现次数低于阈值而跳过的行数
+}
+
+// BuildBloom 将下载的泄露密码库文本文件（每行 <SHA-1>:<出现次数>）转换为布隆过滤器
+// 读取两遍输入：第一遍统计数量以确定过滤器大小，第二遍写入；构建时位数组完整保存在内存中
+func BuildBloom(in io.ReadSeeker, out io.Writer, fpr float64, minCount int) (*BuildStats, error) {
+	if fpr <= 0 || fpr >= 1 {
+		return nil, fmt.Errorf("误判率必须在 0 和 1 之间: %v", fpr)
+	}
+
+	var total uint64
+	if err := scanHashes(in, minCount, func([sha1.Size]byte) { total++ }, &BuildStats{}); err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return nil, errors.New("输入中没有符合条件的密码")
+	}
+
+	// m = -n·ln(p) / (ln2)²，k = m/n·ln2
+	n := float64(total)
+	stats := &BuildStats{}
+	stats.Bits = uint64(math.Ceil(-n * math.Log(fpr) / (math.Ln2 * math.Ln2)))
+	stats.Hashes = uint32(math.Max(1, math.Min(maxBloomHashes, math.Round(float64(stats.Bits)/n*math.Ln2))))
+	stats.FPR = math.Pow(1-math.Exp(-float64(stats.Hashes)*n/float64(stats.Bits)), float64(stats.Hashes))
+
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("重新读取输入失败: %w", err)
+	}
+	bits := make([]byte, (stats.Bits+7)/8)
+	if err := scanHashes(in, minCount, func(sum [sha1.Size]byte) {
+		for _, idx := range bloomIndexes(sum, stats.Hashes, stats.Bits) {
+			bits[idx/8] |= 1 << (idx % 8)
+		}
+		stats.Added++
+	}, stats); err != nil {
+		return nil, err
+	}
+	if stats.Added != total {
+		return nil, errors.New("两次读取的输入不一致")
+	}
+
+	header := make([]byte, bloomHeaderSize)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint32(header[8:12], stats.Hashes)
+	binary.BigEndian.PutUint64(header[16:24], stats.Bits)
+	binary.BigEndian.PutUint64(header[24:32], stats.Added)
+	if _, err := out.Write(header); err != nil {
+		return nil, fmt.Errorf("写入布隆过滤器失败: %w", err)
+	}
+	if _, err := out.Write(bits); err != nil {
+		return nil, fmt.Errorf("写入布隆过滤器失败: %w", err)
+	}
+	return stats, nil
+}
+
+// scanHashes 逐行读取泄露密码库，对出现次数不少于 minCount 的哈希调用 fn
+func scanHashes(in io.Reader, minCount int, fn func([sha1.Size]byte), stats *BuildStats) error {
+	scanner := bufio.NewScanner(in)
+	var line uint64
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		sum, count, err := ParseLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		if count < minCount {
+			stats.Skipped++
+			continue
+		}
+		fn(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取输入失败: %w", err)
+	}
+	stats.Lines = line
+	return nil
+}
+
+// bloomIndexes 计算 SHA-1 在位数组中的 k 个位置
+func bloomIndexes(sum [sha1.Size]byte, k uint32, m uint64) []uint64 {
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	indexes := make([]uint64, k)
+	for i := range indexes {
+		indexes[i] = (h1 + uint64(i)*h2) % m
+	}
+	return indexes
+}
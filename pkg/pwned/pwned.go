@@ -0,0 +1,104 @@
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"auth-service/internal/config"
+)
+
+// 泄露密码库格式
+const (
+	SourceRange = "range" // 按 SHA-1 前5位分片的目录：<目录>/<前缀>.txt，每行 <后35位>:<出现次数>，与 HIBP range 接口的响应一致
+	SourceBloom = "bloom" // 由 authctl build-pwned-bloom 生成的布隆过滤器文件
+)
+
+// Checker 泄露密码检查器
+type Checker interface {
+	// Breached 判断密码是否出现在泄露密码库中
+	Breached(password string) (bool, error)
+}
+
+// Open 根据配置打开泄露密码库，未配置时返回 nil
+func Open(cfg *config.BreachConfig) (Checker, error) {
+	switch cfg.Source {
+	case "":
+		return nil, nil
+	case SourceRange:
+		info, err := os.Stat(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("打开泄露密码库失败: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("泄露密码库路径不是目录: %s", cfg.Path)
+		}
+		return &RangeChecker{dir: cfg.Path, minCount: cfg.MinCount}, nil
+	case SourceBloom:
+		return OpenBloom(cfg.Path)
+	}
+	return nil, fmt.Errorf("不支持的泄露密码库格式: %q", cfg.Source)
+}
+
+// RangeChecker 基于按前缀分片的文本文件的检查器，每次检查只读取一个分片
+type RangeChecker struct {
+	dir      string
+	minCount int
+}
+
+// Breached 判断密码是否出现在泄露密码库中（出现次数不少于 minCount）
+func (c *RangeChecker) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	f, err := os.Open(filepath.Join(c.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		// 完整的数据集包含全部前缀，缺少分片说明该前缀下没有泄露的密码
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("读取泄露密码库分片失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hashSuffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(hashSuffix, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return false, fmt.Errorf("泄露密码库分片 %s 格式错误: %w", prefix, err)
+		}
+		return n >= c.minCount, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("读取泄露密码库分片失败: %w", err)
+	}
+	return false, nil
+}
+
+// ParseLine 解析下载的泄露密码库中的一行：<40位 SHA-1>:<出现次数>
+func ParseLine(line string) ([sha1.Size]byte, int, error) {
+	var sum [sha1.Size]byte
+	hash, count, ok := strings.Cut(strings.TrimSpace(line), ":")
+	if !ok || len(hash) != 2*sha1.Size {
+		return sum, 0, errors.New("格式应为 <SHA-1>:<出现次数>")
+	}
+	if _, err := hex.Decode(sum[:], []byte(hash)); err != nil {
+		return sum, 0, fmt.Errorf("SHA-1 无效: %w", err)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return sum, 0, fmt.Errorf("出现次数无效: %w", err)
+	}
+	return sum, n, nil
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval 清理过期记录的最小间隔
+const sweepInterval = time.Minute
+
+// MemoryLimiter 进程内限流器，计数不在实例之间共享
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryLimiter 创建进程内限流器
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		tats: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// Allow 为 key 消耗一次配额
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	tat, result := gcra(now, l.tats[key], limit)
+	if result.Allowed {
+		l.tats[key] = tat
+	}
+	return result, nil
+}
+
+// sweep 删除理论到达时间已过去的记录（配额已完全恢复，与没有记录等价）
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	for key, tat := range l.tats {
+		if !tat.After(now) {
+			delete(l.tats, key)
+		}
+	}
+	l.lastSweep = now
+}
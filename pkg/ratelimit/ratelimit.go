@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/redis"
+)
+
+// 限流存储后端
+const (
+	BackendRedis  = "redis"  // 多实例共享计数
+	BackendMemory = "memory" // 仅当前进程内有效，用于单实例部署和测试
+)
+
+// Limit 限流参数：平均每 Period 允许 Limit 次请求，最多允许连续 Burst 次突发
+type Limit struct {
+	Limit  int
+	Period time.Duration
+	Burst  int // 0 表示等于 Limit
+}
+
+// Result 限流检查结果
+type Result struct {
+	Allowed    bool
+	Limit      int           // 配额（突发上限）
+	Remaining  int           // 剩余可立即发起的请求数
+	RetryAfter time.Duration // 被拒绝时，多久之后可以重试
+	ResetAfter time.Duration // 多久之后配额完全恢复
+}
+
+// Limiter 限流器，使用 GCRA（通用信元速率算法）：每个键只保存一个“理论到达时间”
+type Limiter interface {
+	// Allow 为 key 消耗一次配额，返回是否允许本次请求
+	Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// New 根据配置创建限流器
+func New(cfg *config.RateLimitConfig, redisClient *redis.Client) (Limiter, error) {
+	switch cfg.Backend {
+	case BackendRedis, "":
+		return NewRedisLimiter(redisClient), nil
+	case BackendMemory:
+		return NewMemoryLimiter(), nil
+	}
+	return nil, fmt.Errorf("不支持的限流存储后端: %q", cfg.Backend)
+}
+
+// emissionInterval 返回两次请求之间的平均间隔和允许的突发容差
+func (l Limit) emissionInterval() (interval, tolerance time.Duration, burst int) {
+	burst = l.Burst
+	if burst <= 0 {
+		burst = l.Limit
+	}
+	interval = l.Period / time.Duration(l.Limit)
+	return interval, interval * time.Duration(burst), burst
+}
+
+// gcra 计算一次请求的结果，tat 为上次保存的理论到达时间（零值表示没有记录）
+// 允许时返回新的理论到达时间，拒绝时 tat 不变
+func gcra(now, tat time.Time, l Limit) (time.Time, *Result) {
+	interval, tolerance, burst := l.emissionInterval()
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTAT := tat.Add(interval)
+	allowAt := newTAT.Add(-tolerance)
+	if now.Before(allowAt) {
+		return tat, &Result{
+			Allowed:    false,
+			Limit:      burst,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}
+	}
+	return newTAT, &Result{
+		Allowed:    true,
+		Limit:      burst,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTAT.Sub(now),
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"auth-service/pkg/redis"
+)
+
+// gcraScript 原子地执行 GCRA 检查，使用 Redis 服务器时间避免各实例时钟不一致
+// KEYS[1] 理论到达时间（微秒）；ARGV[1] 请求间隔（微秒），ARGV[2] 突发容差（微秒）
+// 返回 {是否允许, 剩余次数, 重试等待（微秒）, 配额恢复时间（微秒）}
+const gcraScript = `
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - tolerance
+if now < allow_at then
+	return {0, 0, allow_at - now, tat - now}
+end
+redis.call('SET', KEYS[1], string.format('%.0f', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`
+
+// RedisLimiter 基于 Redis 的限流器，多个服务实例共享计数
+type RedisLimiter struct {
+	redisClient *redis.Client
+}
+
+// NewRedisLimiter 创建基于 Redis 的限流器
+func NewRedisLimiter(redisClient *redis.Client) *RedisLimiter {
+	return &RedisLimiter{redisClient: redisClient}
+}
+
+// Allow 为 key 消耗一次配额
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	interval, tolerance, burst := limit.emissionInterval()
+	reply, err := l.redisClient.Eval(ctx, gcraScript, []string{"ratelimit:" + key},
+		interval.Microseconds(), tolerance.Microseconds())
+	if err != nil {
+		return nil, fmt.Errorf("执行限流脚本失败: %w", err)
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, errors.New("限流脚本返回值格式错误")
+	}
+	nums := make([]int64, len(values))
+	for i, v := range values {
+		if nums[i], ok = v.(int64); !ok {
+			return nil, errors.New("限流脚本返回值格式错误")
+		}
+	}
+
+	return &Result{
+		Allowed:    nums[0] == 1,
+		Limit:      burst,
+		Remaining:  int(nums[1]),
+		RetryAfter: time.Duration(nums[2]) * time.Microsecond,
+		ResetAfter: time.Duration(nums[3]) * time.Microsecond,
+	}, nil
+}
@@ -9,6 +9,9 @@ import (
 	"auth-service/internal/config"
 )
 
+// Nil 键不存在时返回的错误
+const Nil = redis.Nil
+
 // Client Redis 客户端包装器
 type Client struct {
 	rdb    *redis.Client
@@ -62,6 +65,57 @@ func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
 	return result > 0, err
 }
 
+// SetNX 仅当键不存在时设置键值对，返回是否设置成功
+func (c *Client) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	fullKey := c.prefix + key
+	return c.rdb.SetNX(ctx, fullKey, value, expiration).Result()
+}
+
+// Expire 设置键的过期时间
+func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	fullKey := c.prefix + key
+	return c.rdb.Expire(ctx, fullKey, expiration).Err()
+}
+
+// TTL 获取键的剩余有效期，键不存在时返回 -2，未设置过期时间时返回 -1
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	fullKey := c.prefix + key
+	return c.rdb.PTTL(ctx, fullKey).Result()
+}
+
+// Incr 将键的整数值加一，返回加一后的值
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	fullKey := c.prefix + key
+	return c.rdb.Incr(ctx, fullKey).Result()
+}
+
+// SAdd 向集合添加成员
+func (c *Client) SAdd(ctx context.Context, key string, members ...interface{}) error {
+	fullKey := c.prefix + key
+	return c.rdb.SAdd(ctx, fullKey, members...).Err()
+}
+
+// SMembers 获取集合全部成员
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	fullKey := c.prefix + key
+	return c.rdb.SMembers(ctx, fullKey).Result()
+}
+
+// SRem 从集合移除成员
+func (c *Client) SRem(ctx context.Context, key string, members ...interface{}) error {
+	fullKey := c.prefix + key
+	return c.rdb.SRem(ctx, fullKey, members...).Err()
+}
+
+// Eval 执行 Lua 脚本（脚本内的操作是原子的），keys 会自动添加前缀
+func (c *Client) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = c.prefix + key
+	}
+	return c.rdb.Eval(ctx, script, fullKeys, args...).Result()
+}
+
 // Close 关闭连接
 func (c *Client) Close() error {
 	return c.rdb.Close()
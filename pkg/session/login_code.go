@@ -0,0 +1,84 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"auth-service/pkg/redis"
+)
+
+// LoginCodeTTL 一次性登录码的有效期，前端在登录成功页面应立即兑换
+const LoginCodeTTL = time.Minute
+
+// ErrLoginCodeInvalid 登录码不存在、已过期或已被兑换
+var ErrLoginCodeInvalid = errors.New("登录码无效或已过期，请重新登录")
+
+// consumeLoginCodeScript 原子地读取并删除登录码，保证只能兑换一次
+const consumeLoginCodeScript = `
+local value = redis.call('GET', KEYS[1])
+if value then
+	redis.call('DEL', KEYS[1])
+end
+return value
+`
+
+// LoginCode 外部账号登录成功后签发的一次性登录码
+// 重定向地址中只携带登录码，前端再凭登录码换取令牌，令牌不会出现在浏览器历史、Referer 和访问日志中
+type LoginCode struct {
+	UserID     uint      `json:"user_id"`
+	AuthMethod string    `json:"auth_method"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateLoginCode 保存登录信息，返回一次性登录码
+func (m *Manager) CreateLoginCode(ctx context.Context, login LoginCode) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成登录码失败: %w", err)
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+	login.CreatedAt = time.Now()
+
+	loginJSON, err := json.Marshal(login)
+	if err != nil {
+		return "", fmt.Errorf("序列化登录信息失败: %w", err)
+	}
+	if err := m.redisClient.Set(ctx, loginCodeKey(code), string(loginJSON), LoginCodeTTL); err != nil {
+		return "", fmt.Errorf("存储登录码到 Redis 失败: %w", err)
+	}
+	return code, nil
+}
+
+// ConsumeLoginCode 兑换登录码（一次性使用）
+func (m *Manager) ConsumeLoginCode(ctx context.Context, code string) (*LoginCode, error) {
+	if code == "" {
+		return nil, ErrLoginCodeInvalid
+	}
+	reply, err := m.redisClient.Eval(ctx, consumeLoginCodeScript, []string{loginCodeKey(code)})
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrLoginCodeInvalid
+		}
+		return nil, fmt.Errorf("兑换登录码失败: %w", err)
+	}
+	loginJSON, ok := reply.(string)
+	if !ok {
+		return nil, ErrLoginCodeInvalid
+	}
+
+	var login LoginCode
+	if err := json.Unmarshal([]byte(loginJSON), &login); err != nil {
+		return nil, fmt.Errorf("解析登录信息失败: %w", err)
+	}
+	return &login, nil
+}
+
+func loginCodeKey(code string) string {
+	return fmt.Sprintf("oauth2:login_code:%s", code)
+}
@@ -18,10 +18,14 @@ type Manager struct {
 
 // OAuth2State OAuth2 状态信息
 type OAuth2State struct {
-	State     string    `json:"state"`
-	CreatedAt time.Time `json:"created_at"`
-	UserAgent string    `json:"user_agent,omitempty"`
-	ClientIP  string    `json:"client_ip,omitempty"`
+	State        string    `json:"state"`
+	Provider     string    `json:"provider,omitempty"`      // 发起登录的提供方，回调时必须一致
+	Nonce        string    `json:"nonce,omitempty"`         // OpenID Connect nonce，回调时与 ID Token 中的值比对
+	CodeVerifier string    `json:"code_verifier,omitempty"` // PKCE 验证码，交换授权码时提交
+	LinkUserID   uint      `json:"link_user_id,omitempty"`  // 非零时为已登录用户关联外部账号，回调时不登录
+	CreatedAt    time.Time `json:"created_at"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	ClientIP     string    `json:"client_ip,omitempty"`
 }
 
 // NewManager 创建 Session 管理器
@@ -31,18 +35,11 @@ func NewManager(redisClient *redis.Client) *Manager {
 	}
 }
 
-// CreateOAuth2Session 创建 OAuth2 会话
-func (m *Manager) CreateOAuth2Session(ctx context.Context, state, userAgent, clientIP string) (string, error) {
+// CreateOAuth2Session 创建 OAuth2 会话，创建时间由本方法填充
+func (m *Manager) CreateOAuth2Session(ctx context.Context, stateInfo OAuth2State) (string, error) {
 	// 生成唯一的 session ID
 	sessionID := uuid.New().String()
-
-	// 创建状态信息
-	stateInfo := OAuth2State{
-		State:     state,
-		CreatedAt: time.Now(),
-		UserAgent: userAgent,
-		ClientIP:  clientIP,
-	}
+	stateInfo.CreatedAt = time.Now()
 
 	// 序列化为 JSON
 	stateJSON, err := json.Marshal(stateInfo)
@@ -93,6 +90,53 @@ func (m *Manager) DeleteOAuth2Session(ctx context.Context, sessionID string) err
 	return m.redisClient.Del(ctx, key)
 }
 
+// PendingLink 待确认的外部账号关联：外部账号登录时邮箱与已有用户一致，需要该用户登录后确认
+type PendingLink struct {
+	UserID    uint            `json:"user_id"` // 邮箱一致的已有用户
+	Provider  string          `json:"provider"`
+	Email     string          `json:"email"`
+	Account   json.RawMessage `json:"account"` // 外部账号信息
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// CreatePendingLink 保存待确认的外部账号关联，返回确认ID，10 分钟内有效
+func (m *Manager) CreatePendingLink(ctx context.Context, link PendingLink) (string, error) {
+	linkID := uuid.New().String()
+	link.CreatedAt = time.Now()
+
+	linkJSON, err := json.Marshal(link)
+	if err != nil {
+		return "", fmt.Errorf("序列化待确认关联失败: %w", err)
+	}
+
+	key := fmt.Sprintf("oauth2:pending_link:%s", linkID)
+	if err := m.redisClient.Set(ctx, key, string(linkJSON), 10*time.Minute); err != nil {
+		return "", fmt.Errorf("存储待确认关联到 Redis 失败: %w", err)
+	}
+	return linkID, nil
+}
+
+// GetPendingLink 查询待确认的外部账号关联
+func (m *Manager) GetPendingLink(ctx context.Context, linkID string) (*PendingLink, error) {
+	key := fmt.Sprintf("oauth2:pending_link:%s", linkID)
+	linkJSON, err := m.redisClient.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("获取待确认关联失败: %w", err)
+	}
+
+	var link PendingLink
+	if err := json.Unmarshal([]byte(linkJSON), &link); err != nil {
+		return nil, fmt.Errorf("解析待确认关联失败: %w", err)
+	}
+	return &link, nil
+}
+
+// DeletePendingLink 删除待确认的外部账号关联（一次性使用）
+func (m *Manager) DeletePendingLink(ctx context.Context, linkID string) error {
+	key := fmt.Sprintf("oauth2:pending_link:%s", linkID)
+	return m.redisClient.Del(ctx, key)
+}
+
 // CleanupExpiredSessions 清理过期会话（可以通过定时任务调用）
 func (m *Manager) CleanupExpiredSessions(ctx context.Context) error {
 	// Redis 会自动处理过期的键，这里可以添加额外的清理逻辑
@@ -0,0 +1,117 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"auth-service/pkg/redis"
+)
+
+// MaxMFAAttempts 每个两步验证挑战允许提交第二因子的最大次数
+const MaxMFAAttempts = 5
+
+// 两步验证挑战相关错误
+var (
+	ErrMFAChallengeNotFound  = errors.New("两步验证已过期，请重新登录")
+	ErrMFAChallengeExhausted = errors.New("验证码错误次数过多，请重新登录")
+)
+
+// MFAChallenge 两步验证挑战：第一因子（密码或外部账号）验证通过、等待提交第二因子的登录
+type MFAChallenge struct {
+	UserID     uint      `json:"user_id"`
+	AuthMethod string    `json:"auth_method,omitempty"` // 第一因子的登录方式，为空表示密码
+	DeviceName string    `json:"device_name,omitempty"`
+	DeviceID   string    `json:"device_id,omitempty"` // 登录请求携带的设备标识，第二因子验证通过后才信任该设备
+	ClientIP   string    `json:"client_ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CompleteAuthMethod 第二因子验证通过后的登录方式，如 password+totp、github+webauthn
+func (c *MFAChallenge) CompleteAuthMethod(secondFactor string) string {
+	first := c.AuthMethod
+	if first == "" {
+		first = AuthMethodPassword
+	}
+	return first + "+" + secondFactor
+}
+
+// CreateMFAChallenge 创建两步验证挑战，返回一次性的挑战令牌；创建时间由本方法填充
+func (m *Manager) CreateMFAChallenge(ctx context.Context, challenge MFAChallenge, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成挑战令牌失败: %w", err)
+	}
+	challengeToken := base64.RawURLEncoding.EncodeToString(b)
+	challenge.CreatedAt = time.Now()
+
+	challengeJSON, err := json.Marshal(challenge)
+	if err != nil {
+		return "", fmt.Errorf("序列化挑战信息失败: %w", err)
+	}
+
+	if err := m.redisClient.Set(ctx, mfaChallengeKey(challengeToken), string(challengeJSON), ttl); err != nil {
+		return "", fmt.Errorf("存储挑战到 Redis 失败: %w", err)
+	}
+	return challengeToken, nil
+}
+
+// GetMFAChallenge 查询两步验证挑战（不计入尝试次数）
+func (m *Manager) GetMFAChallenge(ctx context.Context, challengeToken string) (*MFAChallenge, error) {
+	challengeJSON, err := m.redisClient.Get(ctx, mfaChallengeKey(challengeToken))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrMFAChallengeNotFound
+		}
+		return nil, fmt.Errorf("获取挑战信息失败: %w", err)
+	}
+
+	var challenge MFAChallenge
+	if err := json.Unmarshal([]byte(challengeJSON), &challenge); err != nil {
+		return nil, fmt.Errorf("解析挑战信息失败: %w", err)
+	}
+	return &challenge, nil
+}
+
+// AttemptMFAChallenge 查询两步验证挑战并记录一次提交，超过最大次数后挑战作废
+func (m *Manager) AttemptMFAChallenge(ctx context.Context, challengeToken string, ttl time.Duration) (*MFAChallenge, error) {
+	challenge, err := m.GetMFAChallenge(ctx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// 计数键不晚于挑战过期，避免挑战过期后计数残留
+	attemptsKey := mfaAttemptsKey(challengeToken)
+	attempts, err := m.redisClient.Incr(ctx, attemptsKey)
+	if err != nil {
+		return nil, fmt.Errorf("记录验证次数失败: %w", err)
+	}
+	if attempts == 1 {
+		if err := m.redisClient.Expire(ctx, attemptsKey, ttl); err != nil {
+			return nil, fmt.Errorf("设置验证次数过期时间失败: %w", err)
+		}
+	}
+	if attempts > MaxMFAAttempts {
+		_ = m.DeleteMFAChallenge(ctx, challengeToken)
+		return nil, ErrMFAChallengeExhausted
+	}
+
+	return challenge, nil
+}
+
+// DeleteMFAChallenge 删除两步验证挑战（验证通过后一次性使用）
+func (m *Manager) DeleteMFAChallenge(ctx context.Context, challengeToken string) error {
+	return m.redisClient.Del(ctx, mfaChallengeKey(challengeToken), mfaAttemptsKey(challengeToken))
+}
+
+func mfaChallengeKey(challengeToken string) string {
+	return fmt.Sprintf("mfa:challenge:%s", challengeToken)
+}
+
+func mfaAttemptsKey(challengeToken string) string {
+	return fmt.Sprintf("mfa:attempts:%s", challengeToken)
+}
@@ -0,0 +1,230 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth-service/pkg/redis"
+)
+
+// 登录方式
+const (
+	AuthMethodPassword         = "password"
+	AuthMethodPasswordTOTP     = "password+totp"     // 密码 + TOTP 两步验证
+	AuthMethodPasswordWebAuthn = "password+webauthn" // 密码 + 安全密钥/通行密钥
+	AuthMethodPasskey          = "passkey"           // 通行密钥免密登录
+	AuthMethodGitHub           = "github"
+
+	// 第二因子，与第一因子组合为 password+totp、github+webauthn 等
+	SecondFactorTOTP     = "totp"
+	SecondFactorWebAuthn = "webauthn"
+)
+
+// ErrSessionNotFound 会话不存在或已被吊销
+var ErrSessionNotFound = errors.New("会话不存在或已失效")
+
+// UserSession 用户登录会话（设备）
+// 会话ID同时作为刷新令牌的令牌族ID，吊销会话即吊销该设备上的全部令牌
+type UserSession struct {
+	ID         string    `json:"id"`
+	UserID     uint      `json:"user_id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	AuthMethod string    `json:"auth_method"` // 登录方式：password, github 等
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	LastSeenIP string    `json:"last_seen_ip,omitempty"`
+}
+
+// CreateUserSession 创建用户登录会话
+func (m *Manager) CreateUserSession(ctx context.Context, userID uint, deviceName, userAgent, clientIP, authMethod string, ttl time.Duration) (*UserSession, error) {
+	if deviceName == "" {
+		deviceName = DeviceNameFromUserAgent(userAgent)
+	}
+
+	now := time.Now()
+	s := &UserSession{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		ClientIP:   clientIP,
+		AuthMethod: authMethod,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		LastSeenIP: clientIP,
+	}
+
+	if err := m.saveUserSession(ctx, s, ttl); err != nil {
+		return nil, err
+	}
+
+	// 维护用户的会话索引
+	if err := m.redisClient.SAdd(ctx, userSessionsKey(userID), s.ID); err != nil {
+		return nil, fmt.Errorf("存储会话索引失败: %w", err)
+	}
+
+	return s, nil
+}
+
+// GetUserSession 查询用户登录会话
+func (m *Manager) GetUserSession(ctx context.Context, sessionID string) (*UserSession, error) {
+	sessionJSON, err := m.redisClient.Get(ctx, userSessionKey(sessionID))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("获取会话信息失败: %w", err)
+	}
+
+	var s UserSession
+	if err := json.Unmarshal([]byte(sessionJSON), &s); err != nil {
+		return nil, fmt.Errorf("解析会话信息失败: %w", err)
+	}
+	return &s, nil
+}
+
+// TouchUserSession 更新会话最近活跃时间和IP，并延长会话有效期
+func (m *Manager) TouchUserSession(ctx context.Context, sessionID, clientIP string, ttl time.Duration) error {
+	s, err := m.GetUserSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	s.LastSeenAt = time.Now()
+	s.LastSeenIP = clientIP
+	return m.saveUserSession(ctx, s, ttl)
+}
+
+// ListUserSessions 查询用户的全部有效会话，按最近活跃时间倒序
+func (m *Manager) ListUserSessions(ctx context.Context, userID uint) ([]*UserSession, error) {
+	ids, err := m.redisClient.SMembers(ctx, userSessionsKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("获取会话索引失败: %w", err)
+	}
+
+	sessions := make([]*UserSession, 0, len(ids))
+	for _, id := range ids {
+		s, err := m.GetUserSession(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrSessionNotFound) {
+				// 会话已过期，清理索引
+				_ = m.redisClient.SRem(ctx, userSessionsKey(userID), id)
+				continue
+			}
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// DeleteUserSession 删除用户会话
+func (m *Manager) DeleteUserSession(ctx context.Context, userID uint, sessionID string) error {
+	if err := m.redisClient.Del(ctx, userSessionKey(sessionID)); err != nil {
+		return fmt.Errorf("删除会话失败: %w", err)
+	}
+	if err := m.redisClient.SRem(ctx, userSessionsKey(userID), sessionID); err != nil {
+		return fmt.Errorf("删除会话索引失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteAllUserSessions 删除用户的全部会话，返回被删除的会话ID
+func (m *Manager) DeleteAllUserSessions(ctx context.Context, userID uint) ([]string, error) {
+	ids, err := m.redisClient.SMembers(ctx, userSessionsKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("获取会话索引失败: %w", err)
+	}
+
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, userSessionKey(id))
+	}
+	keys = append(keys, userSessionsKey(userID))
+
+	if err := m.redisClient.Del(ctx, keys...); err != nil {
+		return nil, fmt.Errorf("删除会话失败: %w", err)
+	}
+	return ids, nil
+}
+
+// saveUserSession 存储会话信息
+func (m *Manager) saveUserSession(ctx context.Context, s *UserSession, ttl time.Duration) error {
+	sessionJSON, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("序列化会话信息失败: %w", err)
+	}
+	if err := m.redisClient.Set(ctx, userSessionKey(s.ID), string(sessionJSON), ttl); err != nil {
+		return fmt.Errorf("存储会话到 Redis 失败: %w", err)
+	}
+	return nil
+}
+
+// DeviceNameFromUserAgent 根据 User-Agent 推断设备名称，如 "Chrome on macOS"
+func DeviceNameFromUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "未知设备"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	// 顺序很重要：Edge 和 Opera 的 UA 中同样包含 Chrome，Chrome 的 UA 中同样包含 Safari
+	browser := "浏览器"
+	for _, b := range []struct{ token, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"okhttp", "Android App"},
+		{"cfnetwork", "iOS App"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	os := ""
+	for _, o := range []struct{ token, name string }{
+		{"iphone", "iPhone"},
+		{"ipad", "iPad"},
+		{"android", "Android"},
+		{"windows", "Windows"},
+		{"mac os x", "macOS"},
+		{"macintosh", "macOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			os = o.name
+			break
+		}
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
+
+func userSessionKey(sessionID string) string {
+	return fmt.Sprintf("user:session:%s", sessionID)
+}
+
+func userSessionsKey(userID uint) string {
+	return fmt.Sprintf("user:sessions:%d", userID)
+}
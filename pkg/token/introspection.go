@@ -0,0 +1,158 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"auth-service/pkg/jwt"
+)
+
+// 令牌类型提示（RFC 7009 / RFC 7662）
+const (
+	HintAccessToken  = "access_token"
+	HintRefreshToken = "refresh_token"
+)
+
+// Introspection 令牌内省结果（RFC 7662 2.2）
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+}
+
+// Introspect 查询令牌状态，已过期、已吊销或无法识别的令牌均返回 active=false
+// hint 仅用于优化查找顺序，按提示查找失败或提示无法识别时会尝试全部类型（RFC 7662 2.1）
+func (m *Manager) Introspect(ctx context.Context, tokenString, hint string) (*Introspection, error) {
+	lookups := []func(context.Context, string) (*Introspection, error){m.introspectAccessToken, m.introspectRefreshToken}
+	if hint == HintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		result, err := lookup(ctx, tokenString)
+		if err != nil {
+			return nil, err
+		}
+		if result.Active {
+			return result, nil
+		}
+	}
+	return &Introspection{Active: false}, nil
+}
+
+// Revoke 资源服务器客户端吊销令牌（RFC 7009），无效的令牌视为吊销成功
+// 两种令牌格式可直接区分，因此不需要类型提示。
+// RFC 7009 2.1 要求令牌必须是签发给请求方的：只能吊销 aud 包含 clientID 的访问令牌；
+// 刷新令牌只签发给本服务的前端，资源服务器无权吊销，持有者通过注销接口吊销。不满足时返回 ErrRevokeNotAllowed
+func (m *Manager) Revoke(ctx context.Context, tokenString, clientID string) error {
+	// 访问令牌：签名有效时吊销其 jti
+	if claims, err := jwt.ParseToken(tokenString, m.keys); err == nil {
+		if !slices.Contains(claims.Audience, clientID) {
+			return ErrRevokeNotAllowed
+		}
+		return m.RevokeAccessToken(ctx, claims)
+	}
+
+	// 刷新令牌
+	if _, err := m.getRefreshRecord(ctx, hashToken(tokenString)); err != nil {
+		if errors.Is(err, ErrRefreshTokenInvalid) {
+			return nil
+		}
+		return err
+	}
+	return ErrRevokeNotAllowed
+}
+
+// introspectAccessToken 内省访问令牌
+func (m *Manager) introspectAccessToken(ctx context.Context, tokenString string) (*Introspection, error) {
+	// 签名或有效期校验失败视为无效令牌
+	claims, err := jwt.ParseToken(tokenString, m.keys)
+	if err != nil {
+		return &Introspection{Active: false}, nil
+	}
+	if err := m.checkRevocation(ctx, claims); err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			return &Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	result := &Introspection{
+		Active:    true,
+		Scope:     claims.Scope,
+		Username:  claims.Username,
+		TokenType: "Bearer",
+		Sub:       strconv.FormatUint(uint64(claims.UserID), 10),
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		result.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		result.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		result.Nbf = claims.NotBefore.Unix()
+	}
+	return result, nil
+}
+
+// introspectRefreshToken 内省刷新令牌
+func (m *Manager) introspectRefreshToken(ctx context.Context, tokenString string) (*Introspection, error) {
+	tokenHash := hashToken(tokenString)
+
+	record, err := m.getRefreshRecord(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenInvalid) {
+			return &Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	// 已轮换过的刷新令牌不再有效
+	used, err := m.redisClient.Exists(ctx, usedKey(tokenHash))
+	if err != nil {
+		return nil, fmt.Errorf("查询刷新令牌状态失败: %w", err)
+	}
+	if used {
+		return &Introspection{Active: false}, nil
+	}
+
+	fam, err := m.getFamily(ctx, record.FamilyID)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenInvalid) {
+			return &Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+	generation, err := m.currentGeneration(ctx, record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if fam.Generation < generation {
+		return &Introspection{Active: false}, nil
+	}
+
+	return &Introspection{
+		Active:    true,
+		Scope:     m.config.Scope,
+		Username:  record.Username,
+		TokenType: HintRefreshToken,
+		Exp:       record.ExpiresAt.Unix(),
+		Iat:       record.IssuedAt.Unix(),
+		Sub:       strconv.FormatUint(uint64(record.UserID), 10),
+	}, nil
+}
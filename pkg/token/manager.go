@@ -0,0 +1,268 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/jwt"
+	"auth-service/pkg/redis"
+)
+
+// 刷新令牌相关错误
+var (
+	ErrRefreshTokenInvalid = errors.New("刷新令牌无效或已过期")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，令牌族已吊销")
+)
+
+// Pair 访问令牌与刷新令牌对
+type Pair struct {
+	SessionID        string `json:"session_id"` // 登录会话ID（令牌族ID）
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`         // 访问令牌剩余秒数
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // 刷新令牌剩余秒数
+}
+
+// refreshRecord 刷新令牌在 Redis 中的记录
+type refreshRecord struct {
+	FamilyID  string    `json:"family_id"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// family 令牌族信息：同一次登录轮换出的所有刷新令牌属于同一族
+type family struct {
+	UserID     uint      `json:"user_id"`
+	Generation int64     `json:"generation"` // 创建时的用户令牌代数
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Manager 令牌管理器：签发访问令牌，管理刷新令牌的轮换与重用检测
+type Manager struct {
+	config      *config.JWTConfig
+	keys        jwt.KeyProvider
+	redisClient *redis.Client
+}
+
+// NewManager 创建令牌管理器
+func NewManager(cfg *config.JWTConfig, keys jwt.KeyProvider, redisClient *redis.Client) *Manager {
+	return &Manager{
+		config:      cfg,
+		keys:        keys,
+		redisClient: redisClient,
+	}
+}
+
+// Issue 为一次新的登录签发令牌对（创建新的令牌族）
+// sessionID 为登录会话ID，同时作为令牌族ID；为空时自动生成
+func (m *Manager) Issue(ctx context.Context, userID uint, username, sessionID string) (*Pair, error) {
+	familyID := sessionID
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	generation, err := m.currentGeneration(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	familyJSON, err := json.Marshal(family{
+		UserID:     userID,
+		Generation: generation,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化令牌族失败: %w", err)
+	}
+
+	if err := m.redisClient.Set(ctx, familyKey(familyID), string(familyJSON), m.config.RefreshTokenTTL); err != nil {
+		return nil, fmt.Errorf("存储令牌族失败: %w", err)
+	}
+
+	return m.issuePair(ctx, familyID, generation, userID, username)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对
+// 每个刷新令牌只能使用一次；已使用过的令牌再次出现时，视为令牌泄露，吊销整个令牌族
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (*Pair, error) {
+	if refreshToken == "" {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	tokenHash := hashToken(refreshToken)
+
+	// 1. 查询刷新令牌记录
+	record, err := m.getRefreshRecord(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 原子地标记为已使用，失败说明令牌被重用
+	firstUse, err := m.redisClient.SetNX(ctx, usedKey(tokenHash), record.FamilyID, m.config.RefreshTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("标记刷新令牌失败: %w", err)
+	}
+	if !firstUse {
+		if err := m.RevokeFamily(ctx, record.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	// 3. 检查令牌族是否仍然有效（未被吊销，且不早于用户最近一次注销全部设备）
+	fam, err := m.getFamily(ctx, record.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	generation, err := m.currentGeneration(ctx, record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if fam.Generation < generation {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	// 4. 延长令牌族有效期并签发新的令牌对
+	if err := m.redisClient.Expire(ctx, familyKey(record.FamilyID), m.config.RefreshTokenTTL); err != nil {
+		return nil, fmt.Errorf("延长令牌族有效期失败: %w", err)
+	}
+
+	return m.issuePair(ctx, record.FamilyID, fam.Generation, record.UserID, record.Username)
+}
+
+// RefreshTokenOwner 查询刷新令牌所属的用户，不标记为已使用，供刷新前检查账号状态
+func (m *Manager) RefreshTokenOwner(ctx context.Context, refreshToken string) (uint, error) {
+	if refreshToken == "" {
+		return 0, ErrRefreshTokenInvalid
+	}
+	record, err := m.getRefreshRecord(ctx, hashToken(refreshToken))
+	if err != nil {
+		return 0, err
+	}
+	return record.UserID, nil
+}
+
+// RevokeFamily 吊销整个令牌族，该族下的所有刷新令牌立即失效
+func (m *Manager) RevokeFamily(ctx context.Context, familyID string) error {
+	if err := m.redisClient.Del(ctx, familyKey(familyID)); err != nil {
+		return fmt.Errorf("吊销令牌族失败: %w", err)
+	}
+	return nil
+}
+
+// getFamily 查询令牌族，不存在时返回 ErrRefreshTokenInvalid
+func (m *Manager) getFamily(ctx context.Context, familyID string) (*family, error) {
+	familyJSON, err := m.redisClient.Get(ctx, familyKey(familyID))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, fmt.Errorf("查询令牌族失败: %w", err)
+	}
+
+	var fam family
+	if err := json.Unmarshal([]byte(familyJSON), &fam); err != nil {
+		return nil, fmt.Errorf("解析令牌族失败: %w", err)
+	}
+	return &fam, nil
+}
+
+// getRefreshRecord 查询刷新令牌记录，不存在时返回 ErrRefreshTokenInvalid
+func (m *Manager) getRefreshRecord(ctx context.Context, tokenHash string) (*refreshRecord, error) {
+	recordJSON, err := m.redisClient.Get(ctx, refreshKey(tokenHash))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, fmt.Errorf("获取刷新令牌失败: %w", err)
+	}
+
+	var record refreshRecord
+	if err := json.Unmarshal([]byte(recordJSON), &record); err != nil {
+		return nil, fmt.Errorf("解析刷新令牌失败: %w", err)
+	}
+	return &record, nil
+}
+
+// issuePair 在指定令牌族下签发访问令牌和新的刷新令牌
+func (m *Manager) issuePair(ctx context.Context, familyID string, generation int64, userID uint, username string) (*Pair, error) {
+	claims := jwt.NewClaims(userID, username, generation, familyID)
+	claims.Scope = m.config.Scope
+	claims.Audience = m.config.Audience
+
+	accessToken, err := jwt.GenerateToken(claims, m.keys, m.config.AccessTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("生成访问令牌失败: %w", err)
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("生成刷新令牌失败: %w", err)
+	}
+
+	now := time.Now()
+	recordJSON, err := json.Marshal(refreshRecord{
+		FamilyID:  familyID,
+		UserID:    userID,
+		Username:  username,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(m.config.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化刷新令牌失败: %w", err)
+	}
+
+	// 只存储令牌的哈希值，Redis 泄露时无法直接使用
+	if err := m.redisClient.Set(ctx, refreshKey(hashToken(refreshToken)), string(recordJSON), m.config.RefreshTokenTTL); err != nil {
+		return nil, fmt.Errorf("存储刷新令牌失败: %w", err)
+	}
+
+	return &Pair{
+		SessionID:        familyID,
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(m.config.AccessTokenTTL.Seconds()),
+		RefreshExpiresIn: int64(m.config.RefreshTokenTTL.Seconds()),
+	}, nil
+}
+
+// generateOpaqueToken 生成不透明的随机令牌
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken 计算令牌的 SHA-256 哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func refreshKey(tokenHash string) string {
+	return fmt.Sprintf("token:refresh:%s", tokenHash)
+}
+
+func usedKey(tokenHash string) string {
+	return fmt.Sprintf("token:refresh_used:%s", tokenHash)
+}
+
+func familyKey(familyID string) string {
+	return fmt.Sprintf("token:family:%s", familyID)
+}
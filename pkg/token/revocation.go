@@ -0,0 +1,133 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"auth-service/pkg/jwt"
+	"auth-service/pkg/redis"
+)
+
+// 访问令牌吊销相关错误
+var (
+	ErrTokenRevoked     = errors.New("令牌已被吊销")
+	ErrRevokeNotAllowed = errors.New("令牌不是签发给该客户端的，无权吊销")
+)
+
+// ValidateAccessToken 解析访问令牌并检查服务端吊销状态
+func (m *Manager) ValidateAccessToken(ctx context.Context, tokenString string) (*jwt.Claims, error) {
+	claims, err := jwt.ParseToken(tokenString, m.keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkRevocation(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkRevocation 检查已通过签名验证的访问令牌是否已在服务端吊销，吊销时返回 ErrTokenRevoked
+func (m *Manager) checkRevocation(ctx context.Context, claims *jwt.Claims) error {
+	// 1. 检查令牌本身是否已被吊销
+	if claims.ID != "" {
+		revoked, err := m.redisClient.Exists(ctx, revokedKey(claims.ID))
+		if err != nil {
+			return fmt.Errorf("查询令牌吊销状态失败: %w", err)
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	// 2. 检查登录会话（令牌族）是否已被吊销
+	if claims.SessionID != "" {
+		active, err := m.redisClient.Exists(ctx, familyKey(claims.SessionID))
+		if err != nil {
+			return fmt.Errorf("查询会话状态失败: %w", err)
+		}
+		if !active {
+			return ErrTokenRevoked
+		}
+	}
+
+	// 3. 检查令牌代数，早于用户最近一次注销全部设备的令牌一律失效
+	generation, err := m.currentGeneration(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if claims.Generation < generation {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// RevokeAccessToken 吊销单个访问令牌，吊销记录保留到令牌自然过期
+func (m *Manager) RevokeAccessToken(ctx context.Context, claims *jwt.Claims) error {
+	if claims.ID == "" {
+		return errors.New("令牌缺少 jti，无法吊销")
+	}
+
+	ttl := m.config.AccessTokenTTL
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	// 已经过期的令牌无需记录
+	if ttl <= 0 {
+		return nil
+	}
+
+	if err := m.redisClient.Set(ctx, revokedKey(claims.ID), "1", ttl); err != nil {
+		return fmt.Errorf("吊销访问令牌失败: %w", err)
+	}
+	return nil
+}
+
+// RevokeRefreshToken 吊销刷新令牌所在的令牌族
+// userID 用于确认刷新令牌属于当前用户，防止吊销他人的会话
+func (m *Manager) RevokeRefreshToken(ctx context.Context, refreshToken string, userID uint) error {
+	record, err := m.getRefreshRecord(ctx, hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if record.UserID != userID {
+		return ErrRefreshTokenInvalid
+	}
+	return m.RevokeFamily(ctx, record.FamilyID)
+}
+
+// RevokeAllForUser 提升用户令牌代数，此前签发的所有访问令牌和刷新令牌立即失效
+func (m *Manager) RevokeAllForUser(ctx context.Context, userID uint) error {
+	if _, err := m.redisClient.Incr(ctx, generationKey(userID)); err != nil {
+		return fmt.Errorf("提升用户令牌代数失败: %w", err)
+	}
+	return nil
+}
+
+// currentGeneration 获取用户当前令牌代数，未设置时为 0
+func (m *Manager) currentGeneration(ctx context.Context, userID uint) (int64, error) {
+	value, err := m.redisClient.Get(ctx, generationKey(userID))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("获取用户令牌代数失败: %w", err)
+	}
+
+	generation, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析用户令牌代数失败: %w", err)
+	}
+	return generation, nil
+}
+
+func revokedKey(jti string) string {
+	return fmt.Sprintf("token:revoked:%s", jti)
+}
+
+func generationKey(userID uint) string {
+	return fmt.Sprintf("token:generation:%d", userID)
+}
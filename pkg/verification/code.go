@@ -0,0 +1,165 @@
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/pkg/redis"
+)
+
+// 验证码用途，不同用途的验证码互不通用
+const (
+	PurposeRegister    = "register"     // 注册
+	PurposeChangeEmail = "change_email" // 修改邮箱（接收方为 用户ID:新邮箱）
+)
+
+// CodeLength 验证码位数
+const CodeLength = 6
+
+// 验证码相关错误
+var (
+	ErrCodeInvalid     = errors.New("验证码错误")
+	ErrCodeNotFound    = errors.New("验证码已过期或不存在，请重新获取")
+	ErrTooManyAttempts = errors.New("验证码错误次数过多，请重新获取")
+	ErrResendTooSoon   = errors.New("验证码发送过于频繁，请稍后再试")
+)
+
+// consumeScript 原子地校验并消费验证码
+// KEYS[1] 验证码哈希，KEYS[2] 尝试次数；ARGV[1] 提交的验证码哈希，ARGV[2] 最大尝试次数
+// 返回 1 校验通过（验证码已删除），0 验证码错误，-1 验证码不存在，-2 尝试次数超限（验证码已删除）
+const consumeScript = `
+local stored = redis.call('GET', KEYS[1])
+if not stored then
+	return -1
+end
+local attempts = redis.call('INCR', KEYS[2])
+if attempts == 1 then
+	redis.call('PEXPIRE', KEYS[2], redis.call('PTTL', KEYS[1]))
+end
+if attempts > tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1], KEYS[2])
+	return -2
+end
+if stored == ARGV[1] then
+	redis.call('DEL', KEYS[1], KEYS[2])
+	return 1
+end
+return 0
+`
+
+// Manager 一次性验证码管理器：验证码只保存哈希值，带有效期和尝试次数限制
+type Manager struct {
+	redisClient    *redis.Client
+	ttl            time.Duration
+	maxAttempts    int
+	resendInterval time.Duration
+}
+
+// NewManager 创建验证码管理器
+func NewManager(cfg *config.EmailCodeConfig, redisClient *redis.Client) *Manager {
+	return &Manager{
+		redisClient:    redisClient,
+		ttl:            cfg.TTL,
+		maxAttempts:    cfg.MaxAttempts,
+		resendInterval: cfg.ResendInterval,
+	}
+}
+
+// TTL 返回验证码有效期
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Issue 为指定用途和接收方（如邮箱）生成新的验证码，旧验证码随即失效
+func (m *Manager) Issue(ctx context.Context, purpose, target string) (string, error) {
+	target = normalizeTarget(target)
+
+	// 限制发送频率
+	if m.resendInterval > 0 {
+		ok, err := m.redisClient.SetNX(ctx, cooldownKey(purpose, target), 1, m.resendInterval)
+		if err != nil {
+			return "", fmt.Errorf("检查发送频率失败: %w", err)
+		}
+		if !ok {
+			return "", ErrResendTooSoon
+		}
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.redisClient.Del(ctx, attemptsKey(purpose, target)); err != nil {
+		return "", fmt.Errorf("重置验证次数失败: %w", err)
+	}
+	if err := m.redisClient.Set(ctx, codeKey(purpose, target), hashCode(purpose, target, code), m.ttl); err != nil {
+		return "", fmt.Errorf("存储验证码到 Redis 失败: %w", err)
+	}
+	return code, nil
+}
+
+// Consume 校验并消费验证码，校验通过后验证码立即失效，不能重复使用
+func (m *Manager) Consume(ctx context.Context, purpose, target, code string) error {
+	target = normalizeTarget(target)
+	code = strings.TrimSpace(code)
+
+	result, err := m.redisClient.Eval(ctx, consumeScript,
+		[]string{codeKey(purpose, target), attemptsKey(purpose, target)},
+		hashCode(purpose, target, code), m.maxAttempts,
+	)
+	if err != nil {
+		return fmt.Errorf("校验验证码失败: %w", err)
+	}
+
+	switch result {
+	case int64(1):
+		return nil
+	case int64(-1):
+		return ErrCodeNotFound
+	case int64(-2):
+		return ErrTooManyAttempts
+	default:
+		return ErrCodeInvalid
+	}
+}
+
+// generateCode 生成均匀分布的6位数字验证码
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("生成验证码失败: %w", err)
+	}
+	return fmt.Sprintf("%0*d", CodeLength, n.Int64()), nil
+}
+
+// hashCode 计算验证码哈希，混入用途和接收方，使验证码只对对应的用途和接收方有效
+func hashCode(purpose, target, code string) string {
+	sum := sha256.Sum256([]byte(purpose + ":" + target + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeTarget 规范化接收方（邮箱不区分大小写）
+func normalizeTarget(target string) string {
+	return strings.ToLower(strings.TrimSpace(target))
+}
+
+func codeKey(purpose, target string) string {
+	return fmt.Sprintf("verify:code:%s:%s", purpose, target)
+}
+
+func attemptsKey(purpose, target string) string {
+	return fmt.Sprintf("verify:attempts:%s:%s", purpose, target)
+}
+
+func cooldownKey(purpose, target string) string {
+	return fmt.Sprintf("verify:cooldown:%s:%s", purpose, target)
+}